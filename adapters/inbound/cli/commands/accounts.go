@@ -3,13 +3,16 @@ package commands
 import (
 	"fmt"
 	"os"
+	"strings"
 
 	"github.com/hirosato/gledger/application"
+	"github.com/hirosato/gledger/application/dto"
 )
 
 // AccountsCommand implements the 'accounts' command
 type AccountsCommand struct {
 	journal *application.Journal
+	output  OutputOptions
 }
 
 // NewAccountsCommand creates a new accounts command
@@ -20,18 +23,44 @@ func NewAccountsCommand(journal *application.Journal) *AccountsCommand {
 }
 
 // Execute runs the accounts command
-func (c *AccountsCommand) Execute(args []string) error {
+func (c *AccountsCommand) Execute(args *Arguments) error {
 	var accounts []string
 	
-	// If pattern is provided, filter accounts
-	if len(args) > 0 {
-		pattern := args[0]
-		accounts = c.journal.GetAccountsMatching(pattern)
+	// The terms are the query
+	for _, option := range args.Options {
+		if _, err := c.output.applyOutputOption(option); err != nil {
+			return err
+		}
+	}
+	query := args.Terms
+
+	// If a query is provided, list the accounts of matching postings
+	if len(query) > 0 {
+		matches, err := c.journal.CompileQuery(query)
+		if err != nil {
+			return err
+		}
+		accounts = c.journal.Filter(matches).GetAccounts()
 	} else {
 		// Get all accounts
 		accounts = c.journal.GetAccounts()
 	}
 
+	if c.output.structured() {
+		list := &dto.AccountList{Accounts: []dto.AccountInfo{}}
+		for _, account := range accounts {
+			parts := strings.Split(account, ":")
+			list.Accounts = append(list.Accounts, dto.AccountInfo{
+				FullName:        account,
+				Name:            parts[len(parts)-1],
+				Level:           len(parts),
+				Parent:          strings.Join(parts[:len(parts)-1], ":"),
+				HasTransactions: true,
+			})
+		}
+		return c.output.write(list)
+	}
+
 	// Print each account on a new line
 	for _, account := range accounts {
 		fmt.Fprintln(os.Stdout, account)
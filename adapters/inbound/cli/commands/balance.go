@@ -6,8 +6,12 @@ import (
 	"sort"
 	"strings"
 
+	"github.com/hirosato/gledger/adapters/inbound/cli/presenters"
 	"github.com/hirosato/gledger/application"
+	"github.com/hirosato/gledger/application/dto"
+	"github.com/hirosato/gledger/application/usecases"
 	"github.com/hirosato/gledger/domain"
+	"github.com/hirosato/gledger/domain/expr"
 )
 
 // BalanceOptions represents options for the balance command
@@ -16,12 +20,27 @@ type BalanceOptions struct {
 	NoTotal  bool // --no-total: Don't show total line
 	Empty    bool // -E, --empty: Show accounts with zero balance
 	NoRollup bool // -n: Don't roll up account balances to parents
+	Forecast string // --forecast EXPR: include periodic transactions while EXPR holds
+	Query    []string // Query terms selecting postings
+	Limit    string // -l, --limit EXPR: only count postings for which EXPR holds
+	Display  string // -d, --display EXPR: only show accounts for which EXPR holds
+	PeriodOptions
+	ValuationOptions
+	GainOptions
+	OutputOptions
+
+	Interval   domain.Interval // -D, -W, -M, --quarterly, -Y: one column per period
+	Cumulative bool            // --cumulative: show totals from the report's start in each column
+	Ending     bool            // --ending: show ending balances, including earlier postings
+	RowTotal   bool            // --row-total: add a column with each account's total
+	Average    bool            // -A, --average: add a column with each account's average
 }
 
 // BalanceCommand implements the 'balance' command
 type BalanceCommand struct {
 	journal *application.Journal
 	options BalanceOptions
+	display *expr.Expr
 }
 
 // NewBalanceCommand creates a new balance command
@@ -32,13 +51,76 @@ func NewBalanceCommand(journal *application.Journal) *BalanceCommand {
 }
 
 // Execute runs the balance command
-func (c *BalanceCommand) Execute(args []string) error {
+func (c *BalanceCommand) Execute(args *Arguments) error {
 	// Parse command line options
 	err := c.parseOptions(args)
 	if err != nil {
 		return err
 	}
 
+	// Project periodic transactions into the future if requested
+	if c.options.Forecast != "" {
+		c.journal, err = forecastJournal(c.journal, c.options.Forecast)
+		if err != nil {
+			return err
+		}
+	}
+
+	// Keep only the postings the limit expression holds for
+	if c.options.Limit != "" {
+		c.journal, err = c.journal.Limit(c.options.Limit)
+		if err != nil {
+			return err
+		}
+	}
+
+	// A reporting interval, given directly or by the period expression,
+	// shows one column per period
+	period, err := c.options.resolve(c.journal)
+	if err != nil {
+		return err
+	}
+
+	// Report capital gains on lots instead of balances if requested
+	if c.options.Gain || c.options.Unrealized {
+		if c.options.structured() {
+			return fmt.Errorf("%s output is not supported for gains", c.options.Format)
+		}
+		return displayGains(c.journal, c.options.GainOptions, period, c.options.Query)
+	}
+
+	// Value amounts at cost or market price if requested
+	c.journal = c.options.ValuationOptions.apply(c.journal, period)
+	interval := c.options.Interval
+	if interval.IsZero() {
+		interval = period.Interval
+	}
+	if !interval.IsZero() {
+		if c.options.structured() {
+			return fmt.Errorf("%s output is not supported with period columns", c.options.Format)
+		}
+		return c.displayPeriodBalances(period, interval)
+	}
+
+	// Keep only the transactions in the report period
+	c.journal = c.journal.Between(period.Begin, period.End)
+
+	// Keep only the postings selected by the query
+	if len(c.options.Query) > 0 {
+		matches, err := c.journal.CompileQuery(c.options.Query)
+		if err != nil {
+			return err
+		}
+		c.journal = c.journal.Filter(matches)
+	}
+
+	if c.options.Display != "" {
+		c.display, err = expr.Parse(c.options.Display)
+		if err != nil {
+			return err
+		}
+	}
+
 	// Get account balances
 	balances := c.getAccountBalances()
 
@@ -46,25 +128,96 @@ func (c *BalanceCommand) Execute(args []string) error {
 	balances = c.filterBalances(balances)
 
 	// Format and display
+	if c.options.structured() {
+		return c.options.write(c.balanceReport(balances))
+	}
 	c.displayBalances(balances)
 
 	return nil
 }
 
-// parseOptions parses command line arguments for balance options
-func (c *BalanceCommand) parseOptions(args []string) error {
-	for _, arg := range args {
-		switch arg {
-		case "--flat":
+// parseOptions applies the parsed command line to the balance options
+func (c *BalanceCommand) parseOptions(args *Arguments) error {
+	for _, option := range args.Options {
+		if c.options.applyPeriodOption(option) || c.options.applyValuationOption(option) {
+			continue
+		}
+		ok, err := c.options.applyGainOption(option)
+		if err != nil {
+			return err
+		}
+		if ok {
+			continue
+		}
+		ok, err = c.options.applyOutputOption(option)
+		if err != nil {
+			return err
+		}
+		if ok {
+			continue
+		}
+
+		switch option.Name {
+		case "flat":
 			c.options.Flat = true
-		case "--no-total":
+		case "no-total":
 			c.options.NoTotal = true
-		case "-E", "--empty":
+		case "empty":
 			c.options.Empty = true
-		case "-n", "--no-rollup":
+		case "collapse", "no-rollup":
 			c.options.NoRollup = true
+		case "cumulative":
+			c.options.Cumulative = true
+		case "ending":
+			c.options.Ending = true
+		case "row-total":
+			c.options.RowTotal = true
+		case "average":
+			c.options.Average = true
+		case "forecast":
+			c.options.Forecast = option.Value
+		case "limit":
+			c.options.Limit = option.Value
+		case "display":
+			c.options.Display = option.Value
+		default:
+			if interval, ok := intervalOptions[option.Name]; ok {
+				c.options.Interval = interval
+			}
 		}
 	}
+
+	// Anything else is part of the query
+	c.options.Query = args.Terms
+	return nil
+}
+
+// displayPeriodBalances displays the multi-period balance table
+func (c *BalanceCommand) displayPeriodBalances(period *domain.Period, interval domain.Interval) error {
+	options := usecases.GetPeriodBalanceOptions{
+		Mode:     usecases.PeriodChange,
+		Interval: interval,
+		Begin:    period.Begin,
+		End:      period.End,
+		Query:    c.options.Query,
+		Empty:    c.options.Empty,
+		RowTotal: c.options.RowTotal,
+		Average:  c.options.Average,
+		NoTotal:  c.options.NoTotal,
+	}
+	if c.options.Cumulative {
+		options.Mode = usecases.PeriodCumulative
+	}
+	if c.options.Ending {
+		options.Mode = usecases.PeriodHistorical
+	}
+
+	report, err := usecases.NewGetPeriodBalance(c.journal).Execute(options)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprint(os.Stdout, presenters.NewPeriodBalancePresenter().Present(report))
 	return nil
 }
 
@@ -251,6 +404,14 @@ func (c *BalanceCommand) filterBalances(balances []AccountBalance) []AccountBala
 		if !c.options.Empty && bal.Balance.IsZero() {
 			continue
 		}
+
+		// Apply the display expression to the account's balance
+		if c.display != nil {
+			shown, err := c.display.Test(&expr.Context{Account: bal.Account, Amount: bal.Balance, Now: c.journal.Now(), Prices: c.journal.GetPriceDB()})
+			if err != nil || !shown {
+				continue
+			}
+		}
 		filtered = append(filtered, bal)
 	}
 
@@ -327,6 +488,33 @@ func (c *BalanceCommand) displayBalances(balances []AccountBalance) {
 	}
 }
 
+// balanceReport returns the balances as a report DTO for structured output,
+// with the same total as the text report
+func (c *BalanceCommand) balanceReport(balances []AccountBalance) *dto.BalanceReport {
+	report := &dto.BalanceReport{Accounts: []dto.AccountBalance{}}
+	for _, bal := range balances {
+		report.Accounts = append(report.Accounts, dto.AccountBalance{
+			Name:    bal.Account,
+			Balance: c.formatBalance(bal.Balance),
+			Level:   bal.Depth,
+			IsEmpty: bal.Balance.IsZero(),
+		})
+	}
+
+	if !c.options.NoTotal {
+		total := domain.NewBalance()
+		for _, account := range c.journal.GetAccounts() {
+			total.AddBalance(c.journal.GetLeafBalance(account))
+		}
+		report.Total = &dto.AccountBalance{
+			Balance: c.formatBalance(total),
+			IsTotal: true,
+			IsEmpty: total.IsZero(),
+		}
+	}
+	return report
+}
+
 // displayAccountBalance displays a single account balance line
 func (c *BalanceCommand) displayAccountBalance(bal AccountBalance) {
 	balanceStr := c.formatBalance(bal.Balance)
@@ -371,25 +559,10 @@ func (c *BalanceCommand) formatBalance(balance *domain.Balance) string {
 		return "0"
 	}
 
-	amounts := balance.GetAmounts()
-	if len(amounts) == 1 {
-		// Single commodity
-		amount := amounts[0]
-		if amount.Commodity.Symbol == "$" {
-			// Default commodity - show without symbol
-			return fmt.Sprintf("%.0f", amount.ToFloat64())
-		}
-		return amount.Format(true)
-	}
-
-	// Multiple commodities
+	// Each amount is shown in the style its commodity was written in
 	var parts []string
-	for _, amount := range amounts {
-		if amount.Commodity.Symbol == "$" {
-			parts = append(parts, fmt.Sprintf("%.0f", amount.ToFloat64()))
-		} else {
-			parts = append(parts, amount.Format(true))
-		}
+	for _, amount := range balance.GetAmounts() {
+		parts = append(parts, amount.Format(true))
 	}
 	return strings.Join(parts, ", ")
 }
\ No newline at end of file
@@ -0,0 +1,79 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/hirosato/gledger/adapters/inbound/cli/presenters"
+	"github.com/hirosato/gledger/application"
+	"github.com/hirosato/gledger/application/usecases"
+)
+
+// BudgetCommand implements the 'budget' command
+type BudgetCommand struct {
+	journal *application.Journal
+	options usecases.GetBudgetOptions
+	period  PeriodOptions
+}
+
+// NewBudgetCommand creates a new budget command
+func NewBudgetCommand(journal *application.Journal) *BudgetCommand {
+	return &BudgetCommand{
+		journal: journal,
+	}
+}
+
+// Execute runs the budget command
+func (c *BudgetCommand) Execute(args *Arguments) error {
+	// Parse command line options
+	err := c.parseOptions(args)
+	if err != nil {
+		return err
+	}
+
+	// Resolve the report period into the budget's range and interval
+	period, err := c.journal.ReportPeriod(c.period.Begin, c.period.End, c.period.Period)
+	if err != nil {
+		return err
+	}
+	c.options.Begin, c.options.End = period.Begin, period.End
+	if c.options.Interval.IsZero() {
+		c.options.Interval = period.Interval
+	}
+
+	report, err := usecases.NewGetBudget(c.journal).Execute(c.options)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprint(os.Stdout, presenters.NewBudgetPresenter().Present(report))
+	return nil
+}
+
+// parseOptions applies the parsed command line to the budget options
+func (c *BudgetCommand) parseOptions(args *Arguments) error {
+	for _, option := range args.Options {
+		if c.period.applyPeriodOption(option) {
+			continue
+		}
+
+		switch option.Name {
+		case "budget":
+			c.options.Mode = usecases.BudgetOnly
+		case "unbudgeted":
+			c.options.Mode = usecases.BudgetUnbudgeted
+		case "add-budget":
+			c.options.Mode = usecases.BudgetAll
+		case "no-total":
+			c.options.NoTotal = true
+		default:
+			if interval, ok := intervalOptions[option.Name]; ok {
+				c.options.Interval = interval
+			}
+		}
+	}
+
+	// Anything else is part of the query
+	c.options.Query = args.Terms
+	return nil
+}
@@ -5,11 +5,13 @@ import (
 	"os"
 
 	"github.com/hirosato/gledger/application"
+	"github.com/hirosato/gledger/application/dto"
 )
 
 // CommoditiesCommand implements the 'commodities' command
 type CommoditiesCommand struct {
 	journal *application.Journal
+	output  OutputOptions
 }
 
 // NewCommoditiesCommand creates a new commodities command
@@ -20,18 +22,37 @@ func NewCommoditiesCommand(journal *application.Journal) *CommoditiesCommand {
 }
 
 // Execute runs the commodities command
-func (c *CommoditiesCommand) Execute(args []string) error {
+func (c *CommoditiesCommand) Execute(args *Arguments) error {
 	var commodities []string
 	
-	// If account pattern is provided, filter by account
-	if len(args) > 0 {
-		accountPattern := args[0]
-		commodities = c.journal.GetCommoditiesForAccount(accountPattern)
+	// The terms are the query
+	for _, option := range args.Options {
+		if _, err := c.output.applyOutputOption(option); err != nil {
+			return err
+		}
+	}
+	query := args.Terms
+
+	// If a query is provided, list the commodities of matching postings
+	if len(query) > 0 {
+		matches, err := c.journal.CompileQuery(query)
+		if err != nil {
+			return err
+		}
+		commodities = c.journal.Filter(matches).GetUsedCommodities()
 	} else {
 		// Get all commodities
 		commodities = c.journal.GetCommodities()
 	}
 
+	if c.output.structured() {
+		list := &dto.CommodityList{Commodities: []dto.CommodityInfo{}}
+		for _, commodity := range commodities {
+			list.Commodities = append(list.Commodities, dto.CommodityInfo{Symbol: commodity})
+		}
+		return c.output.write(list)
+	}
+
 	// Print each commodity on a new line
 	for _, commodity := range commodities {
 		fmt.Fprintln(os.Stdout, commodity)
@@ -24,54 +24,58 @@ func NewEquityCommand(journal *application.Journal) *EquityCommand {
 }
 
 // Execute runs the equity command
-func (c *EquityCommand) Execute(args []string) error {
+func (c *EquityCommand) Execute(args *Arguments) error {
 	// Parse command line options
-	var accountPattern string
 	var showLotPrices bool
 	var showLots bool
 	var dateFormat string = "2006/01/02"
+	var period PeriodOptions
 	
 	// Process arguments
-	i := 0
-	for i < len(args) {
-		arg := args[i]
-		if arg == "--lot-prices" {
+	for _, option := range args.Options {
+		if period.applyPeriodOption(option) {
+			continue
+		}
+
+		switch option.Name {
+		case "lot-prices":
 			showLotPrices = true
-			i++
-		} else if arg == "--lots" {
+		case "lots":
 			showLots = true
-			i++
-		} else if arg == "--date-format" && i+1 < len(args) {
-			i++
+		case "date-format":
 			// Convert ledger date format to Go format
-			dateFormat = convertDateFormat(args[i])
-			i++
-		} else if !strings.HasPrefix(arg, "-") {
-			// This is the account pattern
-			accountPattern = arg
-			i++
-		} else {
-			i++
+			dateFormat = convertDateFormat(option.Value)
 		}
 	}
+	matches, err := c.journal.CompileQuery(args.Terms)
+	if err != nil {
+		return err
+	}
+
+	// Keep only the transactions in the report period
+	journal, err := period.apply(c.journal)
+	if err != nil {
+		return err
+	}
 
 	// Structure to track lots when needed
 	type lot struct {
 		amount    *domain.Amount
 		price     *domain.Amount
 		date      time.Time
+		note      string
 	}
 	
 	// Calculate balances for all accounts
 	balances := make(map[string]*domain.Balance)
 	accountLots := make(map[string][]lot) // Track lots per account if needed
 	
-	for _, tx := range c.journal.GetTransactions() {
+	for _, tx := range journal.GetTransactions() {
 		for _, posting := range tx.Postings {
 			accountName := posting.Account.Name
 			
-			// Filter by account pattern if specified
-			if accountPattern != "" && !strings.Contains(strings.ToLower(accountName), strings.ToLower(accountPattern)) {
+			// Filter by query if specified
+			if !matches(posting) {
 				continue
 			}
 			
@@ -80,26 +84,32 @@ func (c *EquityCommand) Execute(args []string) error {
 				if balances[accountName] == nil {
 					balances[accountName] = domain.NewBalance()
 				}
-				
-				// If we're showing lot prices or lots, track each lot separately for price specs
-				if (showLotPrices || showLots) && posting.HasPrice() {
-					// Store lot information
-					accountLots[accountName] = append(accountLots[accountName], lot{
-						amount: posting.Amount,
-						price:  posting.Price.Amount,
-						date:   tx.Date,
-					})
-				} else {
-					// Normal balance tracking for all accounts
-					balances[accountName].Add(posting.Amount)
-				}
+				balances[accountName].Add(posting.Amount)
 			}
 		}
 	}
+	
+	// If we're showing lot prices or lots, carry each open lot separately,
+	// leaving only what isn't held in lots in the account's balance
+	if showLotPrices || showLots {
+		inventory, err := journal.Filter(matches).BookLots(domain.BookingFIFO)
+		if err != nil {
+			return err
+		}
+		for _, open := range inventory.Lots() {
+			accountLots[open.Account] = append(accountLots[open.Account], lot{
+				amount: open.Quantity,
+				price:  open.Cost,
+				date:   open.Date,
+				note:   open.Label,
+			})
+			balances[open.Account].Add(open.Quantity.Negate())
+		}
+	}
 
 	// Get the latest transaction date to use for the equity entry
 	var latestDate time.Time
-	for _, tx := range c.journal.GetTransactions() {
+	for _, tx := range journal.GetTransactions() {
 		if tx.Date.After(latestDate) {
 			latestDate = tx.Date
 		}
@@ -107,7 +117,7 @@ func (c *EquityCommand) Execute(args []string) error {
 	
 	// If no transactions, use today's date
 	if latestDate.IsZero() {
-		latestDate = time.Now()
+		latestDate = c.journal.Now()
 	}
 
 	// Print the equity transaction
@@ -123,37 +133,38 @@ func (c *EquityCommand) Execute(args []string) error {
 	// First print all regular account balances
 	for _, account := range accounts {
 		// Check if we have lots for this account
-		if lots, hasLots := accountLots[account]; hasLots && (showLotPrices || showLots) {
-			// Print each lot separately
-			for _, lot := range lots {
-				amountStr := c.formatAmount(lot.amount)
-				
-				// Add lot price if requested (lots also include prices)
-				if showLotPrices || showLots {
-					priceStr := c.formatAmount(lot.price)
-					amountStr = fmt.Sprintf("%s {%s}", amountStr, priceStr)
-				}
-				
-				// Add lot date if requested
-				if showLots {
-					dateStr := lot.date.Format(dateFormat)
-					amountStr = fmt.Sprintf("%s [%s]", amountStr, dateStr)
+		// Print each lot separately
+		for _, lot := range accountLots[account] {
+			amountStr := c.formatAmount(lot.amount)
+			
+			// Add lot price if requested (lots also include prices)
+			if showLotPrices || showLots {
+				priceStr := c.formatAmount(lot.price)
+				amountStr = fmt.Sprintf("%s {%s}", amountStr, priceStr)
+			}
+			
+			// Add lot date and note if requested
+			if showLots {
+				dateStr := lot.date.Format(dateFormat)
+				amountStr = fmt.Sprintf("%s [%s]", amountStr, dateStr)
+				if lot.note != "" {
+					amountStr = fmt.Sprintf("%s (%s)", amountStr, lot.note)
 				}
-				
+			}
+			
+			// Use right-aligned formatting with fixed width
+			fmt.Fprintf(os.Stdout, "    %-27s%32s\n", account, amountStr)
+		}
+		
+		// Regular balance output
+		balance := balances[account]
+		for _, amount := range balance.GetAmounts() {
+			if !amount.IsZero() {
+				// Format the amount
+				amountStr := c.formatAmount(amount)
 				// Use right-aligned formatting with fixed width
 				fmt.Fprintf(os.Stdout, "    %-27s%32s\n", account, amountStr)
 			}
-		} else {
-			// Regular balance output
-			balance := balances[account]
-			for _, amount := range balance.GetAmounts() {
-				if !amount.IsZero() {
-					// Format the amount
-					amountStr := c.formatAmount(amount)
-					// Use right-aligned formatting with fixed width
-					fmt.Fprintf(os.Stdout, "    %-27s%32s\n", account, amountStr)
-				}
-			}
 		}
 	}
 	
@@ -164,7 +175,7 @@ func (c *EquityCommand) Execute(args []string) error {
 		totalCommodities += len(balance.GetAmounts())
 	}
 	
-	shouldElideEquity := accountPattern != "" && totalCommodities == 1
+	shouldElideEquity := len(args.Terms) > 0 && totalCommodities == 1
 	
 	// Then print the offsetting Equity:Opening Balances entries
 	if shouldElideEquity {
@@ -178,50 +189,44 @@ func (c *EquityCommand) Execute(args []string) error {
 		}
 		var equityEntries []equityEntry
 		
-		// Track GBP totals when showing lots
-		totalGBP := 0.0
-		
 		for _, account := range accounts {
 			// Handle lots for equity entries
-			if lots, hasLots := accountLots[account]; hasLots && (showLotPrices || showLots) {
-				for _, lot := range lots {
+			for _, lot := range accountLots[account] {
+				// Negate the amount for equity account
+				negatedAmount := lot.amount.Negate()
+				amountStr := c.formatAmount(negatedAmount)
+				
+				// Add lot price if requested (lots also include prices)
+				if showLotPrices || showLots {
+					priceStr := c.formatAmount(lot.price)
+					amountStr = fmt.Sprintf("%s {%s}", amountStr, priceStr)
+				}
+				
+				// Add lot date and note if requested
+				if showLots {
+					dateStr := lot.date.Format(dateFormat)
+					amountStr = fmt.Sprintf("%s [%s]", amountStr, dateStr)
+					if lot.note != "" {
+						amountStr = fmt.Sprintf("%s (%s)", amountStr, lot.note)
+					}
+				}
+				
+				equityAccount := "Equity:Opening Balances"
+				text := fmt.Sprintf("    %-27s%32s\n", equityAccount, amountStr)
+				equityEntries = append(equityEntries, equityEntry{negatedAmount, text})
+			}
+			
+			// Regular balance entries
+			balance := balances[account]
+			for _, amount := range balance.GetAmounts() {
+				if !amount.IsZero() {
 					// Negate the amount for equity account
-					negatedAmount := lot.amount.Negate()
+					negatedAmount := amount.Negate()
 					amountStr := c.formatAmount(negatedAmount)
-					
-					// Add lot price if requested (lots also include prices)
-					if showLotPrices || showLots {
-						priceStr := c.formatAmount(lot.price)
-						amountStr = fmt.Sprintf("%s {%s}", amountStr, priceStr)
-					}
-					
-					// Add lot date if requested
-					if showLots {
-						dateStr := lot.date.Format(dateFormat)
-						amountStr = fmt.Sprintf("%s [%s]", amountStr, dateStr)
-					}
-					
-					// Track GBP total for final equity entry
-					marketValue := lot.price.ToFloat64() * lot.amount.ToFloat64()
-					totalGBP += marketValue
-					
 					equityAccount := "Equity:Opening Balances"
 					text := fmt.Sprintf("    %-27s%32s\n", equityAccount, amountStr)
 					equityEntries = append(equityEntries, equityEntry{negatedAmount, text})
 				}
-			} else {
-				// Regular balance entries
-				balance := balances[account]
-				for _, amount := range balance.GetAmounts() {
-					if !amount.IsZero() {
-						// Negate the amount for equity account
-						negatedAmount := amount.Negate()
-						amountStr := c.formatAmount(negatedAmount)
-						equityAccount := "Equity:Opening Balances"
-						text := fmt.Sprintf("    %-27s%32s\n", equityAccount, amountStr)
-						equityEntries = append(equityEntries, equityEntry{negatedAmount, text})
-					}
-				}
 			}
 		}
 		
@@ -230,7 +235,7 @@ func (c *EquityCommand) Execute(args []string) error {
 		
 		// Sort equity entries: negative amounts first
 		sort.Slice(equityEntries, func(i, j int) bool {
-			return equityEntries[i].amount.ToFloat64() < equityEntries[j].amount.ToFloat64()
+			return equityEntries[i].amount.Number.Cmp(equityEntries[j].amount.Number) < 0
 		})
 		
 		// Print sorted equity entries
@@ -244,10 +249,6 @@ func (c *EquityCommand) Execute(args []string) error {
 
 // formatAmount formats an amount for display
 func (c *EquityCommand) formatAmount(amount *domain.Amount) string {
-	// Format the number part
-	var numberStr string
-	floatVal := amount.ToFloat64()
-	
 	// Use precision from commodity or default to 2
 	precision := 2
 	if amount.Commodity != nil && amount.Commodity.Precision >= 0 {
@@ -255,8 +256,7 @@ func (c *EquityCommand) formatAmount(amount *domain.Amount) string {
 	}
 	
 	// Always use the precision for consistency with ledger-cli
-	format := fmt.Sprintf("%%.%df", precision)
-	numberStr = fmt.Sprintf(format, floatVal)
+	numberStr := amount.Number.FloatString(precision)
 	
 	// Add commodity
 	return numberStr + " " + amount.Commodity.Symbol
@@ -0,0 +1,21 @@
+package commands
+
+import (
+	"github.com/hirosato/gledger/application"
+)
+
+// forecastJournal returns a view of the journal extended with periodic
+// transactions projected from today while the forecast condition holds,
+// for at most application.DefaultForecastYears
+func forecastJournal(journal *application.Journal, condition string) (*application.Journal, error) {
+	until, err := application.ParseForecastUntil(condition, journal.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	from := journal.ForecastStart(journal.Now())
+	if limit := from.AddDate(application.DefaultForecastYears, 0, 0); until.After(limit) {
+		until = limit
+	}
+	return journal.WithForecast(from, until), nil
+}
@@ -0,0 +1,72 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/hirosato/gledger/adapters/inbound/cli/presenters"
+	"github.com/hirosato/gledger/application"
+	"github.com/hirosato/gledger/application/usecases"
+	"github.com/hirosato/gledger/domain"
+)
+
+// GainOptions represents the capital gains options shared by commands
+type GainOptions struct {
+	Gain       bool                 // -G, --gain: report gains realized by lot sales
+	Unrealized bool                 // --unrealized: report gains on the lots still held
+	Booking    domain.BookingMethod // --booking METHOD: fifo, lifo, average or strict
+}
+
+// applyGainOption sets a capital gains option, returning whether the option
+// was one
+func (o *GainOptions) applyGainOption(option ParsedOption) (bool, error) {
+	switch option.Name {
+	case "gain":
+		o.Gain = true
+	case "unrealized":
+		o.Unrealized = true
+	default:
+		return applyBookingOption(option, &o.Booking)
+	}
+	return true, nil
+}
+
+// applyBookingOption sets method from a --booking option, returning whether
+// the option was the booking option
+func applyBookingOption(option ParsedOption, method *domain.BookingMethod) (bool, error) {
+	if option.Name != "booking" {
+		return false, nil
+	}
+	booking, err := domain.ParseBookingMethod(option.Value)
+	if err != nil {
+		return true, err
+	}
+	*method = booking
+	return true, nil
+}
+
+// displayGains books the journal's lots and displays the realized and
+// unrealized gains requested. Lots are booked from the journal's start, but
+// only sales in the report period are shown; held lots are valued at the end
+// of the period, or at now.
+func displayGains(journal *application.Journal, options GainOptions, period *domain.Period, query []string) error {
+	date := journal.Now()
+	if period.End != nil {
+		date = period.End.AddDate(0, 0, -1)
+	}
+
+	report, err := usecases.NewGetGains(journal.Between(nil, period.End)).Execute(usecases.GetGainsOptions{
+		Method:     options.Booking,
+		Realized:   options.Gain,
+		Unrealized: options.Unrealized,
+		Begin:      period.Begin,
+		Date:       date,
+		Query:      query,
+	})
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprint(os.Stdout, presenters.NewGainsPresenter().Present(report))
+	return nil
+}
@@ -0,0 +1,117 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/hirosato/gledger/adapters/inbound/cli/presenters"
+	"github.com/hirosato/gledger/application"
+	"github.com/hirosato/gledger/application/usecases"
+	"github.com/hirosato/gledger/domain"
+)
+
+// LotsOptions represents options for the lots command
+type LotsOptions struct {
+	LotPrices bool                 // --lot-prices: annotate quantities with their {price}
+	LotDates  bool                 // --lot-dates: annotate quantities with their [date]
+	LotNotes  bool                 // --lot-notes: annotate quantities with their (note)
+	Booking   domain.BookingMethod // --booking METHOD: how earlier sales relieved lots
+	NoTotal   bool                 // --no-total: Don't show total line
+	Query     []string             // Query terms selecting postings
+	PeriodOptions
+	OutputOptions
+}
+
+// LotsCommand implements the 'lots' command
+type LotsCommand struct {
+	journal *application.Journal
+	options LotsOptions
+}
+
+// NewLotsCommand creates a new lots command
+func NewLotsCommand(journal *application.Journal) *LotsCommand {
+	return &LotsCommand{
+		journal: journal,
+	}
+}
+
+// Execute runs the lots command
+func (c *LotsCommand) Execute(args *Arguments) error {
+	// Parse command line options
+	err := c.parseOptions(args)
+	if err != nil {
+		return err
+	}
+
+	// Lots are held as of the end of the report period, or now
+	period, err := c.options.resolve(c.journal)
+	if err != nil {
+		return err
+	}
+	date := c.journal.Now()
+	if period.End != nil {
+		date = period.End.AddDate(0, 0, -1)
+	}
+
+	report, err := usecases.NewGetLots(c.journal.Between(nil, period.End)).Execute(usecases.GetLotsOptions{
+		Method:  c.options.Booking,
+		Date:    date,
+		Query:   c.options.Query,
+		NoTotal: c.options.NoTotal,
+	})
+	if err != nil {
+		return err
+	}
+	if c.options.structured() {
+		return c.options.write(report)
+	}
+
+	// Without a choice of annotations, show them all
+	prices, dates, notes := c.options.LotPrices, c.options.LotDates, c.options.LotNotes
+	if !prices && !dates && !notes {
+		prices, dates, notes = true, true, true
+	}
+
+	fmt.Fprint(os.Stdout, presenters.NewLotsPresenter(prices, dates, notes).Present(report))
+	return nil
+}
+
+// parseOptions applies the parsed command line to the lots options
+func (c *LotsCommand) parseOptions(args *Arguments) error {
+	for _, option := range args.Options {
+		if c.options.applyPeriodOption(option) {
+			continue
+		}
+		ok, err := applyBookingOption(option, &c.options.Booking)
+		if err != nil {
+			return err
+		}
+		if ok {
+			continue
+		}
+		ok, err = c.options.applyOutputOption(option)
+		if err != nil {
+			return err
+		}
+		if ok {
+			continue
+		}
+
+		switch option.Name {
+		case "lot-prices":
+			c.options.LotPrices = true
+		case "lot-dates":
+			c.options.LotDates = true
+		case "lot-notes":
+			c.options.LotNotes = true
+		case "lots":
+			c.options.LotPrices, c.options.LotDates, c.options.LotNotes = true, true, true
+		case "no-total":
+			c.options.NoTotal = true
+		}
+	}
+
+	// Anything else is part of the query
+	c.options.Query = args.Terms
+	return nil
+}
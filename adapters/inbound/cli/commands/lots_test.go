@@ -0,0 +1,38 @@
+package commands
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestLotsOutputFormats(t *testing.T) {
+	journal := loadJournal(t, `2012-01-01 * Buy
+    Assets:Brokerage                10 AAPL {$100.00}
+    Assets:Cash
+
+2012-02-01 * Sell
+    Assets:Brokerage                -4 AAPL @ $150.00
+    Assets:Cash                    $600.00
+`)
+
+	var report struct {
+		Lots []struct {
+			Account  string `json:"account"`
+			Quantity string `json:"quantity"`
+			Price    string `json:"price"`
+		} `json:"lots"`
+	}
+	output := runCommand(t, NewLotsCommand(journal).Execute, "-O json --now 2012/03/01")
+	if err := json.Unmarshal([]byte(output), &report); err != nil {
+		t.Fatalf("Expected JSON output, got %v:\n%s", err, output)
+	}
+	if len(report.Lots) != 1 || report.Lots[0].Account != "Assets:Brokerage" || report.Lots[0].Quantity != "6 AAPL" || report.Lots[0].Price != "$100.00" {
+		t.Errorf("Expected the 6 AAPL left at $100.00, got %+v", report.Lots)
+	}
+
+	output = runCommand(t, NewLotsCommand(journal).Execute, "-O csv --now 2012/03/01")
+	if header := strings.SplitN(output, "\n", 2)[0]; header != "account,quantity,price,cost,acquired,label,value,held,isTotal" {
+		t.Errorf("Expected the lot columns, got %q", header)
+	}
+}
@@ -0,0 +1,272 @@
+package commands
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Option describes a command line option of the registry
+type Option struct {
+	Name        string   // Long name, without the leading dashes
+	Short       string   // One letter short name, if any
+	Aliases     []string // Other long names
+	Arg         string   // Name of the option's argument, empty for flags
+	Unsupported bool     // A ledger option gledger accepts but ignores, with a warning
+}
+
+// optionRegistry lists every option gledger accepts. Options are global, as
+// in ledger: any of them may be given to any command, which ignores the ones
+// that don't concern it.
+var optionRegistry = []Option{
+	// Global options
+	{Name: "file", Short: "f", Arg: "FILE"},
+	{Name: "init-file", Arg: "FILE"},
+	{Name: "now", Arg: "DATE"},
+	{Name: "help", Short: "h"},
+	{Name: "version", Short: "v"},
+
+	// Report period and interval
+	{Name: "begin", Short: "b", Arg: "DATE"},
+	{Name: "end", Short: "e", Arg: "DATE"},
+	{Name: "period", Short: "p", Arg: "EXPR"},
+	{Name: "daily", Short: "D"},
+	{Name: "weekly", Short: "W"},
+	{Name: "monthly", Short: "M"},
+	{Name: "quarterly"},
+	{Name: "yearly", Short: "Y"},
+
+	// Valuation and capital gains
+	{Name: "basis", Short: "B", Aliases: []string{"cost"}},
+	{Name: "market", Short: "V"},
+	{Name: "exchange", Short: "X", Arg: "COMMODITY"},
+	{Name: "historical", Short: "H"},
+	{Name: "gain", Short: "G"},
+	{Name: "unrealized"},
+	{Name: "booking", Arg: "METHOD"},
+	{Name: "lot-prices"},
+	{Name: "lot-dates"},
+	{Name: "lot-notes"},
+	{Name: "lots"},
+
+	// Report contents
+	{Name: "limit", Short: "l", Arg: "EXPR"},
+	{Name: "display", Short: "d", Arg: "EXPR"},
+	{Name: "forecast", Arg: "EXPR"},
+	{Name: "budget"},
+	{Name: "unbudgeted"},
+	{Name: "add-budget"},
+	{Name: "actual"},
+	{Name: "generated"},
+
+	// Report layout
+	{Name: "flat"},
+	{Name: "no-total"},
+	{Name: "empty", Short: "E"},
+	{Name: "collapse", Short: "n"},
+	{Name: "no-rollup"},
+	{Name: "cumulative"},
+	{Name: "ending"},
+	{Name: "row-total"},
+	{Name: "average", Short: "A"},
+	{Name: "subtotal", Short: "s"},
+	{Name: "period-sort", Arg: "EXPR"},
+	{Name: "raw"},
+	{Name: "decimal-comma"},
+	{Name: "hashes", Arg: "ALGORITHM"},
+	{Name: "date-format", Short: "y", Arg: "FORMAT"},
+	{Name: "output-format", Short: "O", Arg: "FORMAT"},
+
+	// Ledger options not implemented yet
+	{Name: "sort", Short: "S", Arg: "EXPR", Unsupported: true},
+	{Name: "wide", Short: "w", Unsupported: true},
+	{Name: "columns", Arg: "N", Unsupported: true},
+	{Name: "depth", Arg: "N", Unsupported: true},
+	{Name: "related", Short: "r", Unsupported: true},
+	{Name: "real", Short: "R", Unsupported: true},
+	{Name: "cleared", Short: "C", Unsupported: true},
+	{Name: "pending", Unsupported: true},
+	{Name: "uncleared", Short: "U", Unsupported: true},
+	{Name: "amount", Short: "t", Arg: "EXPR", Unsupported: true},
+	{Name: "total", Short: "T", Arg: "EXPR", Unsupported: true},
+	{Name: "format", Short: "F", Arg: "FORMAT", Unsupported: true},
+	{Name: "head", Arg: "N", Unsupported: true},
+	{Name: "tail", Arg: "N", Unsupported: true},
+	{Name: "invert", Unsupported: true},
+	{Name: "percent", Short: "%", Unsupported: true},
+	{Name: "price-db", Arg: "FILE", Unsupported: true},
+	{Name: "input-date-format", Arg: "FORMAT", Unsupported: true},
+	{Name: "pager", Arg: "PROGRAM", Unsupported: true},
+	{Name: "color", Unsupported: true},
+	{Name: "strict", Unsupported: true},
+	{Name: "pedantic", Unsupported: true},
+	{Name: "explicit", Unsupported: true},
+	{Name: "auto-match", Unsupported: true},
+	{Name: "price", Short: "I", Unsupported: true},
+	{Name: "quantity", Unsupported: true},
+	{Name: "dc", Unsupported: true},
+	{Name: "by-payee", Short: "P", Unsupported: true},
+	{Name: "equity", Unsupported: true},
+	{Name: "unround", Unsupported: true},
+	{Name: "script", Arg: "FILE", Unsupported: true},
+}
+
+// ParsedOption is an option given on the command line, by its long name
+type ParsedOption struct {
+	Name  string
+	Value string // The option's argument, empty for flags
+}
+
+// Arguments is a parsed command line: its options in the order they were
+// given, the remaining terms such as the command and its query, and warnings
+// about the options that were ignored
+type Arguments struct {
+	Options  []ParsedOption
+	Terms    []string
+	Warnings []string
+}
+
+// lookupOption finds a registered option by its long name or an alias
+func lookupOption(name string) (Option, bool) {
+	for _, option := range optionRegistry {
+		if option.Name == name {
+			return option, true
+		}
+		for _, alias := range option.Aliases {
+			if alias == name {
+				return option, true
+			}
+		}
+	}
+	return Option{}, false
+}
+
+// lookupShortOption finds a registered option by its short name
+func lookupShortOption(name string) (Option, bool) {
+	for _, option := range optionRegistry {
+		if option.Short == name {
+			return option, true
+		}
+	}
+	return Option{}, false
+}
+
+// ParseArguments parses a command line against the option registry. Options
+// may come before or after the command, in the forms --name, --name=value,
+// --name value, -x value and -xvalue; everything after -- is a term.
+func ParseArguments(args []string) (*Arguments, error) {
+	parsed := &Arguments{}
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if arg == "--" {
+			parsed.Terms = append(parsed.Terms, args[i+1:]...)
+			break
+		}
+		if len(arg) < 2 || arg[0] != '-' {
+			parsed.Terms = append(parsed.Terms, arg)
+			continue
+		}
+
+		var option Option
+		var value string
+		var hasValue, ok bool
+		if strings.HasPrefix(arg, "--") {
+			name := arg[2:]
+			if idx := strings.Index(name, "="); idx >= 0 {
+				name, value, hasValue = name[:idx], name[idx+1:], true
+			}
+			option, ok = lookupOption(name)
+			if !ok {
+				return nil, unknownOptionError(name)
+			}
+		} else {
+			option, ok = lookupShortOption(arg[1:2])
+			if !ok {
+				return nil, fmt.Errorf("unknown option: %s", arg)
+			}
+			if len(arg) > 2 {
+				if option.Arg == "" {
+					return nil, fmt.Errorf("unknown option: %s", arg)
+				}
+				value, hasValue = arg[2:], true
+			}
+		}
+
+		switch {
+		case option.Arg == "" && hasValue:
+			return nil, fmt.Errorf("option --%s doesn't take an argument", option.Name)
+		case option.Arg != "" && !hasValue:
+			if i+1 >= len(args) {
+				return nil, fmt.Errorf("option --%s requires %s", option.Name, option.Arg)
+			}
+			i++
+			value = args[i]
+		}
+		if option.Unsupported {
+			parsed.Warnings = append(parsed.Warnings, fmt.Sprintf("option --%s is not supported yet, ignoring it", option.Name))
+			continue
+		}
+		parsed.Options = append(parsed.Options, ParsedOption{Name: option.Name, Value: value})
+	}
+	return parsed, nil
+}
+
+// unknownOptionError reports an unknown long option, suggesting the closest
+// registered one when it's only a typo away
+func unknownOptionError(name string) error {
+	best, bestDistance := "", len(name)/3+2
+	for _, option := range optionRegistry {
+		for _, candidate := range append([]string{option.Name}, option.Aliases...) {
+			if distance := editDistance(name, candidate); distance < bestDistance {
+				best, bestDistance = candidate, distance
+			}
+		}
+	}
+	if best == "" {
+		return fmt.Errorf("unknown option: --%s", name)
+	}
+	return fmt.Errorf("unknown option: --%s (did you mean --%s?)", name, best)
+}
+
+// editDistance returns the Levenshtein distance between two strings
+func editDistance(a, b string) int {
+	previous := make([]int, len(b)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		current := make([]int, len(b)+1)
+		current[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous = current
+	}
+	return previous[len(b)]
+}
+
+// Value returns the argument of the last occurrence of an option, and
+// whether the option was given
+func (a *Arguments) Value(name string) (string, bool) {
+	value, found := "", false
+	for _, option := range a.Options {
+		if option.Name == name {
+			value, found = option.Value, true
+		}
+	}
+	return value, found
+}
+
+// Values returns the arguments of every occurrence of an option
+func (a *Arguments) Values(name string) []string {
+	var values []string
+	for _, option := range a.Options {
+		if option.Name == name {
+			values = append(values, option.Value)
+		}
+	}
+	return values
+}
@@ -0,0 +1,87 @@
+package commands
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestParseArguments(t *testing.T) {
+	tests := []struct {
+		args     string
+		options  string
+		terms    string
+		expected string // error, if any
+	}{
+		{"bal -f a.ledger Assets", "file=a.ledger", "bal Assets", ""},
+		{"-M reg --begin=2012 Food", "monthly= begin=2012", "reg Food", ""},
+		{"reg -b 2012 -e2013 --period last month", "begin=2012 end=2013 period=last", "reg month", ""},
+		{"bal --cost -X $", "basis= exchange=$", "bal", ""},
+		{"bal -O json -- -E", "output-format=json", "bal -E", ""},
+		{"bal --montly", "", "", "unknown option: --montly (did you mean --monthly?)"},
+		{"bal --xyzzy", "", "", "unknown option: --xyzzy"},
+		{"bal -Z", "", "", "unknown option: -Z"},
+		{"bal --flat=yes", "", "", "option --flat doesn't take an argument"},
+		{"bal --begin", "", "", "option --begin requires DATE"},
+		{"bal --row-total -E", "row-total= empty=", "bal", ""},
+		{"bal --wide -S amount Assets", "", "bal Assets", ""},
+		{"reg -T amount Food", "", "reg Food", ""},
+	}
+
+	for _, test := range tests {
+		args, err := ParseArguments(strings.Fields(test.args))
+		if test.expected != "" {
+			if err == nil || err.Error() != test.expected {
+				t.Errorf("%q: Expected error %q, got %v", test.args, test.expected, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%q: Unexpected error: %v", test.args, err)
+			continue
+		}
+
+		var options []string
+		for _, option := range args.Options {
+			options = append(options, fmt.Sprintf("%s=%s", option.Name, option.Value))
+		}
+		if got := strings.Join(options, " "); got != test.options {
+			t.Errorf("%q: Expected options %q, got %q", test.args, test.options, got)
+		}
+		if got := strings.Join(args.Terms, " "); got != test.terms {
+			t.Errorf("%q: Expected terms %q, got %q", test.args, test.terms, got)
+		}
+	}
+}
+
+func TestParseArgumentsWarnings(t *testing.T) {
+	args, err := ParseArguments(strings.Fields("bal --depth 2 -T amount Assets"))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	expected := "option --depth is not supported yet, ignoring it; option --total is not supported yet, ignoring it"
+	if got := strings.Join(args.Warnings, "; "); got != expected {
+		t.Errorf("Expected warnings %q, got %q", expected, got)
+	}
+	if got := strings.Join(args.Terms, " "); got != "bal Assets" {
+		t.Errorf("Expected terms %q, got %q", "bal Assets", got)
+	}
+}
+
+func TestArgumentsValue(t *testing.T) {
+	args, err := ParseArguments([]string{"-f", "a.ledger", "--now", "2012/01/01", "--file=b.ledger", "--now=2013/01/01"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if got := strings.Join(args.Values("file"), ","); got != "a.ledger,b.ledger" {
+		t.Errorf("Expected files a.ledger,b.ledger, got %s", got)
+	}
+	if now, ok := args.Value("now"); !ok || now != "2013/01/01" {
+		t.Errorf("Expected the last --now 2013/01/01, got %q", now)
+	}
+	if _, ok := args.Value("help"); ok {
+		t.Errorf("Expected no --help")
+	}
+}
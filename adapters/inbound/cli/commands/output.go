@@ -0,0 +1,52 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/hirosato/gledger/adapters/outbound/formatters"
+	"github.com/hirosato/gledger/domain/ports"
+)
+
+// OutputOptions represents the output format option shared by commands
+type OutputOptions struct {
+	Format    string          // -O, --output-format FORMAT: json, csv or xml instead of text
+	formatter ports.Formatter // The formatter for Format, if any
+}
+
+// applyOutputOption sets the output format option, returning whether the
+// option was one
+func (o *OutputOptions) applyOutputOption(option ParsedOption) (bool, error) {
+	if option.Name != "output-format" {
+		return false, nil
+	}
+
+	// Plain text is the presenters' own output
+	o.Format = option.Value
+	if strings.EqualFold(o.Format, "text") {
+		o.Format, o.formatter = "", nil
+		return true, nil
+	}
+	formatter, err := formatters.NewFormatter(o.Format)
+	if err != nil {
+		return true, err
+	}
+	o.formatter = formatter
+	return true, nil
+}
+
+// structured reports whether a structured output format was requested
+func (o *OutputOptions) structured() bool {
+	return o.formatter != nil
+}
+
+// write formats a report DTO with the requested formatter to stdout
+func (o *OutputOptions) write(report interface{}) error {
+	output, err := o.formatter.Format(report)
+	if err != nil {
+		return err
+	}
+	fmt.Fprint(os.Stdout, output)
+	return nil
+}
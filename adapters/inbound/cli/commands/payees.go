@@ -5,11 +5,13 @@ import (
 	"os"
 
 	"github.com/hirosato/gledger/application"
+	"github.com/hirosato/gledger/application/dto"
 )
 
 // PayeesCommand implements the 'payees' command
 type PayeesCommand struct {
 	journal *application.Journal
+	output  OutputOptions
 }
 
 // NewPayeesCommand creates a new payees command
@@ -20,18 +22,37 @@ func NewPayeesCommand(journal *application.Journal) *PayeesCommand {
 }
 
 // Execute runs the payees command
-func (c *PayeesCommand) Execute(args []string) error {
+func (c *PayeesCommand) Execute(args *Arguments) error {
 	var payees []string
 	
-	// If pattern is provided, filter payees
-	if len(args) > 0 {
-		pattern := args[0]
-		payees = c.journal.GetPayeesMatching(pattern)
+	// The terms are the query
+	for _, option := range args.Options {
+		if _, err := c.output.applyOutputOption(option); err != nil {
+			return err
+		}
+	}
+	query := args.Terms
+
+	// If a query is provided, list the payees of matching postings
+	if len(query) > 0 {
+		matches, err := c.journal.CompileQuery(query)
+		if err != nil {
+			return err
+		}
+		payees = c.journal.Filter(matches).GetPayees()
 	} else {
 		// Get all payees
 		payees = c.journal.GetPayees()
 	}
 
+	if c.output.structured() {
+		list := &dto.PayeeList{Payees: []dto.PayeeInfo{}}
+		for _, payee := range payees {
+			list.Payees = append(list.Payees, dto.PayeeInfo{Name: payee})
+		}
+		return c.output.write(list)
+	}
+
 	// Print each payee on a new line
 	for _, payee := range payees {
 		fmt.Fprintln(os.Stdout, payee)
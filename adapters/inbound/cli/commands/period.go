@@ -0,0 +1,56 @@
+package commands
+
+import (
+	"github.com/hirosato/gledger/application"
+	"github.com/hirosato/gledger/domain"
+)
+
+// PeriodOptions represents the report period options shared by commands
+type PeriodOptions struct {
+	Begin  string // -b, --begin DATE: only include transactions on or after DATE
+	End    string // -e, --end DATE: only include transactions before DATE
+	Period string // -p, --period EXPR: only include transactions in the period
+}
+
+// applyPeriodOption sets a period option, returning whether the option was
+// one
+func (o *PeriodOptions) applyPeriodOption(option ParsedOption) bool {
+	switch option.Name {
+	case "begin":
+		o.Begin = option.Value
+	case "end":
+		o.End = option.Value
+	case "period":
+		o.Period = option.Value
+	default:
+		return false
+	}
+	return true
+}
+
+// resolve returns the report period, which is unbounded when no period
+// option was given
+func (o *PeriodOptions) resolve(journal *application.Journal) (*domain.Period, error) {
+	if o.Begin == "" && o.End == "" && o.Period == "" {
+		return &domain.Period{}, nil
+	}
+	return journal.ReportPeriod(o.Begin, o.End, o.Period)
+}
+
+// apply returns a view of the journal limited to the report period
+func (o *PeriodOptions) apply(journal *application.Journal) (*application.Journal, error) {
+	period, err := o.resolve(journal)
+	if err != nil {
+		return nil, err
+	}
+	return journal.Between(period.Begin, period.End), nil
+}
+
+// intervalOptions maps the reporting interval options to their intervals
+var intervalOptions = map[string]domain.Interval{
+	"daily":     {Unit: domain.IntervalDay, Count: 1},
+	"weekly":    {Unit: domain.IntervalWeek, Count: 1},
+	"monthly":   {Unit: domain.IntervalMonth, Count: 1},
+	"quarterly": {Unit: domain.IntervalQuarter, Count: 1},
+	"yearly":    {Unit: domain.IntervalYear, Count: 1},
+}
@@ -0,0 +1,37 @@
+package commands
+
+import "testing"
+
+func TestPricesCommand(t *testing.T) {
+	journal := loadJournal(t, `P 2012/01/01 AAPL $100.00
+P 2012/01/15 EUR 1,234.5 JPY
+
+2012-02-01 * Buy
+    Assets:Brokerage                10 AAPL @ $120.00
+    Assets:Cash                  $-1,200.00
+
+2012-03-01 * Buy
+    Assets:Brokerage                 5 AAPL @@ $650.00
+    Assets:Cash
+`)
+
+	tests := []struct {
+		args     string
+		expected string // with spaces collapsed
+	}{
+		// Prices from P directives and transactions, in each commodity's style
+		{"", `2012/01/01 AAPL $100.00
+2012/01/15 EUR 1,234.5 JPY
+2012/02/01 AAPL $120.00
+2012/03/01 AAPL $130.00`},
+		{"EUR", `2012/01/15 EUR 1,234.5 JPY`},
+		{"JPY", `2012/01/15 EUR 1,234.5 JPY`},
+	}
+
+	for _, test := range tests {
+		output := runCommand(t, NewPricesCommand(journal).Execute, test.args)
+		if got := collapseSpaces(output); got != test.expected {
+			t.Errorf("prices %s: Expected\n%s\ngot\n%s", test.args, test.expected, output)
+		}
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"time"
 
 	"github.com/hirosato/gledger/application"
+	"github.com/hirosato/gledger/application/dto"
 	"github.com/hirosato/gledger/domain"
 )
 
@@ -34,10 +35,15 @@ var (
 
 // PrintOptions represents options for the print command
 type PrintOptions struct {
+	Query       []string // Query terms selecting the transactions to print
 	Raw         bool   // --raw option: preserve original formatting
 	DecimalComma bool  // --decimal-comma option: use comma as decimal separator
 	Actual       bool  // --actual option: show actual dates
 	Hashes       string // --hashes option: for integrity checking
+	Generated    bool   // --generated option: show automatically generated postings
+	PeriodOptions
+	GainOptions // -G, --gain: add the capital gains postings of lot sales
+	OutputOptions
 }
 
 // PrintCommand implements the 'print' command
@@ -54,18 +60,54 @@ func NewPrintCommand(journal *application.Journal) *PrintCommand {
 }
 
 // Execute runs the print command
-func (c *PrintCommand) Execute(args []string) error {
+func (c *PrintCommand) Execute(args *Arguments) error {
 	// Parse command line options
 	err := c.parseOptions(args)
 	if err != nil {
 		return err
 	}
 
-	// Get all transactions
-	transactions := c.journal.GetTransactions()
+	// Keep only the transactions in the report period
+	journal, err := c.options.apply(c.journal)
+	if err != nil {
+		return err
+	}
+
+	// Add the generated postings balancing realized gains, booking lots
+	// over the whole journal
+	if c.options.Gain {
+		inventory, err := c.journal.BookLots(c.options.Booking)
+		if err != nil {
+			return err
+		}
+		journal = journal.WithCapitalGains(inventory)
+		c.options.Generated = true
+	}
+
+	// Print the whole of each transaction with a posting the query matches
+	matches, err := c.journal.CompileQuery(c.options.Query)
+	if err != nil {
+		return err
+	}
+	var transactions []domain.Transaction
+	for _, tx := range journal.GetTransactions() {
+		for _, posting := range tx.Postings {
+			if matches(posting) {
+				transactions = append(transactions, tx)
+				break
+			}
+		}
+	}
+
+	if c.options.structured() {
+		return c.options.write(c.transactionList(transactions))
+	}
 
-	// Print each transaction
+	// Print each transaction; forecast transactions are never printed
 	for i, tx := range transactions {
+		if tx.IsGenerated {
+			continue
+		}
 		c.printTransaction(&tx)
 		
 		// Add blank line between transactions (except after the last one)
@@ -77,29 +119,103 @@ func (c *PrintCommand) Execute(args []string) error {
 	return nil
 }
 
-// parseOptions parses command line arguments for print options
-func (c *PrintCommand) parseOptions(args []string) error {
-	for _, arg := range args {
-		switch arg {
-		case "--raw":
+// parseOptions applies the parsed command line to the print options
+func (c *PrintCommand) parseOptions(args *Arguments) error {
+	for _, option := range args.Options {
+		if c.options.applyPeriodOption(option) {
+			continue
+		}
+		ok, err := c.options.applyGainOption(option)
+		if err != nil {
+			return err
+		}
+		if ok {
+			continue
+		}
+		ok, err = c.options.applyOutputOption(option)
+		if err != nil {
+			return err
+		}
+		if ok {
+			continue
+		}
+
+		switch option.Name {
+		case "raw":
 			c.options.Raw = true
-		case "--decimal-comma":
+		case "decimal-comma":
 			c.options.DecimalComma = true
-		case "--actual":
+		case "actual":
 			c.options.Actual = true
-		default:
-			if strings.HasPrefix(arg, "--hashes=") {
-				c.options.Hashes = strings.TrimPrefix(arg, "--hashes=")
-			}
+		case "generated":
+			c.options.Generated = true
+		case "hashes":
+			c.options.Hashes = option.Value
 		}
 	}
+
+	// Anything else is part of the query
+	c.options.Query = args.Terms
 	return nil
 }
 
+// transactionList returns the transactions as a DTO for structured output,
+// with the same postings print would show
+func (c *PrintCommand) transactionList(transactions []domain.Transaction) *dto.TransactionList {
+	list := &dto.TransactionList{Transactions: []dto.TransactionInfo{}}
+	for _, tx := range transactions {
+		if tx.IsGenerated {
+			continue
+		}
+
+		info := dto.TransactionInfo{
+			Date:     c.formatDate(tx.Date),
+			Code:     tx.Code,
+			Payee:    tx.Payee,
+			Note:     tx.Note,
+			Postings: []dto.PostingInfo{},
+		}
+		if tx.AuxDate != nil {
+			info.AuxDate = c.formatDate(*tx.AuxDate)
+		}
+		switch tx.Status {
+		case domain.TransactionStatusCleared:
+			info.Status = "cleared"
+		case domain.TransactionStatusReconciled:
+			info.Status = "pending"
+		}
+
+		for _, posting := range tx.Postings {
+			if posting.IsGenerated && !c.options.Generated {
+				continue
+			}
+			postingInfo := dto.PostingInfo{
+				Account: posting.DisplayAccountName(),
+				Note:    posting.Note,
+			}
+			if posting.Amount != nil {
+				postingInfo.Amount = c.formatAmount(posting.Amount)
+			}
+			if posting.HasCost() {
+				postingInfo.Cost = c.formatCost(posting.Cost)
+			}
+			if posting.HasPrice() && !posting.Price.Inferred {
+				postingInfo.Price = c.formatPrice(posting.Price)
+			}
+			info.Postings = append(info.Postings, postingInfo)
+		}
+		list.Transactions = append(list.Transactions, info)
+	}
+	return list
+}
+
 // printTransaction prints a single transaction in ledger format
 func (c *PrintCommand) printTransaction(tx *domain.Transaction) {
-	// Print transaction header: date [status] [code] payee
+	// Print transaction header: date[=aux date] [status] [code] payee
 	line := c.formatDate(tx.Date)
+	if tx.AuxDate != nil {
+		line += "=" + c.formatDate(*tx.AuxDate)
+	}
 	
 	// Add spacing - varies by options and format
 	if c.options.Raw {
@@ -131,15 +247,31 @@ func (c *PrintCommand) printTransaction(tx *domain.Transaction) {
 	
 	fmt.Println(line)
 	
+	// Print transaction note if present
+	for _, note := range noteLines(tx.Note) {
+		fmt.Println(postingIndentStr + "; " + note)
+	}
+	
 	// Print postings
 	for _, posting := range tx.Postings {
+		// Postings added by automated transactions are only shown on request
+		if posting.IsGenerated && !c.options.Generated {
+			continue
+		}
 		c.printPosting(posting)
 	}
-	
-	// Print transaction note if present
-	if tx.Note != "" {
-		fmt.Println(postingIndentStr + "; " + tx.Note)
+}
+
+// postingAccount returns a posting's account as printed, after the
+// posting's own status mark if it has one
+func postingAccount(posting *domain.Posting) string {
+	switch posting.Status {
+	case domain.PostingStatusCleared:
+		return "* " + posting.DisplayAccountName()
+	case domain.PostingStatusPending:
+		return "! " + posting.DisplayAccountName()
 	}
+	return posting.DisplayAccountName()
 }
 
 // printPosting prints a single posting line
@@ -151,7 +283,7 @@ func (c *PrintCommand) printPosting(posting *domain.Posting) {
 			// Use the original expression if we have it
 			amountStr = posting.ExpressionAmount
 			if c.options.DecimalComma {
-				amountStr = strings.ReplaceAll(amountStr, ".", ",")
+				amountStr = swapDecimalMarks(amountStr)
 			}
 		} else {
 			amountStr = c.formatAmount(posting.Amount)
@@ -165,8 +297,8 @@ func (c *PrintCommand) printPosting(posting *domain.Posting) {
 			}
 		}
 		
-		// Add price information if present
-		if posting.HasPrice() {
+		// Add price information if present (inferred prices were never written)
+		if posting.HasPrice() && !posting.Price.Inferred {
 			priceStr := c.formatPrice(posting.Price)
 			if priceStr != "" {
 				amountStr += " " + priceStr
@@ -182,7 +314,7 @@ func (c *PrintCommand) printPosting(posting *domain.Posting) {
 		}
 		
 		// Format with ledger-style alignment
-		accountName := posting.Account.Name
+		accountName := postingAccount(posting)
 		
 		// Ledger's alignment strategy (derived from baseline test analysis):
 		// 1. For complex amounts: use minimal spacing for readability
@@ -195,7 +327,7 @@ func (c *PrintCommand) printPosting(posting *domain.Posting) {
 		
 		// Detect if this is a simple currency amount (short and starts with currency symbol)
 		isSimpleCurrency := len(amountStr) <= simpleCurrencyMaxLength && 
-			(strings.HasPrefix(amountStr, "$") || strings.HasPrefix(amountStr, "€") || strings.HasPrefix(amountStr, "£"))
+			posting.Amount != nil && posting.Amount.Commodity.Prefix
 		
 		// Detect if this is a complex amount (contains @ or multiple components)
 		isComplexAmount := strings.Contains(amountStr, "@") || strings.Count(amountStr, " ") > complexAmountMinSpaces
@@ -231,13 +363,21 @@ func (c *PrintCommand) printPosting(posting *domain.Posting) {
 		}
 	} else {
 		// No amount and no expression - just print account name
-		fmt.Printf("%s%s\n", postingIndentStr, posting.Account.Name)
+		fmt.Printf("%s%s\n", postingIndentStr, postingAccount(posting))
 	}
 	
 	// Print posting note if present
-	if posting.Note != "" {
-		fmt.Println(noteIndentStr + "; " + posting.Note)
+	for _, note := range noteLines(posting.Note) {
+		fmt.Println(noteIndentStr + "; " + note)
+	}
+}
+
+// noteLines splits a note into its lines
+func noteLines(note string) []string {
+	if note == "" {
+		return nil
 	}
+	return strings.Split(note, "\n")
 }
 
 // formatDate formats a date for transaction header
@@ -251,40 +391,25 @@ func (c *PrintCommand) formatAmount(amount *domain.Amount) string {
 		return ""
 	}
 	
-	// Get the numeric string
-	numberStr := c.formatNumber(amount)
-	
-	// Handle commodity placement - currencies like $ go before, others after
-	commodity := amount.Commodity.Symbol
-	if commodity == "$" || commodity == "€" || commodity == "£" {
-		result := commodity + numberStr
-		if c.options.DecimalComma {
-			return strings.ReplaceAll(result, ".", ",")
-		}
-		return result
-	}
-	
-	// Non-currency commodities go after the number
-	result := numberStr + " " + commodity
+	// The commodity remembers where its symbol goes and which separators it uses
+	result := amount.Format(true)
 	if c.options.DecimalComma {
-		return strings.ReplaceAll(result, ".", ",")
+		return swapDecimalMarks(result)
 	}
 	return result
 }
 
-// formatNumber formats just the numeric part of an amount
-func (c *PrintCommand) formatNumber(amount *domain.Amount) string {
-	if amount.Commodity.Precision == 0 {
-		if amount.Number.IsInt() {
-			return amount.Number.Num().String()
+// swapDecimalMarks exchanges periods and commas, turning "1,000.00" into "1.000,00"
+func swapDecimalMarks(s string) string {
+	return strings.Map(func(r rune) rune {
+		switch r {
+		case '.':
+			return ','
+		case ',':
+			return '.'
 		}
-		return amount.Number.String()
-	}
-	
-	floatValue, _ := amount.Number.Float64()
-	precision := amount.Commodity.Precision
-	format := fmt.Sprintf("%%.%df", precision)
-	return fmt.Sprintf(format, floatValue)
+		return r
+	}, s)
 }
 
 // formatCost formats cost basis information
@@ -293,15 +418,26 @@ func (c *PrintCommand) formatCost(cost *domain.CostBasis) string {
 		return ""
 	}
 	
+	var parts []string
 	if cost.PerUnitAmount != nil {
-		return "{" + c.formatAmount(cost.PerUnitAmount) + "}"
+		if cost.IsFixed {
+			parts = append(parts, "{="+c.formatAmount(cost.PerUnitAmount)+"}")
+		} else {
+			parts = append(parts, "{"+c.formatAmount(cost.PerUnitAmount)+"}")
+		}
+	} else if cost.Amount != nil {
+		parts = append(parts, "{{"+c.formatAmount(cost.Amount)+"}}")
+	}
+	
+	if cost.Date != nil {
+		parts = append(parts, "["+c.formatDate(*cost.Date)+"]")
 	}
 	
-	if cost.Amount != nil {
-		return "{{" + c.formatAmount(cost.Amount) + "}}"
+	if cost.Label != "" {
+		parts = append(parts, "("+cost.Label+")")
 	}
 	
-	return ""
+	return strings.Join(parts, " ")
 }
 
 // formatPrice formats price specification information
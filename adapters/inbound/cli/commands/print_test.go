@@ -0,0 +1,98 @@
+package commands
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestPrintQuery(t *testing.T) {
+	journal := loadJournal(t, `2012-01-05 * Shell
+    Expenses:Fuel                $40
+    Assets:Cash
+
+2012-01-06 * Grocery
+    Expenses:Food                $10
+    Assets:Cash
+`)
+
+	tests := []struct {
+		args     string
+		expected []string // payees printed
+	}{
+		{"", []string{"Shell", "Grocery"}},
+		{"payee Shell", []string{"Shell"}},
+		{"Food", []string{"Grocery"}},
+		{"Cash", []string{"Shell", "Grocery"}},
+		{"Rent", nil},
+	}
+
+	for _, test := range tests {
+		output := runCommand(t, NewPrintCommand(journal).Execute, test.args)
+		var payees []string
+		for _, line := range strings.Split(output, "\n") {
+			if strings.HasPrefix(line, "2012") {
+				payees = append(payees, line[strings.LastIndex(line, " ")+1:])
+			}
+		}
+		if strings.Join(payees, ",") != strings.Join(test.expected, ",") {
+			t.Errorf("print %s: Expected %v, got\n%s", test.args, test.expected, output)
+		}
+	}
+
+	// Transactions are printed whole, not just the matching postings
+	output := runCommand(t, NewPrintCommand(journal).Execute, "payee Shell")
+	if !strings.Contains(output, "Expenses:Fuel") || !strings.Contains(output, "Assets:Cash") {
+		t.Errorf("Expected the whole transaction, got\n%s", output)
+	}
+}
+
+func TestPrintGainParsesAgain(t *testing.T) {
+	journal := loadJournal(t, `2012-01-01 * Buy
+    Assets:Brokerage                10 AAPL {$100.00}
+    Assets:Cash
+
+2012-02-01 * Buy
+    Assets:Brokerage                10 AAPL {$120.00}
+    Assets:Cash
+
+2012-03-01 * Sell
+    Assets:Brokerage               -15 AAPL @ $150.00
+    Assets:Cash                   $2250.00
+`)
+
+	output := runCommand(t, NewPrintCommand(journal).Execute, "--gain")
+
+	// The sale is restated at the cost of each lot it relieved, so that the
+	// printed transaction balances with the gain posting
+	for _, posting := range []string{"-10 AAPL {$100.00} [2012/01/01]", "-5 AAPL {$120.00} [2012/02/01]", "Equity:Capital Gains $-650.00"} {
+		if !strings.Contains(collapseSpaces(output), posting) {
+			t.Errorf("Expected %q in\n%s", posting, output)
+		}
+	}
+
+	printed := loadJournal(t, output)
+	if got := printed.GetBalance("Equity:Capital Gains").String(); got != "$-650.00" {
+		t.Errorf("Expected capital gains of $-650.00, got %s", got)
+	}
+	if got := printed.GetBalance("Assets:Brokerage").String(); got != "5 AAPL" {
+		t.Errorf("Expected 5 AAPL left, got %s", got)
+	}
+}
+
+func TestPrintPostingStatus(t *testing.T) {
+	journal := loadJournal(t, `2012-01-05 Grocery
+    * Expenses:Food                $10
+    ! Expenses:Drinks               $5
+    Assets:Cash
+`)
+
+	output := collapseSpaces(runCommand(t, NewPrintCommand(journal).Execute, ""))
+	for _, posting := range []string{"* Expenses:Food $10", "! Expenses:Drinks $5", "Assets:Cash"} {
+		if !strings.Contains(output, posting) {
+			t.Errorf("Expected %q in\n%s", posting, output)
+		}
+	}
+	if strings.Contains(output, "* Assets:Cash") || strings.Contains(output, "! Assets:Cash") {
+		t.Errorf("Expected no status on Assets:Cash, got\n%s", output)
+	}
+}
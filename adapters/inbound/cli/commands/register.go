@@ -3,11 +3,13 @@ package commands
 import (
 	"fmt"
 	"os"
-	"strings"
+	"sort"
 	"time"
 
 	"github.com/hirosato/gledger/application"
+	"github.com/hirosato/gledger/application/dto"
 	"github.com/hirosato/gledger/domain"
+	"github.com/hirosato/gledger/domain/expr"
 )
 
 // RegisterFormat defines the formatting constants for register output
@@ -30,7 +32,19 @@ var DefaultRegisterFormat = RegisterFormat{
 
 // RegisterOptions represents options for the register command
 type RegisterOptions struct {
-	AccountFilter string // Account pattern filter (e.g., :inve for Assets:Investment)
+	Query    []string // Query terms selecting postings (e.g., :inve for Assets:Investment)
+	Forecast string   // --forecast EXPR: include periodic transactions while EXPR holds
+	Limit    string   // -l, --limit EXPR: only count postings for which EXPR holds
+	Display  string   // -d, --display EXPR: only show postings for which EXPR holds
+	PeriodOptions
+	ValuationOptions
+	OutputOptions
+
+	Interval   domain.Interval // -D, -W, -M, --quarterly, -Y: one row per account and period
+	Subtotal   bool            // -s, --subtotal: one row per account for the whole report
+	Collapse   bool            // -n, --collapse: one row per transaction or period
+	Empty      bool            // -E, --empty: show periods without postings
+	PeriodSort string          // --period-sort EXPR: sort the rows of each period by EXPR
 }
 
 // RegisterCommand implements the 'register' command
@@ -38,6 +52,16 @@ type RegisterCommand struct {
 	journal *application.Journal
 	options RegisterOptions
 	format  RegisterFormat
+	matches application.PostingPredicate
+	display *expr.Expr
+	sort    *expr.Expr
+	report  *dto.RegisterReport // Collects the rows for structured output
+}
+
+// registerRow is an account's subtotal within a transaction or period
+type registerRow struct {
+	account string
+	amount  *domain.Balance
 }
 
 // NewRegisterCommand creates a new register command
@@ -59,53 +83,145 @@ func (c *RegisterCommand) formatString() string {
 }
 
 // Execute runs the register command
-func (c *RegisterCommand) Execute(args []string) error {
+func (c *RegisterCommand) Execute(args *Arguments) error {
 	// Parse command line options
 	err := c.parseOptions(args)
 	if err != nil {
 		return err
 	}
 
+	c.matches, err = c.journal.CompileQuery(c.options.Query)
+	if err != nil {
+		return err
+	}
+
+	if c.options.Display != "" {
+		c.display, err = expr.Parse(c.options.Display)
+		if err != nil {
+			return err
+		}
+	}
+	if c.options.PeriodSort != "" {
+		c.sort, err = expr.Parse(c.options.PeriodSort)
+		if err != nil {
+			return err
+		}
+	}
+
+	// Project periodic transactions into the future if requested
+	journal := c.journal
+	if c.options.Forecast != "" {
+		journal, err = forecastJournal(journal, c.options.Forecast)
+		if err != nil {
+			return err
+		}
+	}
+
+	// Keep only the transactions in the report period
+	period, err := c.options.resolve(journal)
+	if err != nil {
+		return err
+	}
+	journal = journal.Between(period.Begin, period.End)
+
+	// Value amounts at cost or market price if requested
+	journal = c.options.ValuationOptions.apply(journal, period)
+
+	// Keep only the postings the limit expression holds for
+	if c.options.Limit != "" {
+		journal, err = journal.Limit(c.options.Limit)
+		if err != nil {
+			return err
+		}
+	}
+
 	// Get all transactions
-	transactions := c.journal.GetTransactions()
+	transactions := journal.GetTransactions()
 
 	// Track running balances
 	runningBalance := domain.NewBalance()
 
-	// Process each transaction
-	for _, tx := range transactions {
-		c.displayTransaction(&tx, runningBalance)
+	// Structured output collects the rows into a report instead
+	if c.options.structured() {
+		c.report = &dto.RegisterReport{Entries: []dto.RegisterEntry{}}
+	}
+
+	// A period expression such as "monthly in 2012" also groups the report
+	interval := c.options.Interval
+	if interval.IsZero() {
+		interval = period.Interval
+	}
+	if !interval.IsZero() || c.options.Subtotal {
+		c.displayPeriods(transactions, period, interval, runningBalance)
+	} else {
+		// Process each transaction
+		for _, tx := range transactions {
+			c.displayTransaction(&tx, runningBalance)
+		}
 	}
 
+	if c.report != nil {
+		c.report.RunningTotal = runningBalance.String()
+		return c.options.write(c.report)
+	}
 	return nil
 }
 
-// parseOptions parses command line arguments for register options
-func (c *RegisterCommand) parseOptions(args []string) error {
-	for _, arg := range args {
-		if strings.HasPrefix(arg, ":") {
-			// Account filter
-			c.options.AccountFilter = arg[1:] // Remove the ':' prefix
+// parseOptions applies the parsed command line to the register options
+func (c *RegisterCommand) parseOptions(args *Arguments) error {
+	for _, option := range args.Options {
+		if c.options.applyPeriodOption(option) || c.options.applyValuationOption(option) {
+			continue
+		}
+		ok, err := c.options.applyOutputOption(option)
+		if err != nil {
+			return err
+		}
+		if ok {
+			continue
+		}
+
+		switch option.Name {
+		case "forecast":
+			c.options.Forecast = option.Value
+		case "limit":
+			c.options.Limit = option.Value
+		case "display":
+			c.options.Display = option.Value
+		case "subtotal":
+			c.options.Subtotal = true
+		case "collapse":
+			c.options.Collapse = true
+		case "empty":
+			c.options.Empty = true
+		case "period-sort":
+			c.options.PeriodSort = option.Value
+		case "gain", "unrealized":
+			// Capital gains are reported by balance and print only
+			return fmt.Errorf("option --%s is not supported by register", option.Name)
+		default:
+			if interval, ok := intervalOptions[option.Name]; ok {
+				c.options.Interval = interval
+			}
 		}
 	}
+
+	// Anything else is part of the query
+	c.options.Query = args.Terms
 	return nil
 }
 
 // displayTransaction displays a transaction in register format
 func (c *RegisterCommand) displayTransaction(tx *domain.Transaction, runningBalance *domain.Balance) {
-	// Filter postings based on account filter if specified
+	// Filter postings based on the query
 	var postingsToShow []*domain.Posting
-	if c.options.AccountFilter != "" {
-		for _, posting := range tx.Postings {
-			if c.matchesAccountFilter(posting.Account.Name, c.options.AccountFilter) {
-				postingsToShow = append(postingsToShow, posting)
-			}
+	for _, posting := range tx.Postings {
+		if c.matches(posting) {
+			postingsToShow = append(postingsToShow, posting)
 		}
-		if len(postingsToShow) == 0 {
-			return // No matching postings, skip this transaction
-		}
-	} else {
-		postingsToShow = tx.Postings
+	}
+	if len(postingsToShow) == 0 {
+		return // No matching postings, skip this transaction
 	}
 
 	// Format date (12-Jan-10 format)
@@ -114,50 +230,205 @@ func (c *RegisterCommand) displayTransaction(tx *domain.Transaction, runningBala
 	// Format description (truncated to ~20 chars)
 	descStr := c.formatDescription(tx.Payee)
 
-	// Display first posting with date and description
-	if len(postingsToShow) > 0 {
-		first := postingsToShow[0]
-		amountStr := c.formatAmount(first.Amount)
-		
+	// Collapsing shows the postings' total on a single row
+	if c.options.Collapse {
+		total := domain.NewBalance()
+		for _, posting := range postingsToShow {
+			total.Add(posting.Amount)
+		}
+		c.displayRows(tx.Date, tx.Payee, []registerRow{{account: "<Total>", amount: total}}, runningBalance)
+		return
+	}
+
+	// The first posting shown carries the date and description
+	for _, posting := range postingsToShow {
 		// Update running balance
-		runningBalance.Add(first.Amount)
+		runningBalance.Add(posting.Amount)
+		if !c.shows(posting, runningBalance) {
+			continue
+		}
+		if c.report != nil {
+			amount := domain.NewBalance()
+			amount.Add(posting.Amount)
+			c.record(tx.Date, tx.Payee, posting.DisplayAccountName(), amount, runningBalance, posting.Note)
+			continue
+		}
+
+		amountStr := c.formatAmount(posting.Amount)
 		runningBalanceStr := c.formatBalance(runningBalance)
 
 		fmt.Fprintf(os.Stdout, c.formatString(), 
-			dateStr, descStr, first.Account.Name, amountStr, runningBalanceStr)
+			dateStr, descStr, posting.DisplayAccountName(), amountStr, runningBalanceStr)
+		dateStr, descStr = "", ""
 		
 		// Display additional balance lines for multi-commodity
 		c.displayAdditionalBalanceLines(runningBalance)
+	}
+}
+
+// displayPeriods displays one row per account and period, as ledger does for
+// --monthly and the other interval options, or a single period for --subtotal
+func (c *RegisterCommand) displayPeriods(transactions []domain.Transaction, period *domain.Period, interval domain.Interval, runningBalance *domain.Balance) {
+	// Collect the postings selected by the query with their dates
+	type datedPosting struct {
+		date    time.Time
+		posting *domain.Posting
+	}
+	var postings []datedPosting
+	var begin, end time.Time
+	for _, tx := range transactions {
+		for _, posting := range tx.Postings {
+			if posting.Amount == nil || !c.matches(posting) {
+				continue
+			}
+			postings = append(postings, datedPosting{date: tx.Date, posting: posting})
+			if begin.IsZero() || tx.Date.Before(begin) {
+				begin = tx.Date
+			}
+			if last := tx.Date.AddDate(0, 0, 1); last.After(end) {
+				end = last
+			}
+		}
+	}
+	if len(postings) == 0 {
+		return
+	}
+
+	// Empty periods are only shown within the report period's bounds
+	if period.Begin != nil {
+		begin = *period.Begin
+	}
+	if period.End != nil {
+		end = *period.End
+	}
+
+	split := &domain.Period{Interval: interval}
+	for _, r := range split.Split(begin, end) {
+		totals := make(map[string]*domain.Balance)
+		var accounts []string
+		for _, p := range postings {
+			if !r.Contains(p.date) {
+				continue
+			}
+			account := p.posting.DisplayAccountName()
+			if totals[account] == nil {
+				totals[account] = domain.NewBalance()
+				accounts = append(accounts, account)
+			}
+			totals[account].Add(p.posting.Amount)
+		}
+		sort.Strings(accounts)
+
+		rows := make([]registerRow, 0, len(accounts))
+		for _, account := range accounts {
+			rows = append(rows, registerRow{account: account, amount: totals[account]})
+		}
+		if c.options.Collapse && len(rows) > 0 {
+			total := domain.NewBalance()
+			for _, row := range rows {
+				total.AddBalance(row.amount)
+			}
+			rows = []registerRow{{account: "<Total>", amount: total}}
+		}
+		if len(rows) == 0 {
+			if !c.options.Empty {
+				continue
+			}
+			rows = []registerRow{{amount: domain.NewBalance()}}
+		}
+		c.sortRows(rows)
 
-		// Display additional postings (indented, no date/description)
-		for _, posting := range postingsToShow[1:] {
-			amountStr := c.formatAmount(posting.Amount)
-			runningBalance.Add(posting.Amount)
-			runningBalanceStr := c.formatBalance(runningBalance)
-
-			fmt.Fprintf(os.Stdout, c.formatString(), 
-				"", "", posting.Account.Name, amountStr, runningBalanceStr)
-			
-			// Display additional balance lines for multi-commodity
-			c.displayAdditionalBalanceLines(runningBalance)
-		}
-
-		// If we're filtering and showing only some postings, we need to account for 
-		// the unseen postings in the running balance
-		if c.options.AccountFilter != "" {
-			for _, posting := range tx.Postings {
-				if !c.matchesAccountFilter(posting.Account.Name, c.options.AccountFilter) {
-					runningBalance.Add(posting.Amount)
-				}
+		// Periods show their first and last day in place of date and payee
+		last := c.formatDate(r.End.AddDate(0, 0, -1))
+		if c.report != nil {
+			last = r.End.AddDate(0, 0, -1).Format("2006/01/02")
+		}
+		c.displayRows(r.Begin, "- "+last, rows, runningBalance)
+	}
+}
+
+// sortRows orders a period's rows by the --period-sort expression. Rows the
+// expression can't be evaluated for keep their order.
+func (c *RegisterCommand) sortRows(rows []registerRow) {
+	if c.sort == nil {
+		return
+	}
+	keys := make(map[string]expr.Value)
+	for _, row := range rows {
+		value, err := c.sort.Eval(&expr.Context{Account: row.account, Amount: row.amount, Now: c.journal.Now(), Prices: c.journal.GetPriceDB()})
+		if err == nil {
+			keys[row.account] = value
+		}
+	}
+	sort.SliceStable(rows, func(i, j int) bool {
+		left, ok := keys[rows[i].account]
+		if !ok {
+			return false
+		}
+		right, ok := keys[rows[j].account]
+		if !ok {
+			return false
+		}
+		order, err := expr.Compare(left, right)
+		return err == nil && order < 0
+	})
+}
+
+// displayRows displays subtotal rows, the first carrying the date and
+// description. Amounts in several commodities take a line each.
+func (c *RegisterCommand) displayRows(date time.Time, payee string, rows []registerRow, runningBalance *domain.Balance) {
+	dateStr, descStr := c.formatDate(date), c.formatDescription(payee)
+	for _, row := range rows {
+		runningBalance.AddBalance(row.amount)
+		if c.display != nil {
+			shown, err := c.display.Test(&expr.Context{Account: row.account, Amount: row.amount, Total: runningBalance, Now: c.journal.Now(), Prices: c.journal.GetPriceDB()})
+			if err != nil || !shown {
+				continue
 			}
 		}
+
+		if c.report != nil {
+			c.record(date, payee, row.account, row.amount, runningBalance, "")
+			continue
+		}
+
+		amounts := row.amount.GetAmounts()
+		amountStr := "0"
+		if len(amounts) > 0 {
+			amountStr = c.formatAmount(amounts[0])
+		}
+		fmt.Fprintf(os.Stdout, c.formatString(),
+			dateStr, descStr, row.account, amountStr, c.formatBalance(runningBalance))
+		dateStr, descStr = "", ""
+
+		for i := 1; i < len(amounts); i++ {
+			fmt.Fprintf(os.Stdout, c.formatString(), "", "", "", c.formatAmount(amounts[i]), "")
+		}
+		c.displayAdditionalBalanceLines(runningBalance)
 	}
 }
 
-// matchesAccountFilter checks if an account name matches the filter
-func (c *RegisterCommand) matchesAccountFilter(accountName, filter string) bool {
-	// Simple substring matching for now (matches ":inve" pattern)
-	return strings.Contains(strings.ToLower(accountName), strings.ToLower(filter))
+// record adds a row to the structured report, with full dates, payees and
+// balances rather than the text columns' abbreviations
+func (c *RegisterCommand) record(date time.Time, payee, account string, amount, runningBalance *domain.Balance, note string) {
+	c.report.Entries = append(c.report.Entries, dto.RegisterEntry{
+		Date:         date.Format("2006/01/02"),
+		Payee:        payee,
+		Account:      account,
+		Amount:       amount.String(),
+		RunningTotal: runningBalance.String(),
+		Note:         note,
+	})
+}
+
+// shows reports whether the --display expression, if any, holds for a
+// posting and the running total after it
+func (c *RegisterCommand) shows(posting *domain.Posting, runningBalance *domain.Balance) bool {
+	if c.display == nil {
+		return true
+	}
+	shown, err := c.display.Test(&expr.Context{Posting: posting, Total: runningBalance, Now: c.journal.Now(), Prices: c.journal.GetPriceDB()})
+	return err == nil && shown
 }
 
 // formatDate formats a date in register format (12-Jan-10)
@@ -183,9 +454,6 @@ func (c *RegisterCommand) formatDescription(payee string) string {
 
 // formatAmount formats an amount for display
 func (c *RegisterCommand) formatAmount(amount *domain.Amount) string {
-	if amount.Commodity.Symbol == "$" {
-		return fmt.Sprintf("%.0f", amount.ToFloat64())
-	}
 	return amount.Format(true)
 }
 
@@ -197,11 +465,7 @@ func (c *RegisterCommand) formatBalance(balance *domain.Balance) string {
 
 	amounts := balance.GetAmounts()
 	if len(amounts) > 0 {
-		amount := amounts[0] // Show only first commodity in main line
-		if amount.Commodity.Symbol == "$" {
-			return fmt.Sprintf("%.0f", amount.ToFloat64())
-		}
-		return amount.Format(true)
+		return c.formatAmount(amounts[0]) // Show only first commodity in main line
 	}
 	
 	return "0"
@@ -216,13 +480,7 @@ func (c *RegisterCommand) displayAdditionalBalanceLines(balance *domain.Balance)
 	amounts := balance.GetAmounts()
 	// Display remaining commodities (skip the first one which was already shown)
 	for i := 1; i < len(amounts); i++ {
-		amount := amounts[i]
-		amountStr := ""
-		if amount.Commodity.Symbol == "$" {
-			amountStr = fmt.Sprintf("%.0f", amount.ToFloat64())
-		} else {
-			amountStr = amount.Format(true)
-		}
+		amountStr := c.formatAmount(amounts[i])
 		
 		fmt.Fprintf(os.Stdout, "%-9s %-20s %-30s %12s %12s\n", 
 			"", "", "", "", amountStr)
@@ -0,0 +1,162 @@
+package commands
+
+import (
+	"io"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/hirosato/gledger/adapters/outbound/filesystem"
+	"github.com/hirosato/gledger/application"
+)
+
+// loadJournal loads a journal from its text
+func loadJournal(t *testing.T, input string) *application.Journal {
+	t.Helper()
+	journal := application.NewJournal(filesystem.NewParserAdapter())
+	if err := journal.LoadFromReader(strings.NewReader(input)); err != nil {
+		t.Fatalf("Failed to load journal: %v", err)
+	}
+	return journal
+}
+
+// runCommand runs a command with the given command line and returns what it
+// wrote to stdout
+func runCommand(t *testing.T, execute func(*Arguments) error, commandLine string) string {
+	t.Helper()
+	args, err := ParseArguments(strings.Fields(commandLine))
+	if err != nil {
+		t.Fatalf("Failed to parse %q: %v", commandLine, err)
+	}
+
+	reader, writer, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout := os.Stdout
+	os.Stdout = writer
+	output := make(chan string)
+	go func() {
+		data, _ := io.ReadAll(reader)
+		output <- string(data)
+	}()
+
+	err = execute(args)
+	os.Stdout = stdout
+	writer.Close()
+	text := <-output
+	if err != nil {
+		t.Fatalf("%q failed: %v", commandLine, err)
+	}
+	return text
+}
+
+// registerColumns returns the account, amount and running total columns of
+// register output, one "account amount total" string per line
+func registerColumns(output string) []string {
+	var rows []string
+	for _, line := range strings.Split(strings.TrimRight(output, "\n"), "\n") {
+		if len(line) < 32 {
+			continue
+		}
+		rows = append(rows, strings.Join(strings.Fields(line[32:]), " "))
+	}
+	return rows
+}
+
+// collapseSpaces returns text with each run of spaces in its lines collapsed
+// to one, so that tests don't depend on column widths
+func collapseSpaces(text string) string {
+	lines := strings.Split(strings.TrimRight(text, "\n"), "\n")
+	for i, line := range lines {
+		lines[i] = strings.Join(strings.Fields(line), " ")
+	}
+	return strings.Join(lines, "\n")
+}
+
+const registerJournal = `2012-01-05 * Grocery
+    Expenses:Food                $10
+    Assets:Cash
+
+2012-01-20 * Restaurant
+    Expenses:Food                $20
+    Assets:Cash
+
+2012-02-03 * Rent
+    Expenses:Rent               $500
+    Assets:Checking
+
+2012-04-10 * Grocery
+    Expenses:Food                 $5
+    Assets:Cash
+`
+
+func TestRegisterRunningTotal(t *testing.T) {
+	journal := loadJournal(t, registerJournal)
+
+	tests := []struct {
+		args     string
+		expected []string
+	}{
+		// The running total only adds up the postings shown
+		{"Food", []string{"Expenses:Food $10 $10", "Expenses:Food $20 $30", "Expenses:Food $5 $35"}},
+		{"Cash", []string{"Assets:Cash $-10 $-10", "Assets:Cash $-20 $-30", "Assets:Cash $-5 $-35"}},
+		{"Food --collapse", []string{"<Total> $10 $10", "<Total> $20 $30", "<Total> $5 $35"}},
+		{"Food -e 2012/02/01", []string{"Expenses:Food $10 $10", "Expenses:Food $20 $30"}},
+	}
+
+	for _, test := range tests {
+		output := runCommand(t, NewRegisterCommand(journal).Execute, test.args)
+		if got := registerColumns(output); strings.Join(got, "\n") != strings.Join(test.expected, "\n") {
+			t.Errorf("reg %s: Expected\n%s\ngot\n%s", test.args, strings.Join(test.expected, "\n"), output)
+		}
+	}
+}
+
+func TestRegisterPeriods(t *testing.T) {
+	journal := loadJournal(t, registerJournal)
+
+	tests := []struct {
+		args     string
+		expected string // with spaces collapsed
+	}{
+		{"Expenses --monthly", `12-Jan-01 - 12-Jan-31 Expenses:Food $30 $30
+12-Feb-01 - 12-Feb-29 Expenses:Rent $500 $530
+12-Apr-01 - 12-Apr-30 Expenses:Food $5 $535`},
+		// Empty periods within the postings' range get a row of their own
+		{"Expenses --monthly --empty", `12-Jan-01 - 12-Jan-31 Expenses:Food $30 $30
+12-Feb-01 - 12-Feb-29 Expenses:Rent $500 $530
+12-Mar-01 - 12-Mar-31 0 $530
+12-Apr-01 - 12-Apr-30 Expenses:Food $5 $535`},
+		{"Food Cash --monthly --collapse", `12-Jan-01 - 12-Jan-31 <Total> 0 0
+12-Apr-01 - 12-Apr-30 <Total> 0 0`},
+		{"--subtotal", `12-Jan-05 - 12-Apr-10 Assets:Cash $-35 $-35
+Assets:Checking $-500 $-535
+Expenses:Food $35 $-500
+Expenses:Rent $500 0`},
+		{"Expenses --yearly --period-sort -amount", `12-Jan-01 - 12-Dec-31 Expenses:Rent $500 $500
+Expenses:Food $35 $535`},
+	}
+
+	for _, test := range tests {
+		output := runCommand(t, NewRegisterCommand(journal).Execute, test.args)
+		if got := collapseSpaces(output); got != test.expected {
+			t.Errorf("reg %s: Expected\n%s\ngot\n%s", test.args, test.expected, output)
+		}
+	}
+}
+
+func TestRegisterRejectsGains(t *testing.T) {
+	journal := loadJournal(t, registerJournal)
+
+	for _, commandLine := range []string{"Food --gain", "-G", "--unrealized"} {
+		args, err := ParseArguments(strings.Fields(commandLine))
+		if err != nil {
+			t.Fatalf("Failed to parse %q: %v", commandLine, err)
+		}
+		err = NewRegisterCommand(journal).Execute(args)
+		if err == nil || !strings.Contains(err.Error(), "is not supported by register") {
+			t.Errorf("reg %s: Expected an unsupported option error, got %v", commandLine, err)
+		}
+	}
+}
@@ -21,27 +21,8 @@ func NewStatsCommand(journal *application.Journal) *StatsCommand {
 }
 
 // Execute runs the stats command
-func (c *StatsCommand) Execute(args []string) error {
-	// Parse command-line arguments for --now flag
-	now := time.Now()
-	
-	for i := 0; i < len(args); i++ {
-		if args[i] == "--now" && i+1 < len(args) {
-			// Parse the date string
-			dateStr := args[i+1]
-			parsedDate, err := time.Parse("2006-01-02", dateStr)
-			if err != nil {
-				// Try other date formats
-				parsedDate, err = time.Parse("2006/01/02", dateStr)
-				if err != nil {
-					return fmt.Errorf("invalid date format for --now: %s", dateStr)
-				}
-			}
-			now = parsedDate
-			// Skip the next argument as it's the date value
-			i++
-		}
-	}
+func (c *StatsCommand) Execute(args *Arguments) error {
+	now := c.journal.Now()
 	
 	transactions := c.journal.GetTransactions()
 	
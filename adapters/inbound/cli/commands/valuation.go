@@ -0,0 +1,53 @@
+package commands
+
+import (
+	"github.com/hirosato/gledger/application"
+	"github.com/hirosato/gledger/domain"
+)
+
+// ValuationOptions represents the valuation options shared by commands
+type ValuationOptions struct {
+	Basis      bool   // -B, --basis: report amounts at their cost
+	Market     bool   // -V, --market: report amounts at their market value
+	Exchange   string // -X, --exchange COMMODITY: report market values in COMMODITY
+	Historical bool   // -H, --historical: value amounts at their posting date
+}
+
+// applyValuationOption sets a valuation option, returning whether the option
+// was one
+func (o *ValuationOptions) applyValuationOption(option ParsedOption) bool {
+	switch option.Name {
+	case "basis":
+		o.Basis = true
+	case "market":
+		o.Market = true
+	case "historical":
+		o.Historical = true
+	case "exchange":
+		o.Exchange = option.Value
+	default:
+		return false
+	}
+	return true
+}
+
+// apply returns a view of the journal with amounts valued as requested. Market
+// values use the prices at the end of the report period, or at now.
+func (o *ValuationOptions) apply(journal *application.Journal, period *domain.Period) *application.Journal {
+	valuation := application.Valuation{Historical: o.Historical}
+	switch {
+	case o.Exchange != "":
+		valuation.Mode = application.ValueExchange
+		valuation.Commodity = o.Exchange
+	case o.Market:
+		valuation.Mode = application.ValueMarket
+	case o.Basis:
+		valuation.Mode = application.ValueBasis
+	}
+
+	date := journal.Now()
+	if period.End != nil {
+		date = period.End.AddDate(0, 0, -1)
+	}
+	return journal.Valued(valuation, date)
+}
@@ -0,0 +1,50 @@
+package presenters
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/hirosato/gledger/application/dto"
+)
+
+// BudgetPresenter formats budget reports for CLI output
+type BudgetPresenter struct{}
+
+// NewBudgetPresenter creates a new budget presenter
+func NewBudgetPresenter() *BudgetPresenter {
+	return &BudgetPresenter{}
+}
+
+// Present formats a budget report for display. Each period gets a heading
+// when the report is split into several.
+func (bp *BudgetPresenter) Present(report *dto.BudgetReport) string {
+	var output strings.Builder
+
+	for i, period := range report.Periods {
+		if len(report.Periods) > 1 {
+			if i > 0 {
+				output.WriteString("\n")
+			}
+			output.WriteString(fmt.Sprintf("%s - %s\n", period.Begin, period.End))
+		}
+
+		for _, line := range period.Lines {
+			output.WriteString(bp.formatLine(line))
+		}
+
+		// Add total line if present
+		if period.Total != nil {
+			output.WriteString(fmt.Sprintf("%s %s %s %s\n",
+				strings.Repeat("-", 12), strings.Repeat("-", 12), strings.Repeat("-", 12), strings.Repeat("-", 5)))
+			output.WriteString(bp.formatLine(*period.Total))
+		}
+	}
+
+	return output.String()
+}
+
+// formatLine formats one line: actual, budget, difference, percent, account
+func (bp *BudgetPresenter) formatLine(line dto.BudgetLine) string {
+	return strings.TrimRight(fmt.Sprintf("%12s %12s %12s %5s  %s",
+		line.Actual, line.Budget, line.Difference, line.Percent, line.Account), " ") + "\n"
+}
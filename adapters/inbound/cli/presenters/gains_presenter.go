@@ -0,0 +1,59 @@
+package presenters
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/hirosato/gledger/application/dto"
+)
+
+// GainsPresenter formats capital gains reports for CLI output
+type GainsPresenter struct{}
+
+// NewGainsPresenter creates a new gains presenter
+func NewGainsPresenter() *GainsPresenter {
+	return &GainsPresenter{}
+}
+
+// Present formats a gains report, realized gains first, each section under
+// a heading
+func (gp *GainsPresenter) Present(report *dto.GainsReport) string {
+	var output strings.Builder
+
+	sections := []struct {
+		title string
+		lines []dto.GainLine
+		total *dto.GainLine
+	}{
+		{"Realized gains", report.Realized, report.RealizedTotal},
+		{"Unrealized gains", report.Unrealized, report.UnrealizedTotal},
+	}
+	for _, section := range sections {
+		if len(section.lines) == 0 {
+			continue
+		}
+		if output.Len() > 0 {
+			output.WriteString("\n")
+		}
+		output.WriteString(section.title + "\n")
+
+		for _, line := range section.lines {
+			output.WriteString(gp.formatLine(line))
+		}
+
+		// Add total line if present
+		if section.total != nil {
+			output.WriteString(strings.Repeat(" ", 60) + strings.Repeat("-", 38) + "\n")
+			output.WriteString(gp.formatLine(*section.total))
+		}
+	}
+
+	return output.String()
+}
+
+// formatLine formats one line: date, acquisition date, quantity, account,
+// cost, value and gain
+func (gp *GainsPresenter) formatLine(line dto.GainLine) string {
+	return strings.TrimRight(fmt.Sprintf("%-10s %-10s %12s  %-22s %12s %12s %12s",
+		line.Date, line.Acquired, line.Quantity, truncate(line.Account, 22), line.Cost, line.Value, line.Gain), " ") + "\n"
+}
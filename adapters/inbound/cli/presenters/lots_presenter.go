@@ -0,0 +1,64 @@
+package presenters
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/hirosato/gledger/application/dto"
+)
+
+// LotsPresenter formats lots reports for CLI output
+type LotsPresenter struct {
+	prices bool
+	dates  bool
+	notes  bool
+}
+
+// NewLotsPresenter creates a new lots presenter. Each lot's quantity is
+// annotated ledger-style with its {price}, [date] and (note) as requested.
+func NewLotsPresenter(prices, dates, notes bool) *LotsPresenter {
+	return &LotsPresenter{
+		prices: prices,
+		dates:  dates,
+		notes:  notes,
+	}
+}
+
+// Present formats a lots report for display
+func (lp *LotsPresenter) Present(report *dto.LotsReport) string {
+	var output strings.Builder
+
+	for _, lot := range report.Lots {
+		output.WriteString(lp.formatLine(lp.annotate(lot), lot.Account, lot.Cost, lot.Value, lot.Held))
+	}
+
+	// Add total line if present
+	if report.Total != nil {
+		output.WriteString(strings.Repeat(" ", 66) + strings.Repeat("-", 26) + "\n")
+		output.WriteString(lp.formatLine("", "", report.Total.Cost, report.Total.Value, ""))
+	}
+
+	return output.String()
+}
+
+// annotate returns the lot's quantity with the requested annotations
+func (lp *LotsPresenter) annotate(lot dto.LotLine) string {
+	quantity := lot.Quantity
+	if lp.prices {
+		quantity += " {" + lot.Price + "}"
+	}
+	if lp.dates {
+		quantity += " [" + lot.Acquired + "]"
+	}
+	if lp.notes && lot.Label != "" {
+		quantity += " (" + lot.Label + ")"
+	}
+	return quantity
+}
+
+// formatLine formats one line: quantity, account, cost basis, market value
+// and holding period
+func (lp *LotsPresenter) formatLine(quantity, account, cost, value, held string) string {
+	return strings.TrimRight(fmt.Sprintf("%-40s %-25s %12s %12s  %s",
+		quantity, truncate(account, 25), cost, value, held), " ") + "\n"
+}
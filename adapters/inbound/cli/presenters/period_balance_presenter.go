@@ -0,0 +1,89 @@
+package presenters
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/hirosato/gledger/application/dto"
+)
+
+// PeriodBalancePresenter formats multi-period balance reports as a table
+type PeriodBalancePresenter struct{}
+
+// NewPeriodBalancePresenter creates a new multi-period balance presenter
+func NewPeriodBalancePresenter() *PeriodBalancePresenter {
+	return &PeriodBalancePresenter{}
+}
+
+// Present formats a multi-period balance report with accounts as rows and
+// periods as columns. Columns are as wide as their widest cell.
+func (pp *PeriodBalancePresenter) Present(report *dto.PeriodBalanceReport) string {
+	if len(report.Rows) == 0 {
+		return ""
+	}
+
+	headings := append([]string{}, report.Periods...)
+	if report.RowTotal {
+		headings = append(headings, "Total")
+	}
+	if report.Average {
+		headings = append(headings, "Average")
+	}
+
+	rows := report.Rows
+	if report.Total != nil {
+		rows = append(append([]dto.PeriodBalanceRow{}, rows...), *report.Total)
+	}
+
+	// Size the account column and every amount column
+	accountWidth := 0
+	widths := make([]int, len(headings))
+	for i, heading := range headings {
+		widths[i] = len(heading)
+	}
+	for _, row := range rows {
+		accountWidth = max(accountWidth, len(row.Account))
+		for i, cell := range pp.cells(report, row) {
+			widths[i] = max(widths[i], len(cell))
+		}
+	}
+
+	var output strings.Builder
+	output.WriteString(pp.formatLine(accountWidth, widths, "", headings))
+	for _, row := range report.Rows {
+		output.WriteString(pp.formatLine(accountWidth, widths, row.Account, pp.cells(report, row)))
+	}
+
+	// Add total line if present
+	if report.Total != nil {
+		rules := make([]string, len(widths))
+		for i, width := range widths {
+			rules[i] = strings.Repeat("-", width)
+		}
+		output.WriteString(pp.formatLine(accountWidth, widths, strings.Repeat("-", accountWidth), rules))
+		output.WriteString(pp.formatLine(accountWidth, widths, "", pp.cells(report, *report.Total)))
+	}
+
+	return output.String()
+}
+
+// cells returns a row's balances followed by its total and average columns
+func (pp *PeriodBalancePresenter) cells(report *dto.PeriodBalanceReport, row dto.PeriodBalanceRow) []string {
+	cells := append([]string{}, row.Balances...)
+	if report.RowTotal {
+		cells = append(cells, row.Total)
+	}
+	if report.Average {
+		cells = append(cells, row.Average)
+	}
+	return cells
+}
+
+// formatLine formats the account, left-aligned, and right-aligned cells
+func (pp *PeriodBalancePresenter) formatLine(accountWidth int, widths []int, account string, cells []string) string {
+	line := fmt.Sprintf("%-*s", accountWidth, account)
+	for i, cell := range cells {
+		line += fmt.Sprintf("  %*s", widths[i], cell)
+	}
+	return strings.TrimRight(line, " ") + "\n"
+}
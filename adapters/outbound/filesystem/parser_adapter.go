@@ -13,10 +13,13 @@ type ParserAdapter struct {
 	parser *parser.Parser
 }
 
-// NewParserAdapter creates a new parser adapter
+// NewParserAdapter creates a new parser adapter. Its parser recovers from
+// errors, so a journal's errors are all reported at once.
 func NewParserAdapter() ports.Parser {
+	journalParser := parser.NewParser()
+	journalParser.SetRecovery(true)
 	return &ParserAdapter{
-		parser: parser.NewParser(),
+		parser: journalParser,
 	}
 }
 
@@ -29,9 +32,15 @@ func (p *ParserAdapter) Parse(reader io.Reader) ([]domain.Transaction, []domain.
 	
 	// Return the parsed data
 	transactions := p.parser.GetTransactions()
-	// TODO: The current parser doesn't support directives yet
-	// This will need to be implemented in the parser
-	directives := []domain.Directive{}
+	directives := p.parser.GetDirectives()
 	
 	return transactions, directives, nil
-}
\ No newline at end of file
+}
+
+// ParseFiles implements the Parser interface
+func (p *ParserAdapter) ParseFiles(paths []string) ([]domain.Transaction, []domain.Directive, error) {
+	if err := p.parser.ParseFiles(paths); err != nil {
+		return nil, nil, err
+	}
+	return p.parser.GetTransactions(), p.parser.GetDirectives(), nil
+}
@@ -0,0 +1,171 @@
+package formatters
+
+import (
+	"encoding/csv"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/hirosato/gledger/domain/ports"
+)
+
+// CSVFormatter formats report DTOs as CSV with a header row. Each element of
+// the report's list becomes a row, and its optional total line the last row.
+// Nested lists, such as a transaction's postings, are flattened into one row
+// per nested element repeating the outer columns.
+type CSVFormatter struct{}
+
+// NewCSVFormatter creates a new CSV formatter
+func NewCSVFormatter() ports.Formatter {
+	return &CSVFormatter{}
+}
+
+// Format implements the Formatter interface
+func (f *CSVFormatter) Format(data interface{}) (string, error) {
+	report := reflect.Indirect(reflect.ValueOf(data))
+	if report.Kind() != reflect.Struct {
+		return "", fmt.Errorf("cannot format %T as CSV", data)
+	}
+
+	var header []string
+	var rows [][]string
+	for i := 0; i < report.NumField(); i++ {
+		field := report.Field(i)
+		if _, ok := columnName(report.Type().Field(i)); !ok {
+			continue
+		}
+		switch {
+		case field.Kind() == reflect.Slice && field.Type().Elem().Kind() == reflect.Struct:
+			if header == nil {
+				header = columns(field.Type().Elem())
+			}
+			for j := 0; j < field.Len(); j++ {
+				rows = append(rows, flatten(field.Index(j))...)
+			}
+		case field.Kind() == reflect.Ptr && field.Type().Elem().Kind() == reflect.Struct:
+			if !field.IsNil() {
+				rows = append(rows, flatten(field.Elem())...)
+			}
+		}
+	}
+	if header == nil {
+		return "", fmt.Errorf("cannot format %T as CSV", data)
+	}
+
+	var output strings.Builder
+	writer := csv.NewWriter(&output)
+	if err := writer.Write(header); err != nil {
+		return "", err
+	}
+	if err := writer.WriteAll(rows); err != nil {
+		return "", err
+	}
+	return output.String(), nil
+}
+
+// columns returns the column names of a row type, with the columns of a
+// nested list after its own. A nested column named like one of the row's own
+// replaces it, as a posting's note does its transaction's.
+func columns(row reflect.Type) []string {
+	var names, nested []string
+	for i := 0; i < row.NumField(); i++ {
+		name, ok := columnName(row.Field(i))
+		if !ok {
+			continue
+		}
+		fieldType := row.Field(i).Type
+		if fieldType.Kind() == reflect.Slice && fieldType.Elem().Kind() == reflect.Struct {
+			nested = columns(fieldType.Elem())
+			continue
+		}
+		names = append(names, name)
+	}
+	if nested == nil {
+		return names
+	}
+
+	replaced := make(map[string]bool, len(nested))
+	for _, name := range nested {
+		replaced[name] = true
+	}
+	var own []string
+	for _, name := range names {
+		if !replaced[name] {
+			own = append(own, name)
+		}
+	}
+	return append(own, nested...)
+}
+
+// flatten returns the rows for a value: a single row, or one row per element
+// of its nested list. Nested columns left empty take the value of the row's
+// own column they replace.
+func flatten(row reflect.Value) [][]string {
+	var names, values []string
+	var nested *reflect.Value
+	for i := 0; i < row.NumField(); i++ {
+		name, ok := columnName(row.Type().Field(i))
+		if !ok {
+			continue
+		}
+		field := row.Field(i)
+		if field.Kind() == reflect.Slice && field.Type().Elem().Kind() == reflect.Struct {
+			nested = &field
+			continue
+		}
+		names = append(names, name)
+		values = append(values, fmt.Sprint(field.Interface()))
+	}
+	if nested == nil {
+		return [][]string{values}
+	}
+
+	inner := columns(nested.Type().Elem())
+	replaced := make(map[string]string)
+	for _, name := range inner {
+		replaced[name] = ""
+	}
+	var own []string
+	for k, name := range names {
+		if _, ok := replaced[name]; ok {
+			replaced[name] = values[k]
+			continue
+		}
+		own = append(own, values[k])
+	}
+
+	innerRows := [][]string{make([]string, len(inner))}
+	if nested.Len() > 0 {
+		innerRows = nil
+		for j := 0; j < nested.Len(); j++ {
+			innerRows = append(innerRows, flatten(nested.Index(j))...)
+		}
+	}
+
+	var rows [][]string
+	for _, innerRow := range innerRows {
+		for k, name := range inner {
+			if innerRow[k] == "" {
+				innerRow[k] = replaced[name]
+			}
+		}
+		rows = append(rows, append(append([]string{}, own...), innerRow...))
+	}
+	return rows
+}
+
+// columnName returns a field's column name from its json tag, and whether
+// the field is exported and not skipped
+func columnName(field reflect.StructField) (string, bool) {
+	if field.PkgPath != "" {
+		return "", false
+	}
+	name := strings.Split(field.Tag.Get("json"), ",")[0]
+	if name == "-" {
+		return "", false
+	}
+	if name == "" {
+		name = field.Name
+	}
+	return name, true
+}
@@ -0,0 +1,23 @@
+package formatters
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/hirosato/gledger/domain/ports"
+)
+
+// NewFormatter returns the formatter for an output format name: json, csv
+// or xml
+func NewFormatter(format string) (ports.Formatter, error) {
+	switch strings.ToLower(format) {
+	case "json":
+		return NewJSONFormatter(), nil
+	case "csv":
+		return NewCSVFormatter(), nil
+	case "xml":
+		return NewXMLFormatter(), nil
+	default:
+		return nil, fmt.Errorf("unknown output format: %s", format)
+	}
+}
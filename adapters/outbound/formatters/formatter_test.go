@@ -0,0 +1,164 @@
+package formatters
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/hirosato/gledger/application/dto"
+)
+
+var transactionList = &dto.TransactionList{
+	Transactions: []dto.TransactionInfo{
+		{
+			Date:  "2012/01/05",
+			Payee: "Grocery",
+			Note:  "weekly",
+			Postings: []dto.PostingInfo{
+				{Account: "Expenses:Food", Amount: "$10.00", Note: "milk"},
+				{Account: "Assets:Cash", Amount: "$-10.00"},
+			},
+		},
+		{
+			Date:     "2012/01/06",
+			Status:   "cleared",
+			Payee:    "Shell, Inc.",
+			Postings: []dto.PostingInfo{},
+		},
+	},
+}
+
+var balanceReport = &dto.BalanceReport{
+	Accounts: []dto.AccountBalance{
+		{Name: "Assets:Cash", Balance: "$-10.00"},
+		{Name: "Expenses:Food", Balance: "$10.00"},
+	},
+	Total: &dto.AccountBalance{Balance: "0", IsTotal: true},
+}
+
+func TestCSVFormatter(t *testing.T) {
+	tests := []struct {
+		name     string
+		data     interface{}
+		expected string
+	}{
+		{
+			// Postings flatten into one row each, repeating the transaction's
+			// columns; a posting without a note shows the transaction's
+			name: "nested postings",
+			data: transactionList,
+			expected: `date,auxDate,status,code,payee,account,amount,cost,price,note
+2012/01/05,,,,Grocery,Expenses:Food,$10.00,,,milk
+2012/01/05,,,,Grocery,Assets:Cash,$-10.00,,,weekly
+2012/01/06,,cleared,,"Shell, Inc.",,,,,
+`,
+		},
+		{
+			name: "total row",
+			data: balanceReport,
+			expected: `name,balance,level,isTotal,isEmpty
+Assets:Cash,$-10.00,0,false,false
+Expenses:Food,$10.00,0,false,false
+,0,0,true,false
+`,
+		},
+		{
+			name: "without total",
+			data: &dto.BalanceReport{Accounts: balanceReport.Accounts},
+			expected: `name,balance,level,isTotal,isEmpty
+Assets:Cash,$-10.00,0,false,false
+Expenses:Food,$10.00,0,false,false
+`,
+		},
+		{
+			name: "empty list",
+			data: &dto.RegisterReport{Entries: []dto.RegisterEntry{}},
+			expected: `date,payee,account,amount,runningTotal,note
+`,
+		},
+	}
+
+	for _, test := range tests {
+		got, err := NewCSVFormatter().Format(test.data)
+		if err != nil {
+			t.Errorf("%s: Unexpected error: %v", test.name, err)
+			continue
+		}
+		if got != test.expected {
+			t.Errorf("%s: Expected\n%s\ngot\n%s", test.name, test.expected, got)
+		}
+	}
+
+	for _, data := range []interface{}{"text", struct{ Name string }{"no list"}} {
+		if _, err := NewCSVFormatter().Format(data); err == nil {
+			t.Errorf("Expected an error formatting %#v as CSV", data)
+		}
+	}
+}
+
+func TestJSONFormatter(t *testing.T) {
+	output, err := NewJSONFormatter().Format(transactionList)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	var parsed dto.TransactionList
+	if err := json.Unmarshal([]byte(output), &parsed); err != nil {
+		t.Fatalf("Failed to parse the JSON output: %v", err)
+	}
+	if !reflect.DeepEqual(&parsed, transactionList) {
+		t.Errorf("Expected the JSON to hold %+v, got %+v", transactionList, parsed)
+	}
+
+	// Empty optional fields are left out
+	if strings.Contains(output, "auxDate") {
+		t.Errorf("Expected no empty auxDate in\n%s", output)
+	}
+
+	output, err = NewJSONFormatter().Format(&dto.BalanceReport{Accounts: balanceReport.Accounts})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if strings.Contains(output, `"total"`) {
+		t.Errorf("Expected no total in\n%s", output)
+	}
+}
+
+func TestXMLFormatter(t *testing.T) {
+	output, err := NewXMLFormatter().Format(balanceReport)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !strings.HasPrefix(output, xml.Header+"<BalanceReport>") {
+		t.Errorf("Expected a BalanceReport root element, got\n%s", output)
+	}
+
+	var parsed dto.BalanceReport
+	if err := xml.Unmarshal([]byte(output), &parsed); err != nil {
+		t.Fatalf("Failed to parse the XML output: %v", err)
+	}
+	if !reflect.DeepEqual(&parsed, balanceReport) {
+		t.Errorf("Expected the XML to hold %+v, got %+v", balanceReport, parsed)
+	}
+
+	output, err = NewXMLFormatter().Format(transactionList)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if strings.Count(output, "<posting>") != 2 || !strings.Contains(output, "<note>milk</note>") {
+		t.Errorf("Expected the postings nested in their transaction, got\n%s", output)
+	}
+}
+
+func TestNewFormatter(t *testing.T) {
+	for _, format := range []string{"json", "CSV", "xml"} {
+		if _, err := NewFormatter(format); err != nil {
+			t.Errorf("Expected a formatter for %s, got %v", format, err)
+		}
+	}
+	if _, err := NewFormatter("yaml"); err == nil {
+		t.Errorf("Expected an error for an unknown format")
+	}
+}
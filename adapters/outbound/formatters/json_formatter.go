@@ -0,0 +1,24 @@
+package formatters
+
+import (
+	"encoding/json"
+
+	"github.com/hirosato/gledger/domain/ports"
+)
+
+// JSONFormatter formats report DTOs as indented JSON
+type JSONFormatter struct{}
+
+// NewJSONFormatter creates a new JSON formatter
+func NewJSONFormatter() ports.Formatter {
+	return &JSONFormatter{}
+}
+
+// Format implements the Formatter interface
+func (f *JSONFormatter) Format(data interface{}) (string, error) {
+	output, err := json.MarshalIndent(data, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return string(output) + "\n", nil
+}
@@ -0,0 +1,25 @@
+package formatters
+
+import (
+	"encoding/xml"
+
+	"github.com/hirosato/gledger/domain/ports"
+)
+
+// XMLFormatter formats report DTOs as indented XML, with the DTO's type name
+// as the root element
+type XMLFormatter struct{}
+
+// NewXMLFormatter creates a new XML formatter
+func NewXMLFormatter() ports.Formatter {
+	return &XMLFormatter{}
+}
+
+// Format implements the Formatter interface
+func (f *XMLFormatter) Format(data interface{}) (string, error) {
+	output, err := xml.MarshalIndent(data, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return xml.Header + string(output) + "\n", nil
+}
@@ -0,0 +1,63 @@
+package application
+
+import (
+	"fmt"
+
+	"github.com/hirosato/gledger/domain"
+)
+
+// applyAutomatedTransactions adds the postings generated by each automated
+// transaction to every transaction with a matching posting
+func (j *Journal) applyAutomatedTransactions() error {
+	type rule struct {
+		automated *domain.AutomatedTransaction
+		matches   PostingPredicate
+	}
+
+	var rules []rule
+	for _, directive := range j.directives {
+		automated, ok := directive.(*domain.AutomatedTransaction)
+		if !ok {
+			continue
+		}
+		matches, err := j.CompileQuery([]string{automated.Predicate})
+		if err != nil {
+			return fmt.Errorf("automated transaction '%s': %w", automated.Predicate, err)
+		}
+		rules = append(rules, rule{automated, matches})
+	}
+	if len(rules) == 0 {
+		return nil
+	}
+
+	for i := range j.transactions {
+		tx := &j.transactions[i]
+
+		// Only the postings written in the journal can trigger a rule
+		original := append([]*domain.Posting(nil), tx.Postings...)
+		for _, r := range rules {
+			for _, posting := range original {
+				if posting.IsGenerated || !r.matches(posting) {
+					continue
+				}
+				for _, check := range r.automated.Checks {
+					if err := j.evaluateCheck(check, j.expressionContext(posting)); err != nil {
+						return err
+					}
+				}
+				for _, generated := range r.automated.Generate(posting) {
+					if generated.Amount == nil && generated.ExpressionAmount != "" {
+						amount, err := j.evaluateAmount(generated.ExpressionAmount, posting)
+						if err != nil {
+							return fmt.Errorf("automated transaction '%s': %w", r.automated.Predicate, err)
+						}
+						generated.Amount = amount
+					}
+					tx.AddPosting(generated)
+					j.registerAccount(generated.Account.FullName)
+				}
+			}
+		}
+	}
+	return nil
+}
@@ -2,14 +2,14 @@ package dto
 
 // AccountList represents a list of accounts
 type AccountList struct {
-	Accounts []AccountInfo
+	Accounts []AccountInfo `json:"accounts" xml:"account"`
 }
 
 // AccountInfo represents information about a single account
 type AccountInfo struct {
-	FullName string
-	Name     string
-	Level    int
-	Parent   string
-	HasTransactions bool
+	FullName string `json:"fullName" xml:"fullName"`
+	Name     string `json:"name" xml:"name"`
+	Level    int    `json:"level" xml:"level"`
+	Parent   string `json:"parent" xml:"parent"`
+	HasTransactions bool `json:"hasTransactions" xml:"hasTransactions"`
 }
\ No newline at end of file
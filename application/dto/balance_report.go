@@ -2,15 +2,15 @@ package dto
 
 // BalanceReport represents the result of a balance calculation
 type BalanceReport struct {
-	Accounts []AccountBalance
-	Total    *AccountBalance // Optional total line
+	Accounts []AccountBalance `json:"accounts" xml:"account"`
+	Total    *AccountBalance  `json:"total,omitempty" xml:"total,omitempty"` // Optional total line
 }
 
 // AccountBalance represents a single account's balance in the report
 type AccountBalance struct {
-	Name     string
-	Balance  string
-	Level    int  // Indentation level for hierarchical display
-	IsTotal  bool // Whether this is a total line
-	IsEmpty  bool // Whether this account has zero balance
+	Name     string `json:"name" xml:"name"`
+	Balance  string `json:"balance" xml:"balance"`
+	Level    int    `json:"level" xml:"level"`       // Indentation level for hierarchical display
+	IsTotal  bool   `json:"isTotal" xml:"isTotal"`   // Whether this is a total line
+	IsEmpty  bool   `json:"isEmpty" xml:"isEmpty"`   // Whether this account has zero balance
 }
\ No newline at end of file
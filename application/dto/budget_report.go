@@ -0,0 +1,24 @@
+package dto
+
+// BudgetReport represents actual versus budgeted amounts, per period
+type BudgetReport struct {
+	Periods []BudgetPeriod
+}
+
+// BudgetPeriod represents the budget lines for one reporting period
+type BudgetPeriod struct {
+	Begin string
+	End   string // Last day of the period
+	Lines []BudgetLine
+	Total *BudgetLine // Optional total line
+}
+
+// BudgetLine represents a single account's actual and budgeted amounts
+type BudgetLine struct {
+	Account    string
+	Actual     string
+	Budget     string
+	Difference string // Actual minus budget
+	Percent    string // Actual as a percentage of budget, or "na"
+	IsTotal    bool
+}
@@ -0,0 +1,11 @@
+package dto
+
+// CommodityList represents a list of commodities
+type CommodityList struct {
+	Commodities []CommodityInfo `json:"commodities" xml:"commodity"`
+}
+
+// CommodityInfo represents a single commodity
+type CommodityInfo struct {
+	Symbol string `json:"symbol" xml:"symbol"`
+}
@@ -0,0 +1,22 @@
+package dto
+
+// GainsReport represents realized gains from lot sales and unrealized gains
+// on the lots still held
+type GainsReport struct {
+	Realized        []GainLine
+	Unrealized      []GainLine
+	RealizedTotal   *GainLine // Optional total lines
+	UnrealizedTotal *GainLine
+}
+
+// GainLine represents the gain on a single lot, sold or held
+type GainLine struct {
+	Date     string // Sale date, or the report date for held lots
+	Acquired string // Lot acquisition date
+	Account  string
+	Quantity string
+	Cost     string // Cost basis of the quantity
+	Value    string // Sale proceeds, or market value for held lots
+	Gain     string
+	IsTotal  bool
+}
@@ -0,0 +1,20 @@
+package dto
+
+// LotsReport represents the open lots held, grouped by account and commodity
+type LotsReport struct {
+	Lots  []LotLine `json:"lots" xml:"lot"`
+	Total *LotLine  `json:"total,omitempty" xml:"total,omitempty"` // Optional total line
+}
+
+// LotLine represents a single open lot
+type LotLine struct {
+	Account  string `json:"account" xml:"account"`
+	Quantity string `json:"quantity" xml:"quantity"`
+	Price    string `json:"price" xml:"price"` // Unit cost
+	Cost     string `json:"cost" xml:"cost"`   // Cost basis of the lot
+	Acquired string `json:"acquired" xml:"acquired"`
+	Label    string `json:"label,omitempty" xml:"label,omitempty"`
+	Value    string `json:"value,omitempty" xml:"value,omitempty"` // Market value, empty when there's no price
+	Held     string `json:"held" xml:"held"`                       // Holding period, e.g. "45 days"
+	IsTotal  bool   `json:"isTotal" xml:"isTotal"`
+}
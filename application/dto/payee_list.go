@@ -0,0 +1,11 @@
+package dto
+
+// PayeeList represents a list of payees
+type PayeeList struct {
+	Payees []PayeeInfo `json:"payees" xml:"payee"`
+}
+
+// PayeeInfo represents a single payee
+type PayeeInfo struct {
+	Name string `json:"name" xml:"name"`
+}
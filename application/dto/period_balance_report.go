@@ -0,0 +1,19 @@
+package dto
+
+// PeriodBalanceReport represents account balances with one column per period
+type PeriodBalanceReport struct {
+	Periods  []string // Column headings, one per period
+	Rows     []PeriodBalanceRow
+	Total    *PeriodBalanceRow // Optional total line
+	RowTotal bool              // Whether rows carry a total column
+	Average  bool              // Whether rows carry an average column
+}
+
+// PeriodBalanceRow represents a single account's balances across the periods
+type PeriodBalanceRow struct {
+	Account  string
+	Balances []string // One per period
+	Total    string   // Change over the whole report
+	Average  string   // Average change per period, at display precision
+	IsTotal  bool
+}
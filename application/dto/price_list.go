@@ -0,0 +1,13 @@
+package dto
+
+// PriceList represents the price history of commodities
+type PriceList struct {
+	Prices []PriceInfo `json:"prices" xml:"price"`
+}
+
+// PriceInfo represents a single price of one commodity in another
+type PriceInfo struct {
+	Date      string `json:"date" xml:"date"`
+	Commodity string `json:"commodity" xml:"commodity"`
+	Price     string `json:"price" xml:"price"` // Unit price, e.g. "10.50 EUR"
+}
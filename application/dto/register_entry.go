@@ -2,16 +2,16 @@ package dto
 
 // RegisterReport represents the result of a register query
 type RegisterReport struct {
-	Entries      []RegisterEntry
-	RunningTotal string
+	Entries      []RegisterEntry `json:"entries" xml:"entry"`
+	RunningTotal string          `json:"runningTotal" xml:"runningTotal"`
 }
 
 // RegisterEntry represents a single entry in the register
 type RegisterEntry struct {
-	Date         string
-	Payee        string
-	Account      string
-	Amount       string
-	RunningTotal string
-	Note         string
+	Date         string `json:"date" xml:"date"`
+	Payee        string `json:"payee" xml:"payee"`
+	Account      string `json:"account" xml:"account"`
+	Amount       string `json:"amount" xml:"amount"`
+	RunningTotal string `json:"runningTotal" xml:"runningTotal"`
+	Note         string `json:"note,omitempty" xml:"note,omitempty"`
 }
\ No newline at end of file
@@ -0,0 +1,26 @@
+package dto
+
+// TransactionList represents transactions as written in the journal
+type TransactionList struct {
+	Transactions []TransactionInfo `json:"transactions" xml:"transaction"`
+}
+
+// TransactionInfo represents a single transaction
+type TransactionInfo struct {
+	Date     string        `json:"date" xml:"date"`
+	AuxDate  string        `json:"auxDate,omitempty" xml:"auxDate,omitempty"`
+	Status   string        `json:"status,omitempty" xml:"status,omitempty"` // "cleared", "pending" or empty
+	Code     string        `json:"code,omitempty" xml:"code,omitempty"`
+	Payee    string        `json:"payee" xml:"payee"`
+	Note     string        `json:"note,omitempty" xml:"note,omitempty"`
+	Postings []PostingInfo `json:"postings" xml:"posting"`
+}
+
+// PostingInfo represents a single posting of a transaction
+type PostingInfo struct {
+	Account string `json:"account" xml:"account"`
+	Amount  string `json:"amount" xml:"amount"`
+	Cost    string `json:"cost,omitempty" xml:"cost,omitempty"`   // Lot annotation, e.g. "{$10.00}"
+	Price   string `json:"price,omitempty" xml:"price,omitempty"` // Price annotation, e.g. "@ $10.00"
+	Note    string `json:"note,omitempty" xml:"note,omitempty"`
+}
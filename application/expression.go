@@ -0,0 +1,129 @@
+package application
+
+import (
+	"fmt"
+
+	"github.com/hirosato/gledger/domain"
+	"github.com/hirosato/gledger/domain/expr"
+)
+
+// ExpressionPredicate compiles a value expression into a posting predicate,
+// as used by the "expr" query term. Postings the expression can't be
+// evaluated against don't match.
+func (j *Journal) ExpressionPredicate(text string) (PostingPredicate, error) {
+	e, err := expr.Parse(text)
+	if err != nil {
+		return nil, err
+	}
+	return func(posting *domain.Posting) bool {
+		matches, err := e.Test(j.expressionContext(posting))
+		return err == nil && matches
+	}, nil
+}
+
+// expressionContext returns the context expressions are evaluated in against
+// a posting, with the journal's now and prices
+func (j *Journal) expressionContext(posting *domain.Posting) *expr.Context {
+	return &expr.Context{Posting: posting, Now: j.Now(), Prices: j.prices}
+}
+
+// Limit returns a view of the journal keeping only the postings a value
+// expression holds for, as --limit does. An expression that can't be
+// evaluated against a posting, such as one comparing amounts in different
+// commodities, is an error.
+func (j *Journal) Limit(text string) (*Journal, error) {
+	e, err := expr.Parse(text)
+	if err != nil {
+		return nil, err
+	}
+
+	var evalErr error
+	view := j.Filter(func(posting *domain.Posting) bool {
+		holds, err := e.Test(j.expressionContext(posting))
+		if err != nil && evalErr == nil {
+			evalErr = fmt.Errorf("cannot evaluate %s: %w", text, err)
+		}
+		return err == nil && holds
+	})
+	if evalErr != nil {
+		return nil, evalErr
+	}
+	return view, nil
+}
+
+// GetWarnings returns the warnings raised while loading, such as failed checks
+func (j *Journal) GetWarnings() []string {
+	return j.warnings
+}
+
+// applyChecks evaluates the journal's assert and check directives once it
+// is loaded, with the journal's total as "total"
+func (j *Journal) applyChecks() error {
+	ctx := j.expressionContext(nil)
+	ctx.Total = j.GetTotalBalance()
+	for _, directive := range j.directives {
+		if err := j.evaluateCheck(directive, ctx); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// evaluateCheck evaluates an assert or check directive. A failed assert is an
+// error, while a failed check is only recorded as a warning.
+func (j *Journal) evaluateCheck(directive domain.Directive, ctx *expr.Context) error {
+	var text string
+	isAssert := false
+	switch d := directive.(type) {
+	case *domain.AssertDirective:
+		text, isAssert = d.Expression, true
+	case *domain.CheckDirective:
+		text = d.Expression
+	default:
+		return nil
+	}
+
+	e, err := expr.Parse(text)
+	if err != nil {
+		return fmt.Errorf("%s: %w", directive.String(), err)
+	}
+	holds, err := e.Test(ctx)
+	if err != nil {
+		return fmt.Errorf("%s: %w", directive.String(), err)
+	}
+	if holds {
+		return nil
+	}
+
+	if isAssert {
+		return fmt.Errorf("assertion failed: %s", text)
+	}
+	j.warnings = append(j.warnings, "check failed: "+text)
+	return nil
+}
+
+// evaluateAmount evaluates an automated transaction's expression amount
+// against the posting that matched it
+func (j *Journal) evaluateAmount(text string, matched *domain.Posting) (*domain.Amount, error) {
+	e, err := expr.Parse(text)
+	if err != nil {
+		return nil, err
+	}
+	value, err := e.Eval(j.expressionContext(matched))
+	if err != nil {
+		return nil, fmt.Errorf("cannot evaluate %s: %w", text, err)
+	}
+	amount, err := value.Amount()
+	if err != nil {
+		return nil, fmt.Errorf("cannot evaluate %s: %w", text, err)
+	}
+
+	amount = amount.Copy()
+	if amount.Commodity.Symbol != "" {
+		amount.Commodity = j.registerCommodityIfAbsent(amount.Commodity)
+	} else if matched.Amount != nil {
+		// A bare number is in the matched posting's commodity
+		amount.Commodity = matched.Amount.Commodity
+	}
+	return amount, nil
+}
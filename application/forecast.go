@@ -0,0 +1,86 @@
+package application
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/hirosato/gledger/domain"
+)
+
+// DefaultForecastYears limits how far a forecast runs, as in ledger
+const DefaultForecastYears = 5
+
+// WithForecast returns a view of the journal whose transactions also include
+// the periodic transactions projected over [from, until). The journal itself
+// is unchanged, so forecast transactions never reach print or storage.
+func (j *Journal) WithForecast(from, until time.Time) *Journal {
+	var forecast []domain.Transaction
+	for _, template := range j.GetPeriodicTransactions() {
+		for _, date := range template.Occurrences(from, until) {
+			forecast = append(forecast, *template.Generate(date))
+		}
+	}
+
+	view := *j
+	view.transactions = append(append([]domain.Transaction(nil), j.transactions...), forecast...)
+	sort.SliceStable(view.transactions, func(a, b int) bool {
+		return view.transactions[a].Date.Before(view.transactions[b].Date)
+	})
+
+	// Generated transactions point their postings at the slice elements
+	for i := range view.transactions {
+		tx := &view.transactions[i]
+		if tx.IsGenerated {
+			for _, posting := range tx.Postings {
+				posting.Transaction = tx
+			}
+		}
+	}
+
+	return &view
+}
+
+// ForecastStart returns the date a forecast begins: the day after the last
+// transaction, or now if that is later
+func (j *Journal) ForecastStart(now time.Time) time.Time {
+	start := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	for _, tx := range j.transactions {
+		if next := tx.Date.AddDate(0, 0, 1); next.After(start) {
+			start = next
+		}
+	}
+	return start
+}
+
+// ParseForecastUntil parses a forecast condition such as "d<[2027]" or
+// "date<=[2027/06/30]" and returns the exclusive end date of the forecast.
+// Relative dates such as "next year" are relative to now.
+func ParseForecastUntil(condition string, now time.Time) (time.Time, error) {
+	// Spaces only matter within the bracketed date
+	text, date, _ := strings.Cut(condition, "[")
+	text = strings.ReplaceAll(text, " ", "")
+	if date != "" {
+		text += "[" + strings.TrimSpace(date)
+	}
+	text = strings.TrimPrefix(text, "date")
+	text = strings.TrimPrefix(text, "d")
+
+	inclusive := strings.HasPrefix(text, "<=")
+	text = strings.TrimPrefix(strings.TrimPrefix(text, "<="), "<")
+	if !strings.HasPrefix(text, "[") || !strings.HasSuffix(text, "]") {
+		return time.Time{}, fmt.Errorf("unsupported forecast condition: %s", condition)
+	}
+
+	period, err := domain.ParsePeriodAt(text[1:len(text)-1], now)
+	if err != nil || period.Begin == nil {
+		return time.Time{}, fmt.Errorf("invalid date in forecast condition: %s", condition)
+	}
+
+	until := *period.Begin
+	if inclusive {
+		until = until.AddDate(0, 0, 1)
+	}
+	return until, nil
+}
@@ -5,6 +5,7 @@ import (
 	"io"
 	"sort"
 	"strings"
+	"time"
 
 	"github.com/hirosato/gledger/domain"
 	"github.com/hirosato/gledger/domain/ports"
@@ -19,6 +20,9 @@ type Journal struct {
 	commodityRegistry map[string]*domain.Commodity
 	defaultCommodity  *domain.Commodity
 	parser            ports.Parser
+	warnings          []string
+	now               time.Time
+	prices            *PriceDB
 }
 
 // NewJournal creates a new empty journal with injected dependencies
@@ -30,6 +34,7 @@ func NewJournal(parser ports.Parser) *Journal {
 		directives:        []domain.Directive{},
 		commodityRegistry: make(map[string]*domain.Commodity),
 		parser:            parser,
+		prices:            NewPriceDB(),
 	}
 }
 
@@ -43,10 +48,35 @@ func (j *Journal) LoadFromReader(reader io.Reader) error {
 	if err != nil {
 		return fmt.Errorf("failed to parse journal: %w", err)
 	}
+	return j.load(transactions, directives)
+}
+
+// LoadFromFile loads journal data from the named file and the files it includes
+func (j *Journal) LoadFromFile(path string) error {
+	return j.LoadFromFiles([]string{path})
+}
+
+// LoadFromFiles loads journal data from the named files as one journal; the
+// path "-" reads standard input
+func (j *Journal) LoadFromFiles(paths []string) error {
+	if j.parser == nil {
+		return fmt.Errorf("parser not initialized")
+	}
+	
+	transactions, directives, err := j.parser.ParseFiles(paths)
+	if err != nil {
+		return fmt.Errorf("failed to parse journal: %w", err)
+	}
+	return j.load(transactions, directives)
+}
 
+// load builds the journal from parsed transactions and directives
+func (j *Journal) load(transactions []domain.Transaction, directives []domain.Directive) error {
 	// Store parsed data
 	j.transactions = transactions
-	j.directives = directives
+	j.directives = []domain.Directive{}
+	j.warnings = nil
+	j.prices = NewPriceDB()
 
 	// Build account tree and commodity registry from transactions
 	for _, tx := range j.transactions {
@@ -56,14 +86,41 @@ func (j *Journal) LoadFromReader(reader io.Reader) error {
 			}
 			// Register commodity from amount
 			if posting.Amount != nil && posting.Amount.Commodity != nil {
-				j.RegisterCommodity(posting.Amount.Commodity)
+				j.registerCommodityIfAbsent(posting.Amount.Commodity)
 			}
 		}
 	}
 
+	// Prices from transactions come first, so P directives win on the same day
+	j.recordTransactionPrices()
+
+	// Apply directives in file order
+	for _, directive := range directives {
+		j.AddDirective(directive)
+	}
+
+	// Add the postings generated by automated transactions
+	if err := j.applyAutomatedTransactions(); err != nil {
+		return err
+	}
+
+	// Evaluate assert and check directives against the loaded journal
+	if err := j.applyChecks(); err != nil {
+		return err
+	}
+
 	return nil
 }
 
+// registerCommodityIfAbsent registers a commodity unless one with the same symbol exists
+func (j *Journal) registerCommodityIfAbsent(commodity *domain.Commodity) *domain.Commodity {
+	if existing, exists := j.commodityRegistry[commodity.Symbol]; exists {
+		return existing
+	}
+	j.commodityRegistry[commodity.Symbol] = commodity
+	return commodity
+}
+
 // registerAccount registers an account and all its parent accounts
 func (j *Journal) registerAccount(fullName string) {
 	// Register this account and all parent accounts
@@ -107,33 +164,13 @@ func (j *Journal) GetAccounts() []string {
 	return accounts
 }
 
-// GetAccountsMatching returns accounts matching the given pattern
+// GetAccountsMatching returns the accounts of postings matching the given query
 func (j *Journal) GetAccountsMatching(pattern string) []string {
-	var matches []string
-	
-	pattern = strings.ToLower(pattern)
-	
-	// Only match accounts that are actually used in transactions
-	usedAccounts := make(map[string]bool)
-	for _, tx := range j.transactions {
-		for _, posting := range tx.Postings {
-			if posting.Account != nil {
-				usedAccounts[posting.Account.FullName] = true
-			}
-		}
-	}
-	
-	for name := range usedAccounts {
-		nameLower := strings.ToLower(name)
-		
-		// Simple substring matching for now
-		if strings.Contains(nameLower, pattern) {
-			matches = append(matches, name)
-		}
+	matches, err := j.CompileQuery([]string{pattern})
+	if err != nil {
+		return nil
 	}
-	
-	sort.Strings(matches)
-	return matches
+	return j.Filter(matches).GetAccounts()
 }
 
 // GetAccount returns a specific account by name
@@ -203,15 +240,44 @@ func (j *Journal) AddDirective(directive domain.Directive) {
 	
 	// Process directive effects
 	switch d := directive.(type) {
+	case *domain.AccountDirective:
+		j.registerAccount(d.Name)
+		if d.Note != "" {
+			j.accounts[d.Name].Note = d.Note
+		}
+
+	case *domain.AliasDirective:
+		j.registerAccount(d.Value)
+		j.accounts[d.Value].Alias = d.Name
+
 	case *domain.CommodityDirective:
-		commodity := domain.NewCommodity(d.Symbol)
-		commodity.Precision = d.Precision
-		commodity.Format = d.Format
-		j.commodityRegistry[d.Symbol] = commodity
+		commodity := j.registerCommodityIfAbsent(domain.NewCommodity(d.Symbol))
+		if d.Precision >= 0 {
+			commodity.Precision = d.Precision
+		}
+		if d.Format != "" {
+			commodity.Format = d.Format
+			if sample, err := domain.ParseAmount(d.Format); err == nil {
+				commodity.AdoptStyle(sample.Commodity)
+			}
+		}
+		if d.Note != "" {
+			commodity.Note = d.Note
+		}
+		if d.Alias != "" {
+			commodity.Alias = d.Alias
+		}
+		commodity.NoMarket = commodity.NoMarket || d.NoMarket
+		if d.Default {
+			j.SetDefaultCommodity(commodity)
+		}
 	
 	case *domain.PriceDirective:
-		if commodity, exists := j.commodityRegistry[d.Commodity]; exists {
-			commodity.AddPrice(d.Date, d.Price)
+		commodity := j.registerCommodityIfAbsent(domain.NewCommodity(d.Commodity))
+		commodity.AddPrice(d.Date, d.Price)
+		if d.Price != nil && d.Price.Commodity != nil {
+			j.registerCommodityIfAbsent(d.Price.Commodity)
+			j.prices.AddPrice(commodity, d.Date, d.Price)
 		}
 	}
 }
@@ -221,6 +287,17 @@ func (j *Journal) GetDirectives() []domain.Directive {
 	return j.directives
 }
 
+// GetPeriodicTransactions returns the periodic transaction templates in file order
+func (j *Journal) GetPeriodicTransactions() []*domain.PeriodicTransaction {
+	var periodic []*domain.PeriodicTransaction
+	for _, directive := range j.directives {
+		if pt, ok := directive.(*domain.PeriodicTransaction); ok {
+			periodic = append(periodic, pt)
+		}
+	}
+	return periodic
+}
+
 // GetCommodity gets a commodity from the registry
 func (j *Journal) GetCommodity(symbol string) (*domain.Commodity, bool) {
 	commodity, exists := j.commodityRegistry[symbol]
@@ -242,18 +319,23 @@ func (j *Journal) GetCommodities() []string {
 	return commodities
 }
 
-// GetCommoditiesForAccount returns commodities used in transactions affecting the given account
+// GetCommoditiesForAccount returns commodities used in postings matching the given query
 func (j *Journal) GetCommoditiesForAccount(accountPattern string) []string {
+	matches, err := j.CompileQuery([]string{accountPattern})
+	if err != nil {
+		return nil
+	}
+	return j.Filter(matches).GetUsedCommodities()
+}
+
+// GetUsedCommodities returns the commodities of all posting amounts, sorted
+func (j *Journal) GetUsedCommodities() []string {
 	commoditySet := make(map[string]bool)
 	
 	for _, tx := range j.transactions {
 		for _, posting := range tx.Postings {
-			// Check if this posting affects an account matching the pattern
-			if posting.Account != nil && j.matchesAccountPattern(posting.Account.FullName, accountPattern) {
-				// Add the commodity from this posting
-				if posting.Amount != nil && posting.Amount.Commodity != nil {
-					commoditySet[posting.Amount.Commodity.Symbol] = true
-				}
+			if posting.Amount != nil && posting.Amount.Commodity != nil {
+				commoditySet[posting.Amount.Commodity.Symbol] = true
 			}
 		}
 	}
@@ -266,11 +348,6 @@ func (j *Journal) GetCommoditiesForAccount(accountPattern string) []string {
 	return commodities
 }
 
-// matchesAccountPattern checks if an account name matches the pattern (simple substring match for now)
-func (j *Journal) matchesAccountPattern(accountName, pattern string) bool {
-	return strings.Contains(strings.ToLower(accountName), strings.ToLower(pattern))
-}
-
 // SetDefaultCommodity sets the default commodity
 func (j *Journal) SetDefaultCommodity(commodity *domain.Commodity) {
 	j.defaultCommodity = commodity
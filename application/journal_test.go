@@ -3,8 +3,11 @@ package application
 import (
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/hirosato/gledger/adapters/outbound/filesystem"
+	"github.com/hirosato/gledger/domain"
+	"github.com/hirosato/gledger/domain/expr"
 )
 
 func TestJournalBasicFunctionality(t *testing.T) {
@@ -120,4 +123,668 @@ func TestJournalAccountsMatching(t *testing.T) {
 			t.Errorf("Expected account '%s' not found in matches: %v", expected, matches)
 		}
 	}
-}
\ No newline at end of file
+}
+func TestJournalAppliesDirectives(t *testing.T) {
+	parser := filesystem.NewParserAdapter()
+	journal := NewJournal(parser)
+
+	input := `account Assets:Checking ; main account
+commodity CAD
+    format 1,000.000 CAD
+    default
+P 2012/03/16 CAD $2.50
+
+2012-03-17 * Deposit
+    Assets:Checking              10.000 CAD
+    Equity:Opening balance`
+
+	if err := journal.LoadFromReader(strings.NewReader(input)); err != nil {
+		t.Fatalf("Failed to load journal: %v", err)
+	}
+
+	if len(journal.GetDirectives()) != 3 {
+		t.Errorf("Expected 3 directives, got %d", len(journal.GetDirectives()))
+	}
+
+	account, exists := journal.GetAccount("Assets:Checking")
+	if !exists || account.Note != "main account" {
+		t.Errorf("Expected account note to be applied, got %+v", account)
+	}
+
+	commodity, exists := journal.GetCommodity("CAD")
+	if !exists {
+		t.Fatal("Expected CAD to be registered")
+	}
+	if commodity.Precision != 3 {
+		t.Errorf("Expected CAD precision 3, got %d", commodity.Precision)
+	}
+	if journal.GetDefaultCommodity() != commodity {
+		t.Error("Expected CAD to be the default commodity")
+	}
+	if price := commodity.GetLatestPrice("$"); price == nil || price.ToFloat64() != 2.50 {
+		t.Errorf("Expected CAD price of $2.50, got %v", price)
+	}
+}
+
+func TestJournalAppliesAutomatedTransactions(t *testing.T) {
+	parser := filesystem.NewParserAdapter()
+	journal := NewJournal(parser)
+
+	input := `= /^Expenses:Food/
+    (Budget:Food)                  -1
+    ($account:Tracked)            $1.00
+
+2011-01-01 * Groceries
+    Expenses:Food                  $5.00
+    Assets:Cash
+
+2011-01-02 * Rent
+    Expenses:Rent                $500.00
+    Assets:Cash`
+
+	if err := journal.LoadFromReader(strings.NewReader(input)); err != nil {
+		t.Fatalf("Failed to load journal: %v", err)
+	}
+
+	transactions := journal.GetTransactions()
+	if len(transactions[1].Postings) != 2 {
+		t.Errorf("Expected non-matching transaction to keep 2 postings, got %d", len(transactions[1].Postings))
+	}
+
+	postings := transactions[0].Postings
+	if len(postings) != 4 {
+		t.Fatalf("Expected 4 postings after automation, got %d", len(postings))
+	}
+
+	expected := []struct {
+		account string
+		amount  string
+	}{
+		{"Budget:Food", "$-5.00"},
+		{"Expenses:Food:Tracked", "$1.00"},
+	}
+	for i, want := range expected {
+		posting := postings[2+i]
+		if !posting.IsGenerated {
+			t.Errorf("Expected posting %d to be marked as generated", i)
+		}
+		if posting.Account.FullName != want.account {
+			t.Errorf("Expected account '%s', got '%s'", want.account, posting.Account.FullName)
+		}
+		if got := posting.Amount.Format(true); got != want.amount {
+			t.Errorf("Expected amount %s, got %s", want.amount, got)
+		}
+	}
+
+	balance := journal.GetBalance("Budget:Food")
+	if amount := balance.GetAmount("$"); amount == nil || amount.Format(true) != "$-5.00" {
+		t.Errorf("Expected Budget:Food balance of $-5.00, got %s", balance.String())
+	}
+}
+
+func TestJournalAutomatedMultipliersKeepStyle(t *testing.T) {
+	journal := NewJournal(filesystem.NewParserAdapter())
+
+	input := `= /^Expenses:Food/
+    (Savings)                    0.1
+    (Budget:Food)              -0.25
+
+2011-01-01 * Groceries
+    Expenses:Food                 $100
+    Assets:Cash`
+
+	if err := journal.LoadFromReader(strings.NewReader(input)); err != nil {
+		t.Fatalf("Failed to load journal: %v", err)
+	}
+
+	// Multipliers don't widen the precision of the default commodity
+	expected := []string{"$100", "$-100", "$10", "$-25"}
+	for i, posting := range journal.GetTransactions()[0].Postings {
+		if got := posting.Amount.Format(true); got != expected[i] {
+			t.Errorf("Expected amount %s, got %s", expected[i], got)
+		}
+	}
+}
+
+func TestJournalWithForecast(t *testing.T) {
+	parser := filesystem.NewParserAdapter()
+	journal := NewJournal(parser)
+
+	input := `~ Monthly
+    Expenses:Rent               $1000.00
+    Assets:Checking
+
+2012-01-01 * Opening
+    Assets:Checking             $5000.00
+    Equity:Opening`
+
+	if err := journal.LoadFromReader(strings.NewReader(input)); err != nil {
+		t.Fatalf("Failed to load journal: %v", err)
+	}
+
+	until, err := ParseForecastUntil("d<[2012/04]", time.Time{})
+	if err != nil {
+		t.Fatalf("Failed to parse forecast condition: %v", err)
+	}
+	from := journal.ForecastStart(time.Date(2011, time.June, 1, 0, 0, 0, 0, time.UTC))
+	if got := from.Format("2006-01-02"); got != "2012-01-02" {
+		t.Errorf("Expected forecast to start after the last transaction, got %s", got)
+	}
+
+	view := journal.WithForecast(from, until)
+	transactions := view.GetTransactions()
+	if len(transactions) != 3 {
+		t.Fatalf("Expected 1 actual and 2 forecast transactions, got %d", len(transactions))
+	}
+	for _, tx := range transactions[1:] {
+		if !tx.IsGenerated {
+			t.Errorf("Expected forecast transaction on %s to be marked as generated", tx.Date.Format("2006-01-02"))
+		}
+	}
+	if got := view.GetBalance("Assets:Checking").String(); got != "$3000.00" {
+		t.Errorf("Expected projected balance $3000.00, got %s", got)
+	}
+
+	if len(journal.GetTransactions()) != 1 {
+		t.Errorf("Expected the journal itself to keep 1 transaction, got %d", len(journal.GetTransactions()))
+	}
+
+	if _, err := ParseForecastUntil("amount > 10", time.Time{}); err == nil {
+		t.Errorf("Expected error for an unsupported forecast condition")
+	}
+
+	// Relative dates are relative to the configured now
+	now := time.Date(2012, time.February, 10, 0, 0, 0, 0, time.UTC)
+	until, err = ParseForecastUntil("d<[next month]", now)
+	if err != nil || until.Format("2006-01-02") != "2012-03-01" {
+		t.Errorf("Expected next month to begin on 2012-03-01, got %s (%v)", until.Format("2006-01-02"), err)
+	}
+}
+
+func TestCompileQuery(t *testing.T) {
+	parser := filesystem.NewParserAdapter()
+	journal := NewJournal(parser)
+
+	input := `2012-03-01 (101) * Grocery Store  ; :shopping:
+    Expenses:Food                   $20.00  ; Receipt: 42
+    Assets:Cash
+
+2012-03-02 * Landlord
+    ; monthly rent
+    Expenses:Rent                  $500.00
+    Assets:Bank:Checking`
+
+	if err := journal.LoadFromReader(strings.NewReader(input)); err != nil {
+		t.Fatalf("Failed to load journal: %v", err)
+	}
+
+	tests := []struct {
+		query    []string
+		expected []string
+	}{
+		{[]string{"food"}, []string{"Expenses:Food"}},
+		{[]string{"food", "rent"}, []string{"Expenses:Food", "Expenses:Rent"}},
+		{[]string{"^assets"}, []string{"Assets:Bank:Checking", "Assets:Cash"}},
+		{[]string{"payee", "grocery"}, []string{"Assets:Cash", "Expenses:Food"}},
+		{[]string{"@landlord", "and", "not", "rent"}, []string{"Assets:Bank:Checking"}},
+		{[]string{"%shopping"}, []string{"Assets:Cash", "Expenses:Food"}},
+		{[]string{"tag", "receipt=42"}, []string{"Expenses:Food"}},
+		{[]string{"tag", "receipt=43"}, nil},
+		{[]string{"#101", "&", "cash"}, []string{"Assets:Cash"}},
+		{[]string{"=monthly"}, []string{"Assets:Bank:Checking", "Expenses:Rent"}},
+		{[]string{"expenses and not (food or @grocery)"}, []string{"Expenses:Rent"}},
+		{[]string{"/bank:checking/"}, []string{"Assets:Bank:Checking"}},
+		{[]string{"expr", "amount > $100"}, []string{"Expenses:Rent"}},
+		{[]string{"food", "or", "expr 'account =~ /^assets:bank/'"}, []string{"Assets:Bank:Checking", "Expenses:Food"}},
+		// "and" binds tighter than adjacent terms: food or (rent and payee landlord)
+		{[]string{"food", "rent", "and", "@landlord"}, []string{"Expenses:Food", "Expenses:Rent"}},
+		{[]string{"rent", "food", "and", "@landlord"}, []string{"Expenses:Rent"}},
+		{[]string{"cash", "or", "food", "and", "not", "@grocery"}, []string{"Assets:Cash"}},
+	}
+
+	for _, test := range tests {
+		matches, err := journal.CompileQuery(test.query)
+		if err != nil {
+			t.Errorf("Unexpected error compiling %v: %v", test.query, err)
+			continue
+		}
+		accounts := journal.Filter(matches).GetAccounts()
+		if strings.Join(accounts, ",") != strings.Join(test.expected, ",") {
+			t.Errorf("Query %v: expected %v, got %v", test.query, test.expected, accounts)
+		}
+	}
+
+	for _, query := range [][]string{{"(food"}, {"food", "and"}, {"payee"}, {"[unclosed"}, {"'quoted"}} {
+		if _, err := journal.CompileQuery(query); err == nil {
+			t.Errorf("Expected error compiling %v", query)
+		}
+	}
+}
+
+func TestJournalEvaluatesChecks(t *testing.T) {
+	input := `= /Food/
+    check amount < $50
+    (Budget:Food)                  (amount * -1)
+
+check total == 0
+
+2012-03-01 * Dinner
+    Expenses:Food                   $60.00
+    Assets:Cash`
+
+	journal := NewJournal(filesystem.NewParserAdapter())
+	if err := journal.LoadFromReader(strings.NewReader(input)); err != nil {
+		t.Fatalf("Failed to load journal: %v", err)
+	}
+
+	warnings := journal.GetWarnings()
+	if len(warnings) != 2 || warnings[0] != "check failed: amount < $50" || warnings[1] != "check failed: total == 0" {
+		t.Errorf("Expected two failed checks, got %v", warnings)
+	}
+	if got := journal.GetBalance("Budget:Food").String(); got != "$-60.00" {
+		t.Errorf("Expected generated budget posting of $-60.00, got %s", got)
+	}
+
+	journal = NewJournal(filesystem.NewParserAdapter())
+	err := journal.LoadFromReader(strings.NewReader("assert 2 + 2 == 5\n"))
+	if err == nil || !strings.Contains(err.Error(), "assertion failed: 2 + 2 == 5") {
+		t.Errorf("Expected failed assertion error, got %v", err)
+	}
+}
+
+func TestJournalReportPeriod(t *testing.T) {
+	input := `2025-03-05 March
+    Expenses:Food                   $10.00
+    Assets:Cash
+
+2025-04-05 April
+    Expenses:Food                   $20.00
+    Assets:Cash
+
+2025-05-05 May
+    Expenses:Food                   $40.00
+    Assets:Cash`
+
+	journal := NewJournal(filesystem.NewParserAdapter())
+	journal.SetNow(time.Date(2025, time.May, 14, 0, 0, 0, 0, time.UTC))
+	if err := journal.LoadFromReader(strings.NewReader(input)); err != nil {
+		t.Fatalf("Failed to load journal: %v", err)
+	}
+
+	tests := []struct {
+		begin, end, period string
+		expected           string
+	}{
+		{"", "", "last month", "$20.00"},
+		{"", "", "from 2025/03 to 2025/05", "$30.00"},
+		{"2025/04", "", "", "$60.00"},
+		{"", "2025/04", "this year", "$10.00"},
+		{"2025/04/05", "2025/05/05", "", "$20.00"},
+	}
+
+	for _, test := range tests {
+		period, err := journal.ReportPeriod(test.begin, test.end, test.period)
+		if err != nil {
+			t.Errorf("Unexpected error for %q %q %q: %v", test.begin, test.end, test.period, err)
+			continue
+		}
+		view := journal.Between(period.Begin, period.End)
+		if got := view.GetBalance("Expenses:Food").String(); got != test.expected {
+			t.Errorf("Expected %s for %q %q %q, got %s", test.expected, test.begin, test.end, test.period, got)
+		}
+	}
+
+	if _, err := journal.ReportPeriod("someday", "", ""); err == nil {
+		t.Errorf("Expected error for invalid begin date")
+	}
+
+	// Expressions compare dates with the configured now too
+	limit, err := journal.ExpressionPredicate("date < now")
+	if err != nil {
+		t.Fatalf("Failed to compile expression: %v", err)
+	}
+	matches, err := journal.CompileQuery([]string{"expr", "date >= now"})
+	if err != nil {
+		t.Fatalf("Failed to compile query: %v", err)
+	}
+	for _, tx := range journal.GetTransactions() {
+		past := tx.Date.Before(journal.Now())
+		if got := limit(tx.Postings[0]); got != past {
+			t.Errorf("Expected 'date < now' to be %v on %s", past, tx.Date.Format("2006-01-02"))
+		}
+		if got := matches(tx.Postings[0]); got == past {
+			t.Errorf("Expected 'expr date >= now' to be %v on %s", !past, tx.Date.Format("2006-01-02"))
+		}
+	}
+}
+
+func TestJournalLimit(t *testing.T) {
+	journal := NewJournal(filesystem.NewParserAdapter())
+	input := `2012-01-01 Lunch
+    Expenses:Food                   $10.00
+    Assets:Cash
+
+2012-01-02 Groceries
+    Expenses:Food                    $3.00
+    Assets:Cash`
+	if err := journal.LoadFromReader(strings.NewReader(input)); err != nil {
+		t.Fatalf("Failed to load journal: %v", err)
+	}
+
+	view, err := journal.Limit("amount > $5")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if got := view.GetBalance("Expenses:Food").String(); got != "$10.00" {
+		t.Errorf("Expected Expenses:Food of $10.00, got %s", got)
+	}
+
+	// Evaluation errors are reported rather than matching nothing
+	if _, err := journal.Limit("amount > 5 EUR"); err == nil {
+		t.Errorf("Expected an error comparing $ with EUR")
+	}
+	if _, err := journal.Limit("amount >"); err == nil {
+		t.Errorf("Expected an error for an invalid expression")
+	}
+}
+
+func TestJournalValued(t *testing.T) {
+	input := `P 2012-01-01 AAPL $10.00
+
+2012-01-05 Buy
+    Assets:Brokerage                10 AAPL @ $12.00
+    Assets:Cash
+
+2012-02-10 Buy more
+    Assets:Brokerage                5 AAPL @@ $75.00
+    Assets:Cash
+
+P 2012-02-15 AAPL 16 EUR
+P 2012-03-01 AAPL $20.00`
+
+	journal := NewJournal(filesystem.NewParserAdapter())
+	if err := journal.LoadFromReader(strings.NewReader(input)); err != nil {
+		t.Fatalf("Failed to load journal: %v", err)
+	}
+
+	now := time.Date(2012, time.April, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name      string
+		valuation Valuation
+		date      time.Time
+		expected  string
+	}{
+		{"as is", Valuation{}, now, "15 AAPL"},
+		{"basis", Valuation{Mode: ValueBasis}, now, "$195.00"},
+		{"market", Valuation{Mode: ValueMarket}, now, "$300.00"},
+		{"market before", Valuation{Mode: ValueMarket}, time.Date(2012, time.February, 1, 0, 0, 0, 0, time.UTC), "$180.00"},
+		{"historical", Valuation{Mode: ValueMarket, Historical: true}, now, "$195.00"},
+		{"exchange", Valuation{Mode: ValueExchange, Commodity: "EUR"}, now, "240 EUR"},
+	}
+
+	for _, test := range tests {
+		got := journal.Valued(test.valuation, test.date).GetBalance("Assets:Brokerage").String()
+		if got != test.expected {
+			t.Errorf("Expected %s valuation of %s, got %s", test.name, test.expected, got)
+		}
+	}
+}
+
+func TestPriceDBConversions(t *testing.T) {
+	input := `P 2012-01-01 AAPL $10.00
+P 2012-01-01 EUR $1.25
+P 2012-02-01 AAPL 9 GBP
+P 2012-03-01 GBP 1.2 EUR
+
+2012-01-05 Buy
+    Assets:Brokerage                10 AAPL
+    Assets:Cash                     $-100.00`
+
+	journal := NewJournal(filesystem.NewParserAdapter())
+	if err := journal.LoadFromReader(strings.NewReader(input)); err != nil {
+		t.Fatalf("Failed to load journal: %v", err)
+	}
+	prices := journal.GetPriceDB()
+
+	tests := []struct {
+		from, to string
+		date     time.Time
+		expected string
+		found    bool
+	}{
+		{"AAPL", "$", time.Date(2012, time.January, 2, 0, 0, 0, 0, time.UTC), "10.00", true},
+		{"$", "EUR", time.Date(2012, time.January, 2, 0, 0, 0, 0, time.UTC), "0.80", true},
+		{"AAPL", "EUR", time.Date(2012, time.January, 2, 0, 0, 0, 0, time.UTC), "8.00", true},
+		{"AAPL", "EUR", time.Date(2012, time.March, 2, 0, 0, 0, 0, time.UTC), "10.80", true},
+		{"AAPL", "GBP", time.Date(2012, time.January, 2, 0, 0, 0, 0, time.UTC), "", false},
+		{"AAPL", "EUR", time.Date(2011, time.December, 31, 0, 0, 0, 0, time.UTC), "", false},
+	}
+
+	for _, test := range tests {
+		rate, found := prices.Rate(test.from, test.to, test.date)
+		if found != test.found {
+			t.Errorf("Expected %s to %s at %s found to be %v", test.from, test.to, test.date.Format("2006-01-02"), test.found)
+			continue
+		}
+		if found && rate.FloatString(2) != test.expected {
+			t.Errorf("Expected %s to %s rate %s, got %s", test.from, test.to, test.expected, rate.FloatString(2))
+		}
+	}
+
+	balance := journal.GetBalance("Assets:Brokerage").ConvertTo("EUR", prices, time.Date(2012, time.January, 2, 0, 0, 0, 0, time.UTC))
+	if got := balance.String(); got != "80.00 EUR" {
+		t.Errorf("Expected 80.00 EUR, got %s", got)
+	}
+
+	valued := journal.Valued(Valuation{Mode: ValueExchange, Commodity: "EUR"}, time.Date(2012, time.March, 2, 0, 0, 0, 0, time.UTC))
+	if got := valued.GetBalance("Assets:Brokerage").String(); got != "108.00 EUR" {
+		t.Errorf("Expected 108.00 EUR, got %s", got)
+	}
+
+	// Expressions value amounts through the same price graph
+	posting := journal.GetTransactions()[0].Postings[0]
+	for text, expected := range map[string]string{
+		"market(amount, [2012/03/02], 'EUR')": "108.00 EUR",
+		"market(amount, [2012/03/02])":        "90 GBP",
+		"market(amount)":                      "$100.00",
+	} {
+		e, err := expr.Parse(text)
+		if err != nil {
+			t.Fatalf("Failed to parse %s: %v", text, err)
+		}
+		value, err := e.Eval(journal.expressionContext(posting))
+		if err != nil {
+			t.Errorf("Unexpected error evaluating %s: %v", text, err)
+		} else if value.String() != expected {
+			t.Errorf("Expected %s to be %s, got %s", text, expected, value.String())
+		}
+	}
+}
+
+func TestJournalBookLots(t *testing.T) {
+	input := `2012-01-01 Buy
+    Assets:Brokerage                10 AAPL {$100.00}
+    Assets:Cash
+
+2012-02-01 Buy
+    Assets:Brokerage                10 AAPL {$120.00}
+    Assets:Cash
+
+2012-03-01 Sell
+    Assets:Brokerage                -15 AAPL @ $150.00
+    Assets:Cash                     $2250.00
+
+P 2012-04-01 AAPL $130.00`
+
+	journal := NewJournal(filesystem.NewParserAdapter())
+	if err := journal.LoadFromReader(strings.NewReader(input)); err != nil {
+		t.Fatalf("Failed to load journal: %v", err)
+	}
+	april := time.Date(2012, time.April, 2, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		method     domain.BookingMethod
+		realized   string
+		unrealized string
+	}{
+		{domain.BookingFIFO, "$650.00", "$50.00"},
+		{domain.BookingLIFO, "$550.00", "$150.00"},
+		{domain.BookingAverage, "$600.00", "$100.00"},
+	}
+
+	for _, test := range tests {
+		inventory, err := journal.BookLots(test.method)
+		if err != nil {
+			t.Errorf("Expected %v booking to succeed, got %v", test.method, err)
+			continue
+		}
+
+		realized := domain.NewBalance()
+		for _, sale := range inventory.Sales() {
+			realized.Add(sale.Gain())
+		}
+		if got := realized.String(); got != test.realized {
+			t.Errorf("Expected %v realized gain %s, got %s", test.method, test.realized, got)
+		}
+
+		unrealized := domain.NewBalance()
+		for _, lot := range inventory.Lots() {
+			gain, ok := inventory.UnrealizedGain(lot, april)
+			if !ok {
+				t.Errorf("Expected %v lot to have a market value", test.method)
+				continue
+			}
+			unrealized.Add(gain)
+		}
+		if got := unrealized.String(); got != test.unrealized {
+			t.Errorf("Expected %v unrealized gain %s, got %s", test.method, test.unrealized, got)
+		}
+	}
+
+	if _, err := journal.BookLots(domain.BookingStrict); err == nil {
+		t.Errorf("Expected strict booking of an unannotated sale from two lots to fail")
+	}
+
+	inventory, _ := journal.BookLots(domain.BookingFIFO)
+	withGains := journal.WithCapitalGains(inventory)
+	if got := withGains.GetBalance(CapitalGainsAccount).String(); got != "$-650.00" {
+		t.Errorf("Expected capital gains posting of $-650.00, got %s", got)
+	}
+	if got := journal.GetBalance(CapitalGainsAccount).String(); got != "0" {
+		t.Errorf("Expected the journal itself to be unchanged, got %s", got)
+	}
+}
+
+func TestLotInventoryLots(t *testing.T) {
+	input := `2012-01-01 Buy
+    Assets:Brokerage                3 MSFT {$30.00} [2011/12/15] (gift)
+    Assets:Brokerage                10 AAPL @ $100.00
+    Assets:Cash
+
+2012-02-01 Sell
+    Assets:Brokerage                -4 AAPL @ $110.00
+    Assets:Cash`
+
+	journal := NewJournal(filesystem.NewParserAdapter())
+	if err := journal.LoadFromReader(strings.NewReader(input)); err != nil {
+		t.Fatalf("Failed to load journal: %v", err)
+	}
+
+	inventory, err := journal.BookLots(domain.BookingFIFO)
+	if err != nil {
+		t.Fatalf("Failed to book lots: %v", err)
+	}
+	lots := inventory.Lots()
+	if len(lots) != 2 {
+		t.Fatalf("Expected 2 open lots, got %d", len(lots))
+	}
+
+	tests := []struct {
+		quantity string
+		cost     string
+		date     string
+		label    string
+	}{
+		{"6 AAPL", "$600.00", "2012/01/01", ""},
+		{"3 MSFT", "$90.00", "2011/12/15", "gift"},
+	}
+
+	for i, test := range tests {
+		lot := lots[i]
+		if got := lot.Quantity.Format(true); got != test.quantity {
+			t.Errorf("Expected lot %d quantity %s, got %s", i, test.quantity, got)
+		}
+		if got := lot.CostBasis().Format(true); got != test.cost {
+			t.Errorf("Expected lot %d cost basis %s, got %s", i, test.cost, got)
+		}
+		if got := lot.Date.Format("2006/01/02"); got != test.date {
+			t.Errorf("Expected lot %d acquired %s, got %s", i, test.date, got)
+		}
+		if lot.Label != test.label {
+			t.Errorf("Expected lot %d label %q, got %q", i, test.label, lot.Label)
+		}
+	}
+
+	// The sale's price is the latest market price for AAPL
+	value, ok := inventory.MarketValue(lots[0], time.Date(2012, time.March, 1, 0, 0, 0, 0, time.UTC))
+	if !ok || value.Format(true) != "$660.00" {
+		t.Errorf("Expected market value $660.00, got %v", value)
+	}
+}
+
+func TestJournalBookLotsTransfers(t *testing.T) {
+	input := `2012-01-01 Buy
+    Assets:Brokerage                10 AAPL {$100.00}
+    Assets:Cash
+
+2012-02-01 Transfer
+    Assets:Brokerage                -6 AAPL
+    Assets:Retirement                6 AAPL
+
+2012-03-01 Sell
+    Assets:Retirement               -2 AAPL
+    Assets:Cash                     $300.00`
+
+	journal := NewJournal(filesystem.NewParserAdapter())
+	if err := journal.LoadFromReader(strings.NewReader(input)); err != nil {
+		t.Fatalf("Failed to load journal: %v", err)
+	}
+
+	inventory, err := journal.BookLots(domain.BookingFIFO)
+	if err != nil {
+		t.Fatalf("Failed to book lots: %v", err)
+	}
+
+	// The transfer moves the lot rather than selling it, and the sale
+	// against cash realizes a gain without an @ price
+	sales := inventory.Sales()
+	if len(sales) != 1 {
+		t.Fatalf("Expected 1 sale, got %d", len(sales))
+	}
+	if got := sales[0].Gain().Format(true); got != "$100.00" {
+		t.Errorf("Expected a gain of $100.00, got %s", got)
+	}
+
+	var held []string
+	for _, lot := range inventory.Lots() {
+		held = append(held, lot.Account+" "+lot.Quantity.Format(true)+" "+lot.Cost.Format(true))
+	}
+	expected := []string{"Assets:Brokerage 4 AAPL $100.00", "Assets:Retirement 4 AAPL $100.00"}
+	if strings.Join(held, ", ") != strings.Join(expected, ", ") {
+		t.Errorf("Expected lots %v, got %v", expected, held)
+	}
+
+	// Selling more than the lots hold is an error
+	oversold := input + `
+
+2012-04-01 Sell
+    Assets:Brokerage                -5 AAPL @ $150.00
+    Assets:Cash`
+	journal = NewJournal(filesystem.NewParserAdapter())
+	if err := journal.LoadFromReader(strings.NewReader(oversold)); err != nil {
+		t.Fatalf("Failed to load journal: %v", err)
+	}
+	if _, err := journal.BookLots(domain.BookingFIFO); err == nil || !strings.Contains(err.Error(), "cannot sell 5 AAPL from Assets:Brokerage: only 4 AAPL held in lots") {
+		t.Errorf("Expected an error for selling 1 AAPL more than held, got %v", err)
+	}
+}
@@ -0,0 +1,410 @@
+package application
+
+import (
+	"fmt"
+	"math/big"
+	"sort"
+	"time"
+
+	"github.com/hirosato/gledger/domain"
+)
+
+// CapitalGainsAccount receives the generated postings that balance realized gains
+const CapitalGainsAccount = "Equity:Capital Gains"
+
+// LotInventory holds the lots of each account and the sales that relieved
+// them, built by replaying the journal's postings in date order
+type LotInventory struct {
+	method domain.BookingMethod
+	prices *PriceDB
+	lots   map[string]map[string][]*domain.Lot // account, then commodity
+	sales  []domain.LotSale
+}
+
+// BookLots replays the journal into a lot inventory. Postings that add a
+// commodity at a {cost} or @ price acquire a lot. Postings that remove it
+// relieve lots as the booking method decides: a removal at an @ price, or
+// one that nothing in the transaction receives, is a sale realizing a gain
+// at its @ price or otherwise at the market price of the day, while any
+// other removal moves the lots to the postings receiving the commodity.
+func (j *Journal) BookLots(method domain.BookingMethod) (*LotInventory, error) {
+	inventory := &LotInventory{
+		method: method,
+		prices: j.prices,
+		lots:   make(map[string]map[string][]*domain.Lot),
+	}
+
+	transactions := make([]domain.Transaction, len(j.transactions))
+	copy(transactions, j.transactions)
+	sort.SliceStable(transactions, func(a, b int) bool {
+		return transactions[a].Date.Before(transactions[b].Date)
+	})
+
+	for i := range transactions {
+		if err := inventory.book(&transactions[i]); err != nil {
+			return nil, err
+		}
+	}
+	return inventory, nil
+}
+
+// book acquires, sells and moves the lots of one transaction
+func (inv *LotInventory) book(tx *domain.Transaction) error {
+	var postings []*domain.Posting
+	for _, posting := range tx.Postings {
+		if posting.Amount == nil || posting.Amount.IsZero() || posting.Type == domain.PostingTypeVirtual {
+			continue
+		}
+		postings = append(postings, posting)
+	}
+
+	// Postings adding a commodity without a price may receive lots moved
+	// from another account
+	receivers := make(map[string][]*domain.Posting)
+	for _, posting := range postings {
+		if posting.Amount.IsPositive() && posting.Price == nil {
+			symbol := posting.Amount.Commodity.Symbol
+			receivers[symbol] = append(receivers[symbol], posting)
+		}
+	}
+	moved := make(map[string][]domain.Lot)
+	for _, posting := range postings {
+		if posting.Amount.IsPositive() || posting.Price != nil {
+			continue
+		}
+		symbol := posting.Amount.Commodity.Symbol
+		if len(receivers[symbol]) == 0 {
+			continue
+		}
+		relieved, err := inv.relieve(tx, posting, "move")
+		if err != nil {
+			return err
+		}
+		moved[symbol] = append(moved[symbol], relieved...)
+	}
+
+	for _, posting := range postings {
+		symbol := posting.Amount.Commodity.Symbol
+		switch {
+		case posting.Amount.IsPositive():
+			if posting.Price == nil && moved[symbol] != nil {
+				moved[symbol] = inv.receive(posting, moved[symbol])
+				continue
+			}
+			inv.acquire(tx, posting)
+		case posting.Price != nil || len(receivers[symbol]) == 0:
+			if err := inv.sell(tx, posting); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// acquire adds the lot a posting bought, if it has a cost
+func (inv *LotInventory) acquire(tx *domain.Transaction, posting *domain.Posting) {
+	cost := unitCost(posting)
+	if cost == nil {
+		return
+	}
+
+	lot := &domain.Lot{
+		Account:  posting.Account.FullName,
+		Quantity: domain.NewAmount(new(big.Rat).Set(posting.Amount.Number), posting.Amount.Commodity),
+		Cost:     cost,
+		Date:     tx.Date,
+	}
+	if posting.Cost != nil {
+		if posting.Cost.Date != nil {
+			lot.Date = *posting.Cost.Date
+		}
+		lot.Label = posting.Cost.Label
+	}
+	inv.add(lot)
+}
+
+// add puts a lot into its account's holdings
+func (inv *LotInventory) add(lot *domain.Lot) {
+	symbol := lot.Quantity.Commodity.Symbol
+	if inv.lots[lot.Account] == nil {
+		inv.lots[lot.Account] = make(map[string][]*domain.Lot)
+	}
+	inv.lots[lot.Account][symbol] = append(inv.lots[lot.Account][symbol], lot)
+}
+
+// receive moves lots taken from another account into the account of a
+// posting, up to the quantity it adds, and returns the lots left to move
+func (inv *LotInventory) receive(posting *domain.Posting, lots []domain.Lot) []domain.Lot {
+	remaining := new(big.Rat).Set(posting.Amount.Number)
+	for len(lots) > 0 && remaining.Sign() > 0 {
+		lot := lots[0]
+		quantity := new(big.Rat).Set(lot.Quantity.Number)
+		if quantity.Cmp(remaining) > 0 {
+			quantity.Set(remaining)
+			lots[0].Quantity = domain.NewAmount(new(big.Rat).Sub(lot.Quantity.Number, quantity), lot.Quantity.Commodity)
+		} else {
+			lots = lots[1:]
+		}
+		remaining.Sub(remaining, quantity)
+
+		lot.Account = posting.Account.FullName
+		lot.Quantity = domain.NewAmount(quantity, lot.Quantity.Commodity)
+		inv.add(&lot)
+	}
+	return lots
+}
+
+// sell relieves the lots a posting sold, recording the sales
+func (inv *LotInventory) sell(tx *domain.Transaction, posting *domain.Posting) error {
+	relieved, err := inv.relieve(tx, posting, "sell")
+	if err != nil {
+		return err
+	}
+
+	salePrice := unitCost(&domain.Posting{Amount: posting.Amount, Price: posting.Price})
+	for _, lot := range relieved {
+		sale := domain.LotSale{
+			Lot:     lot,
+			Date:    tx.Date,
+			Payee:   tx.Payee,
+			Posting: posting,
+		}
+		sale.Proceeds = inv.proceeds(sale.Lot, salePrice, tx.Date)
+		inv.sales = append(inv.sales, sale)
+	}
+	return nil
+}
+
+// relieve removes the quantity a posting takes out of its account from the
+// account's lots, returning the part of each lot taken. Taking more than
+// the matching lots hold is an error, unless the account holds no lots of
+// the commodity at all.
+func (inv *LotInventory) relieve(tx *domain.Transaction, posting *domain.Posting, action string) ([]domain.Lot, error) {
+	account := posting.Account.FullName
+	symbol := posting.Amount.Commodity.Symbol
+	held := inv.lots[account][symbol]
+	if len(held) == 0 {
+		// Nothing held at a cost: spending a currency, say
+		return nil, nil
+	}
+
+	var candidates []*domain.Lot
+	for _, lot := range held {
+		if lot.Matches(posting.Cost, posting.Amount) {
+			candidates = append(candidates, lot)
+		}
+	}
+
+	switch inv.method {
+	case domain.BookingLIFO:
+		sort.SliceStable(candidates, func(a, b int) bool { return candidates[a].Date.After(candidates[b].Date) })
+	case domain.BookingAverage:
+		domain.AverageLots(held)
+		fallthrough
+	default:
+		sort.SliceStable(candidates, func(a, b int) bool { return candidates[a].Date.Before(candidates[b].Date) })
+	}
+	if inv.method == domain.BookingStrict && posting.Cost == nil && len(candidates) > 1 {
+		return nil, fmt.Errorf("%s: cannot %s %s from %s: it is ambiguous between %d lots",
+			saleLocation(tx, posting), action, posting.Amount.Abs().Format(true), account, len(candidates))
+	}
+
+	remaining := new(big.Rat).Abs(posting.Amount.Number)
+	var relieved []domain.Lot
+	for _, lot := range candidates {
+		if remaining.Sign() == 0 {
+			break
+		}
+		taken := new(big.Rat).Set(lot.Quantity.Number)
+		if taken.Cmp(remaining) > 0 {
+			taken.Set(remaining)
+		}
+		remaining.Sub(remaining, taken)
+
+		part := *lot
+		part.Quantity = domain.NewAmount(taken, lot.Quantity.Commodity)
+		relieved = append(relieved, part)
+
+		lot.Quantity = domain.NewAmount(new(big.Rat).Sub(lot.Quantity.Number, taken), lot.Quantity.Commodity)
+	}
+
+	// Drop the lots emptied
+	var open []*domain.Lot
+	for _, lot := range held {
+		if !lot.Quantity.IsZero() {
+			open = append(open, lot)
+		}
+	}
+	inv.lots[account][symbol] = open
+
+	if remaining.Sign() != 0 {
+		taken := posting.Amount.Abs()
+		heldQuantity := new(big.Rat).Sub(taken.Number, remaining)
+		return nil, fmt.Errorf("%s: cannot %s %s from %s: only %s held in lots",
+			saleLocation(tx, posting), action, taken.Format(true), account,
+			domain.NewAmount(heldQuantity, posting.Amount.Commodity).Format(true))
+	}
+	return relieved, nil
+}
+
+// saleLocation identifies a sale in errors by where it was written, or by
+// its date when the journal was read without sources
+func saleLocation(tx *domain.Transaction, posting *domain.Posting) string {
+	if !posting.Source.IsZero() {
+		return posting.Source.String()
+	}
+	return tx.Date.Format("2006/01/02")
+}
+
+// proceeds values the quantity sold from a lot in the lot's cost commodity,
+// at the sale price if there is one and otherwise at the market price. Sales
+// that can't be valued realize no gain.
+func (inv *LotInventory) proceeds(sold domain.Lot, salePrice *domain.Amount, date time.Time) *domain.Amount {
+	target := sold.Cost.Commodity.Symbol
+	if salePrice != nil {
+		total := salePrice.Multiply(sold.Quantity.Number)
+		if converted, ok := inv.prices.Convert(total, target, date); ok {
+			return converted
+		}
+	}
+	if value, ok := inv.prices.Convert(sold.Quantity, target, date); ok {
+		return value
+	}
+	return sold.CostBasis()
+}
+
+// unitCost returns what one unit of a posting's amount cost, from its lot
+// annotation or else its @ or @@ price
+func unitCost(posting *domain.Posting) *domain.Amount {
+	if posting.Cost != nil {
+		if cost := posting.Cost.UnitCost(posting.Amount); cost != nil {
+			return cost
+		}
+	}
+	if posting.Price != nil && posting.Price.Amount != nil {
+		if posting.Price.IsTotal {
+			return posting.Price.Amount.Divide(new(big.Rat).Abs(posting.Amount.Number))
+		}
+		return posting.Price.Amount
+	}
+	return nil
+}
+
+// Lots returns the open lots ordered by account, commodity and date
+func (inv *LotInventory) Lots() []*domain.Lot {
+	var lots []*domain.Lot
+	for _, byCommodity := range inv.lots {
+		for _, held := range byCommodity {
+			lots = append(lots, held...)
+		}
+	}
+	sort.SliceStable(lots, func(a, b int) bool {
+		if lots[a].Account != lots[b].Account {
+			return lots[a].Account < lots[b].Account
+		}
+		if lots[a].Quantity.Commodity.Symbol != lots[b].Quantity.Commodity.Symbol {
+			return lots[a].Quantity.Commodity.Symbol < lots[b].Quantity.Commodity.Symbol
+		}
+		return lots[a].Date.Before(lots[b].Date)
+	})
+	return lots
+}
+
+// Sales returns the lot sales in date order
+func (inv *LotInventory) Sales() []domain.LotSale {
+	return inv.sales
+}
+
+// MarketValue returns what a lot is worth at date in its cost commodity, and
+// whether there's a price to tell
+func (inv *LotInventory) MarketValue(lot *domain.Lot, date time.Time) (*domain.Amount, bool) {
+	return inv.prices.Convert(lot.Quantity, lot.Cost.Commodity.Symbol, date)
+}
+
+// UnrealizedGain returns a lot's market value at date less its cost basis
+func (inv *LotInventory) UnrealizedGain(lot *domain.Lot, date time.Time) (*domain.Amount, bool) {
+	value, ok := inv.MarketValue(lot, date)
+	if !ok {
+		return nil, false
+	}
+	return value.Subtract(lot.CostBasis()), true
+}
+
+// WithCapitalGains returns a view of the journal in which every sale that
+// realized a gain or loss is restated at the cost of the lots it relieved,
+// one posting per lot, and has a generated posting to CapitalGainsAccount
+// recording the gain, so that the transaction balances at cost
+func (j *Journal) WithCapitalGains(inventory *LotInventory) *Journal {
+	sales := make(map[*domain.Posting][]domain.LotSale)
+	gains := make(map[*domain.Posting]*domain.Balance)
+	for _, sale := range inventory.Sales() {
+		sales[sale.Posting] = append(sales[sale.Posting], sale)
+		if gains[sale.Posting] == nil {
+			gains[sale.Posting] = domain.NewBalance()
+		}
+		gains[sale.Posting].Add(sale.Gain())
+	}
+	for posting, gain := range gains {
+		if gain.IsZero() {
+			delete(gains, posting)
+		}
+	}
+	if len(gains) == 0 {
+		return j
+	}
+
+	account := domain.NewAccount(CapitalGainsAccount)
+
+	view := *j
+	view.transactions = make([]domain.Transaction, 0, len(j.transactions))
+	for _, tx := range j.transactions {
+		var postings, generated []*domain.Posting
+		for _, posting := range tx.Postings {
+			gain, ok := gains[posting]
+			if !ok {
+				postings = append(postings, posting)
+				continue
+			}
+			postings = append(postings, restateAtCost(posting, sales[posting])...)
+			for _, amount := range gain.GetAmounts() {
+				gainPosting := domain.NewPosting(account)
+				gainPosting.Amount = amount.Negate()
+				gainPosting.IsGenerated = true
+				gainPosting.Transaction = posting.Transaction
+				generated = append(generated, gainPosting)
+			}
+		}
+
+		withGains := tx
+		if generated != nil {
+			withGains.Postings = append(postings, generated...)
+		}
+		view.transactions = append(view.transactions, withGains)
+	}
+	return &view
+}
+
+// restateAtCost splits a sale's posting into one posting per lot it
+// relieved, each removing the quantity sold from the lot at the lot's cost
+func restateAtCost(posting *domain.Posting, sales []domain.LotSale) []*domain.Posting {
+	var restated []*domain.Posting
+	for i, sale := range sales {
+		date := sale.Lot.Date
+		part := posting.Copy()
+		part.Transaction = posting.Transaction
+		part.Amount = sale.Lot.Quantity.Negate()
+		part.ExpressionAmount = ""
+		part.Price = nil
+		if i < len(sales)-1 {
+			// A balance assertion holds after the last part
+			part.BalanceAssertion = nil
+		}
+		part.Cost = &domain.CostBasis{
+			PerUnitAmount: sale.Lot.Cost,
+			Date:          &date,
+			Label:         sale.Lot.Label,
+		}
+		restated = append(restated, part)
+	}
+	return restated
+}
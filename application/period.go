@@ -0,0 +1,72 @@
+package application
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/hirosato/gledger/domain"
+)
+
+// Now returns the date that relative periods such as "last month" are
+// resolved against: the configured one, or the current time
+func (j *Journal) Now() time.Time {
+	if j.now.IsZero() {
+		return time.Now()
+	}
+	return j.now
+}
+
+// SetNow configures the date used as now, as ledger's --now does
+func (j *Journal) SetNow(now time.Time) {
+	j.now = now
+}
+
+// ReportPeriod resolves the -b, -e and -p report options against now. The
+// begin and end options override the bounds of the period expression.
+func (j *Journal) ReportPeriod(begin, end, period string) (*domain.Period, error) {
+	result := &domain.Period{}
+	if period != "" {
+		parsed, err := domain.ParsePeriodAt(period, j.Now())
+		if err != nil {
+			return nil, err
+		}
+		result = parsed
+	}
+
+	if begin != "" {
+		bound, err := domain.ParsePeriodAt(begin, j.Now())
+		if err != nil || bound.Begin == nil {
+			return nil, fmt.Errorf("invalid begin date: %s", begin)
+		}
+		result.Begin = bound.Begin
+	}
+	if end != "" {
+		bound, err := domain.ParsePeriodAt(end, j.Now())
+		if err != nil || bound.Begin == nil {
+			return nil, fmt.Errorf("invalid end date: %s", end)
+		}
+		result.End = bound.Begin
+	}
+	return result, nil
+}
+
+// Between returns a view of the journal keeping only the transactions dated
+// on or after begin and before end. Either bound may be nil.
+func (j *Journal) Between(begin, end *time.Time) *Journal {
+	if begin == nil && end == nil {
+		return j
+	}
+
+	view := *j
+	view.transactions = []domain.Transaction{}
+	for _, tx := range j.transactions {
+		if begin != nil && tx.Date.Before(*begin) {
+			continue
+		}
+		if end != nil && !tx.Date.Before(*end) {
+			continue
+		}
+		view.transactions = append(view.transactions, tx)
+	}
+	return &view
+}
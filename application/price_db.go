@@ -0,0 +1,209 @@
+package application
+
+import (
+	"math/big"
+	"sort"
+	"time"
+
+	"github.com/hirosato/gledger/domain"
+)
+
+// PriceDB is a dated graph of commodity prices used to convert amounts. Each
+// price is an edge from the priced commodity to the price's commodity, along
+// with a reverse edge at the inverse rate, so conversions can go through
+// intermediate commodities.
+type PriceDB struct {
+	edges       map[string]map[string][]pricePoint
+	commodities map[string]*domain.Commodity
+}
+
+// pricePoint is the rate of one edge of the graph from a date on
+type pricePoint struct {
+	date    time.Time
+	rate    *big.Rat
+	reverse bool // derived from a price quoted the other way
+}
+
+// NewPriceDB creates an empty price database
+func NewPriceDB() *PriceDB {
+	return &PriceDB{
+		edges:       make(map[string]map[string][]pricePoint),
+		commodities: make(map[string]*domain.Commodity),
+	}
+}
+
+// AddPrice records that one unit of commodity was worth price at date
+func (db *PriceDB) AddPrice(commodity *domain.Commodity, date time.Time, price *domain.Amount) {
+	if price == nil || price.Number.Sign() == 0 || commodity.Symbol == price.Commodity.Symbol {
+		return
+	}
+	if _, exists := db.commodities[commodity.Symbol]; !exists {
+		db.commodities[commodity.Symbol] = commodity
+	}
+	if _, exists := db.commodities[price.Commodity.Symbol]; !exists {
+		db.commodities[price.Commodity.Symbol] = price.Commodity
+	}
+
+	rate := new(big.Rat).Set(price.Number)
+	db.addEdge(commodity.Symbol, price.Commodity.Symbol, pricePoint{date: date, rate: rate})
+	db.addEdge(price.Commodity.Symbol, commodity.Symbol, pricePoint{date: date, rate: new(big.Rat).Inv(rate), reverse: true})
+}
+
+// addEdge inserts a rate in date order. On the same date a quoted price
+// replaces any other, while a reverse one never replaces a quoted one.
+func (db *PriceDB) addEdge(from, to string, point pricePoint) {
+	if db.edges[from] == nil {
+		db.edges[from] = make(map[string][]pricePoint)
+	}
+	points := db.edges[from][to]
+
+	i := sort.Search(len(points), func(i int) bool { return !points[i].date.Before(point.date) })
+	if i < len(points) && points[i].date.Equal(point.date) {
+		if point.reverse && !points[i].reverse {
+			return
+		}
+		points[i] = point
+		return
+	}
+	points = append(points, pricePoint{})
+	copy(points[i+1:], points[i:])
+	points[i] = point
+	db.edges[from][to] = points
+}
+
+// QuotedPrice is a price recorded in the database, as quoted rather than
+// derived from a price quoted the other way
+type QuotedPrice struct {
+	Date      time.Time
+	Commodity *domain.Commodity
+	Price     *domain.Amount
+}
+
+// Prices returns the quoted prices ordered by date, commodity and the
+// commodity of the price
+func (db *PriceDB) Prices() []QuotedPrice {
+	var prices []QuotedPrice
+	for from, targets := range db.edges {
+		for to, points := range targets {
+			for _, point := range points {
+				if point.reverse {
+					continue
+				}
+				prices = append(prices, QuotedPrice{
+					Date:      point.date,
+					Commodity: db.commodities[from],
+					Price:     domain.NewAmount(new(big.Rat).Set(point.rate), db.commodities[to]),
+				})
+			}
+		}
+	}
+	sort.Slice(prices, func(a, b int) bool {
+		if !prices[a].Date.Equal(prices[b].Date) {
+			return prices[a].Date.Before(prices[b].Date)
+		}
+		if prices[a].Commodity.Symbol != prices[b].Commodity.Symbol {
+			return prices[a].Commodity.Symbol < prices[b].Commodity.Symbol
+		}
+		return prices[a].Price.Commodity.Symbol < prices[b].Price.Commodity.Symbol
+	})
+	return prices
+}
+
+// rateAt returns the latest rate of an edge on or before date
+func (db *PriceDB) rateAt(from, to string, date time.Time) (pricePoint, bool) {
+	points := db.edges[from][to]
+	i := sort.Search(len(points), func(i int) bool { return points[i].date.After(date) })
+	if i == 0 {
+		return pricePoint{}, false
+	}
+	return points[i-1], true
+}
+
+// Rate returns what one unit of from is worth in to at date. It follows the
+// path with the fewest conversions and, among those, the one whose oldest
+// price is the most recent.
+func (db *PriceDB) Rate(from, to string, date time.Time) (*big.Rat, bool) {
+	if from == to {
+		return big.NewRat(1, 1), true
+	}
+
+	type route struct {
+		rate   *big.Rat
+		oldest time.Time
+	}
+	reached := map[string]route{from: {rate: big.NewRat(1, 1), oldest: date}}
+	frontier := []string{from}
+
+	for len(frontier) > 0 {
+		next := make(map[string]route)
+		for _, node := range frontier {
+			for neighbor := range db.edges[node] {
+				if _, seen := reached[neighbor]; seen {
+					continue
+				}
+				point, ok := db.rateAt(node, neighbor, date)
+				if !ok {
+					continue
+				}
+
+				current := reached[node]
+				candidate := route{rate: new(big.Rat).Mul(current.rate, point.rate), oldest: current.oldest}
+				if point.date.Before(candidate.oldest) {
+					candidate.oldest = point.date
+				}
+				if existing, ok := next[neighbor]; !ok || candidate.oldest.After(existing.oldest) {
+					next[neighbor] = candidate
+				}
+			}
+		}
+
+		if found, ok := next[to]; ok {
+			return found.rate, true
+		}
+
+		frontier = frontier[:0]
+		for node, r := range next {
+			reached[node] = r
+			frontier = append(frontier, node)
+		}
+		sort.Strings(frontier)
+	}
+	return nil, false
+}
+
+// Convert returns the amount's value in the target commodity at date, and
+// whether a conversion path was found. Unconvertible amounts are returned
+// unchanged.
+func (db *PriceDB) Convert(amount *domain.Amount, target string, date time.Time) (*domain.Amount, bool) {
+	rate, ok := db.Rate(amount.Commodity.Symbol, target, date)
+	if !ok {
+		return amount, false
+	}
+	if amount.Commodity.Symbol == target {
+		return amount, true
+	}
+
+	commodity, exists := db.commodities[target]
+	if !exists {
+		commodity = domain.NewCommodity(target)
+	}
+	return domain.NewAmount(new(big.Rat).Mul(amount.Number, rate), commodity), true
+}
+
+// MarketCommodity returns the commodity a commodity was most recently quoted
+// in on or before date, which -V values amounts in
+func (db *PriceDB) MarketCommodity(symbol string, date time.Time) (string, bool) {
+	var best string
+	var bestDate time.Time
+	found := false
+	for to := range db.edges[symbol] {
+		point, ok := db.rateAt(symbol, to, date)
+		if !ok || point.reverse {
+			continue
+		}
+		if !found || point.date.After(bestDate) || (point.date.Equal(bestDate) && to < best) {
+			best, bestDate, found = to, point.date, true
+		}
+	}
+	return best, found
+}
@@ -0,0 +1,370 @@
+package application
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/hirosato/gledger/domain"
+)
+
+// PostingPredicate decides whether a posting is selected by a query
+type PostingPredicate func(posting *domain.Posting) bool
+
+// MatchAll is the predicate of an empty query
+func MatchAll(*domain.Posting) bool {
+	return true
+}
+
+// CompileQuery compiles report arguments written in ledger's query language
+// into a posting predicate. Terms are account regexes unless introduced by a
+// keyword or its shorthand:
+//
+//	payee/desc REGEX  or  @REGEX   match the transaction's payee
+//	tag/meta NAME[=VALUE] or %NAME match posting or transaction metadata
+//	code REGEX        or  #REGEX   match the transaction's code
+//	note REGEX        or  =REGEX   match the posting or transaction note
+//	account REGEX                  match the account explicitly
+//	expr EXPR                      evaluate a value expression
+//
+// Adjacent terms are alternatives; "and"/"&", "or"/"|", "not"/"!" and
+// parentheses combine them, with "and" binding tighter than alternatives. Regexes match case-insensitively and may be
+// written as /REGEX/. Expression terms are evaluated with the journal's now
+// and prices.
+func (j *Journal) CompileQuery(args []string) (PostingPredicate, error) {
+	tokens, err := tokenizeQuery(args)
+	if err != nil {
+		return nil, err
+	}
+	if len(tokens) == 0 {
+		return MatchAll, nil
+	}
+
+	parser := &queryParser{tokens: tokens, journal: j}
+	predicate, err := parser.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if !parser.atEnd() {
+		return nil, fmt.Errorf("unexpected '%s' in query", parser.peek().text)
+	}
+	return predicate, nil
+}
+
+// Filter returns a view of the journal keeping only the postings that match
+// the predicate, and only the transactions with at least one of them
+func (j *Journal) Filter(matches PostingPredicate) *Journal {
+	view := *j
+	view.transactions = []domain.Transaction{}
+	for _, tx := range j.transactions {
+		var postings []*domain.Posting
+		for _, posting := range tx.Postings {
+			if matches(posting) {
+				postings = append(postings, posting)
+			}
+		}
+		if len(postings) > 0 {
+			filtered := tx
+			filtered.Postings = postings
+			view.transactions = append(view.transactions, filtered)
+		}
+	}
+	return &view
+}
+
+// queryToken is a query word; quoted words are never keywords or operators
+type queryToken struct {
+	text   string
+	quoted bool
+}
+
+// tokenizeQuery splits report arguments into query tokens. Parentheses are
+// tokens of their own, and quotes or /slashes/ keep spaces and parentheses
+// inside a single token, as does passing an expression as its own argument
+// after "expr".
+func tokenizeQuery(args []string) ([]queryToken, error) {
+	var tokens []queryToken
+	for _, arg := range args {
+		// The argument after "expr" is a whole value expression
+		if n := len(tokens); n > 0 && tokens[n-1].text == "expr" && !tokens[n-1].quoted {
+			tokens = append(tokens, queryToken{text: arg, quoted: true})
+			continue
+		}
+
+		i := 0
+		for i < len(arg) {
+			c := arg[i]
+			switch {
+			case c == ' ' || c == '\t':
+				i++
+			case c == '(' || c == ')':
+				tokens = append(tokens, queryToken{text: string(c)})
+				i++
+			case c == '\'' || c == '"':
+				end := strings.IndexByte(arg[i+1:], c)
+				if end < 0 {
+					return nil, fmt.Errorf("unterminated quote in query: %s", arg)
+				}
+				tokens = append(tokens, queryToken{text: arg[i+1 : i+1+end], quoted: true})
+				i += end + 2
+			default:
+				start := i
+				for i < len(arg) && arg[i] != ' ' && arg[i] != '\t' && arg[i] != '(' && arg[i] != ')' {
+					if arg[i] == '/' {
+						// A /regex/ may contain spaces and parentheses
+						if end := strings.IndexByte(arg[i+1:], '/'); end >= 0 {
+							i += end + 1
+						}
+					}
+					i++
+				}
+				tokens = append(tokens, queryToken{text: arg[start:i]})
+			}
+		}
+	}
+	return tokens, nil
+}
+
+// queryParser is a recursive-descent parser over query tokens
+type queryParser struct {
+	tokens  []queryToken
+	pos     int
+	journal *Journal // evaluates expression terms
+}
+
+func (qp *queryParser) atEnd() bool {
+	return qp.pos >= len(qp.tokens)
+}
+
+func (qp *queryParser) peek() queryToken {
+	return qp.tokens[qp.pos]
+}
+
+func (qp *queryParser) next() queryToken {
+	token := qp.tokens[qp.pos]
+	qp.pos++
+	return token
+}
+
+// peekOperator reports whether the next token is one of the given operators
+func (qp *queryParser) peekOperator(operators ...string) bool {
+	if qp.atEnd() || qp.peek().quoted {
+		return false
+	}
+	for _, operator := range operators {
+		if qp.peek().text == operator {
+			return true
+		}
+	}
+	return false
+}
+
+// parseOr parses terms separated by "or", or just adjacent as in ledger,
+// so that "and" binds tighter than both
+func (qp *queryParser) parseOr() (PostingPredicate, error) {
+	left, err := qp.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for !qp.atEnd() && !qp.peekOperator(")") {
+		if qp.peekOperator("or", "|") {
+			qp.next()
+		}
+		right, err := qp.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = orPredicate(left, right)
+	}
+	return left, nil
+}
+
+// parseAnd parses terms separated by "and"
+func (qp *queryParser) parseAnd() (PostingPredicate, error) {
+	left, err := qp.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	for qp.peekOperator("and", "&") {
+		qp.next()
+		right, err := qp.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		left = andPredicate(left, right)
+	}
+	return left, nil
+}
+
+// parseNot parses a term optionally negated by "not"
+func (qp *queryParser) parseNot() (PostingPredicate, error) {
+	if qp.peekOperator("not", "!") {
+		qp.next()
+		operand, err := qp.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return func(posting *domain.Posting) bool {
+			return !operand(posting)
+		}, nil
+	}
+	return qp.parseTerm()
+}
+
+// parseTerm parses a parenthesized query or a single term
+func (qp *queryParser) parseTerm() (PostingPredicate, error) {
+	if qp.atEnd() {
+		return nil, fmt.Errorf("query ends unexpectedly")
+	}
+
+	token := qp.next()
+	if token.quoted {
+		return accountTerm(token.text)
+	}
+
+	switch token.text {
+	case "(":
+		inner, err := qp.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if !qp.peekOperator(")") {
+			return nil, fmt.Errorf("missing ')' in query")
+		}
+		qp.next()
+		return inner, nil
+	case ")":
+		return nil, fmt.Errorf("unexpected ')' in query")
+	case "payee", "desc", "tag", "meta", "code", "note", "account", "expr":
+		if qp.atEnd() {
+			return nil, fmt.Errorf("missing argument after '%s' in query", token.text)
+		}
+		return qp.keywordTerm(token.text, qp.next().text)
+	}
+
+	// Shorthand prefixes for the keyword terms
+	if len(token.text) > 1 {
+		switch token.text[0] {
+		case '@':
+			return qp.keywordTerm("payee", token.text[1:])
+		case '%':
+			return qp.keywordTerm("tag", token.text[1:])
+		case '#':
+			return qp.keywordTerm("code", token.text[1:])
+		case '=':
+			return qp.keywordTerm("note", token.text[1:])
+		}
+	}
+
+	return accountTerm(token.text)
+}
+
+// keywordTerm compiles a term introduced by a query keyword
+func (qp *queryParser) keywordTerm(keyword, argument string) (PostingPredicate, error) {
+	switch keyword {
+	case "payee", "desc":
+		pattern, err := compileQueryRegexp(argument)
+		if err != nil {
+			return nil, err
+		}
+		return func(posting *domain.Posting) bool {
+			return posting.Transaction != nil && pattern.MatchString(posting.Transaction.Payee)
+		}, nil
+
+	case "code":
+		pattern, err := compileQueryRegexp(argument)
+		if err != nil {
+			return nil, err
+		}
+		return func(posting *domain.Posting) bool {
+			return posting.Transaction != nil && pattern.MatchString(posting.Transaction.Code)
+		}, nil
+
+	case "note":
+		pattern, err := compileQueryRegexp(argument)
+		if err != nil {
+			return nil, err
+		}
+		return func(posting *domain.Posting) bool {
+			if pattern.MatchString(posting.Note) {
+				return true
+			}
+			return posting.Transaction != nil && pattern.MatchString(posting.Transaction.Note)
+		}, nil
+
+	case "tag", "meta":
+		return tagTerm(argument)
+
+	case "account":
+		return accountTerm(argument)
+	}
+
+	return qp.journal.ExpressionPredicate(argument)
+}
+
+// accountTerm matches postings whose account matches the regex
+func accountTerm(argument string) (PostingPredicate, error) {
+	pattern, err := compileQueryRegexp(argument)
+	if err != nil {
+		return nil, err
+	}
+	return func(posting *domain.Posting) bool {
+		return posting.Account != nil && pattern.MatchString(posting.Account.FullName)
+	}, nil
+}
+
+// tagTerm matches postings that have, or whose transaction has, a tag whose
+// name matches NAME and, when given, whose value matches VALUE
+func tagTerm(argument string) (PostingPredicate, error) {
+	name, value, hasValue := strings.Cut(argument, "=")
+	namePattern, err := compileQueryRegexp(name)
+	if err != nil {
+		return nil, err
+	}
+	var valuePattern *regexp.Regexp
+	if hasValue {
+		if valuePattern, err = compileQueryRegexp(value); err != nil {
+			return nil, err
+		}
+	}
+
+	matches := func(metadata map[string]string) bool {
+		for key, val := range metadata {
+			if namePattern.MatchString(key) && (valuePattern == nil || valuePattern.MatchString(val)) {
+				return true
+			}
+		}
+		return false
+	}
+
+	return func(posting *domain.Posting) bool {
+		if matches(posting.Metadata) {
+			return true
+		}
+		return posting.Transaction != nil && matches(posting.Transaction.Metadata)
+	}, nil
+}
+
+// compileQueryRegexp compiles a case-insensitive regex, removing /slashes/
+func compileQueryRegexp(text string) (*regexp.Regexp, error) {
+	if len(text) > 1 && strings.HasPrefix(text, "/") && strings.HasSuffix(text, "/") {
+		text = text[1 : len(text)-1]
+	}
+	pattern, err := regexp.Compile("(?i)" + text)
+	if err != nil {
+		return nil, fmt.Errorf("invalid pattern '%s' in query: %w", text, err)
+	}
+	return pattern, nil
+}
+
+func andPredicate(left, right PostingPredicate) PostingPredicate {
+	return func(posting *domain.Posting) bool {
+		return left(posting) && right(posting)
+	}
+}
+
+func orPredicate(left, right PostingPredicate) PostingPredicate {
+	return func(posting *domain.Posting) bool {
+		return left(posting) || right(posting)
+	}
+}
@@ -2,6 +2,7 @@ package usecases
 
 import (
 	"strings"
+	"time"
 
 	"github.com/hirosato/gledger/application"
 	"github.com/hirosato/gledger/application/dto"
@@ -10,11 +11,13 @@ import (
 
 // GetBalanceOptions contains options for the balance calculation
 type GetBalanceOptions struct {
-	Flat     bool     // Show accounts in flat format
-	NoTotal  bool     // Don't show total line
-	Empty    bool     // Show accounts with zero balance
-	NoRollup bool     // Don't roll up account balances to parents
-	Accounts []string // Filter by account patterns
+	Flat     bool       // Show accounts in flat format
+	NoTotal  bool       // Don't show total line
+	Empty    bool       // Show accounts with zero balance
+	NoRollup bool       // Don't roll up account balances to parents
+	Accounts []string   // Filter by account patterns
+	Begin    *time.Time // Inclusive; nil for no lower bound
+	End      *time.Time // Exclusive; nil for no upper bound
 }
 
 // GetBalance calculates and returns account balances
@@ -67,14 +70,15 @@ func (gb *GetBalance) Execute(options GetBalanceOptions) (*dto.BalanceReport, er
 
 // calculateBalances calculates balances for all accounts
 func (gb *GetBalance) calculateBalances(options GetBalanceOptions) []accountBalance {
-	// Get all account names
-	accounts := gb.journal.GetAccounts()
+	// Get all account names in the report period
+	journal := gb.journal.Between(options.Begin, options.End)
+	accounts := journal.GetAccounts()
 	
 	var balances []accountBalance
 	for _, accountName := range accounts {
 		if gb.shouldIncludeAccountName(accountName, options.Accounts) {
 			// Calculate balance for this account
-			bal := gb.journal.GetBalance(accountName)
+			bal := journal.GetBalance(accountName)
 			
 			// Calculate account level (depth)
 			level := len(strings.Split(accountName, ":")) - 1
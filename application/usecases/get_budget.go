@@ -0,0 +1,258 @@
+package usecases
+
+import (
+	"math/big"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/hirosato/gledger/application"
+	"github.com/hirosato/gledger/application/dto"
+	"github.com/hirosato/gledger/domain"
+)
+
+// BudgetMode selects which accounts a budget report shows
+type BudgetMode int
+
+const (
+	BudgetOnly       BudgetMode = iota // --budget: only budgeted accounts
+	BudgetUnbudgeted                   // --unbudgeted: only accounts without a budget
+	BudgetAll                          // --add-budget: both
+)
+
+// GetBudgetOptions contains options for the budget calculation
+type GetBudgetOptions struct {
+	Mode     BudgetMode
+	Interval domain.Interval // Split the report into periods, e.g. monthly
+	Begin    *time.Time      // Inclusive; defaults to the first transaction
+	End      *time.Time      // Exclusive; defaults to the day after the last transaction
+	Query    []string        // Query terms selecting postings
+	NoTotal  bool            // Don't show total lines
+}
+
+// GetBudget compares actual postings against periodic transaction budgets
+type GetBudget struct {
+	journal *application.Journal
+}
+
+// NewGetBudget creates a new GetBudget use case
+func NewGetBudget(journal *application.Journal) *GetBudget {
+	return &GetBudget{
+		journal: journal,
+	}
+}
+
+// Execute performs the budget calculation and returns a BudgetReport
+func (gb *GetBudget) Execute(options GetBudgetOptions) (*dto.BudgetReport, error) {
+	report := &dto.BudgetReport{
+		Periods: []dto.BudgetPeriod{},
+	}
+
+	begin, end, ok := gb.reportRange(options)
+	if !ok {
+		return report, nil
+	}
+
+	matches, err := gb.journal.CompileQuery(options.Query)
+	if err != nil {
+		return nil, err
+	}
+
+	templates := gb.journal.GetPeriodicTransactions()
+	budgeted := budgetedAccounts(templates)
+
+	split := &domain.Period{Interval: options.Interval}
+	for _, r := range split.Split(begin, end) {
+		budgets := gb.calculateBudgets(templates, r, matches)
+		actuals := gb.calculateActuals(budgeted, r, matches)
+
+		period := dto.BudgetPeriod{
+			Begin: r.Begin.Format("2006/01/02"),
+			End:   r.End.AddDate(0, 0, -1).Format("2006/01/02"),
+			Lines: []dto.BudgetLine{},
+		}
+
+		totalActual := domain.NewBalance()
+		totalBudget := domain.NewBalance()
+		for _, account := range reportAccounts(budgets, actuals, budgeted, options) {
+			actual := balanceOrEmpty(actuals[account])
+			budget := balanceOrEmpty(budgets[account])
+			if actual.IsZero() && budget.IsZero() {
+				continue
+			}
+
+			period.Lines = append(period.Lines, budgetLine(account, actual, budget))
+			totalActual.AddBalance(actual)
+			totalBudget.AddBalance(budget)
+		}
+
+		if !options.NoTotal && len(period.Lines) > 0 {
+			total := budgetLine("", totalActual, totalBudget)
+			total.IsTotal = true
+			period.Total = &total
+		}
+
+		report.Periods = append(report.Periods, period)
+	}
+
+	return report, nil
+}
+
+// reportRange returns the range of dates the report covers
+func (gb *GetBudget) reportRange(options GetBudgetOptions) (time.Time, time.Time, bool) {
+	var begin, end time.Time
+	for _, tx := range gb.journal.GetTransactions() {
+		if begin.IsZero() || tx.Date.Before(begin) {
+			begin = tx.Date
+		}
+		if last := tx.Date.AddDate(0, 0, 1); last.After(end) {
+			end = last
+		}
+	}
+
+	if options.Begin != nil {
+		begin = *options.Begin
+	}
+	if options.End != nil {
+		end = *options.End
+	}
+	return begin, end, !begin.IsZero() && begin.Before(end)
+}
+
+// calculateBudgets sums the template postings of every recurrence that
+// overlaps the range, by account
+func (gb *GetBudget) calculateBudgets(templates []*domain.PeriodicTransaction, r domain.DateRange, matches application.PostingPredicate) map[string]*domain.Balance {
+	budgets := make(map[string]*domain.Balance)
+	for _, template := range templates {
+		count := int64(len(template.Spans(r.Begin, r.End)))
+		if count == 0 {
+			continue
+		}
+		for _, posting := range template.Postings {
+			if posting.Amount == nil || !matches(posting) {
+				continue
+			}
+			name := posting.Account.FullName
+			if budgets[name] == nil {
+				budgets[name] = domain.NewBalance()
+			}
+			budgets[name].Add(posting.Amount.Multiply(big.NewRat(count, 1)))
+		}
+	}
+	return budgets
+}
+
+// calculateActuals sums the postings in the range by account. Postings
+// under a budgeted account count towards the most specific one.
+func (gb *GetBudget) calculateActuals(budgeted map[string]bool, r domain.DateRange, matches application.PostingPredicate) map[string]*domain.Balance {
+	actuals := make(map[string]*domain.Balance)
+	for _, tx := range gb.journal.GetTransactions() {
+		if !r.Contains(tx.Date) {
+			continue
+		}
+		for _, posting := range tx.Postings {
+			if posting.Amount == nil || !matches(posting) {
+				continue
+			}
+			name := budgetAccountFor(posting.Account.FullName, budgeted)
+			if actuals[name] == nil {
+				actuals[name] = domain.NewBalance()
+			}
+			actuals[name].Add(posting.Amount)
+		}
+	}
+	return actuals
+}
+
+// budgetAccountFor returns the deepest budgeted account at or above the
+// given account, or the account itself if none is budgeted
+func budgetAccountFor(account string, budgeted map[string]bool) string {
+	for name := account; name != ""; {
+		if budgeted[name] {
+			return name
+		}
+		idx := strings.LastIndex(name, ":")
+		if idx < 0 {
+			break
+		}
+		name = name[:idx]
+	}
+	return account
+}
+
+// budgetedAccounts returns the accounts that appear in periodic transactions
+func budgetedAccounts(templates []*domain.PeriodicTransaction) map[string]bool {
+	budgeted := make(map[string]bool)
+	for _, template := range templates {
+		for _, posting := range template.Postings {
+			budgeted[posting.Account.FullName] = true
+		}
+	}
+	return budgeted
+}
+
+// reportAccounts returns the sorted accounts to show for the budget mode
+func reportAccounts(budgets, actuals map[string]*domain.Balance, budgeted map[string]bool, options GetBudgetOptions) []string {
+	seen := make(map[string]bool)
+	for name := range budgets {
+		seen[name] = true
+	}
+	for name := range actuals {
+		seen[name] = true
+	}
+
+	var accounts []string
+	for name := range seen {
+		if budgeted[name] && options.Mode == BudgetUnbudgeted {
+			continue
+		}
+		if !budgeted[name] && options.Mode == BudgetOnly {
+			continue
+		}
+		accounts = append(accounts, name)
+	}
+	sort.Strings(accounts)
+	return accounts
+}
+
+// budgetLine builds a report line from an account's actual and budgeted balances
+func budgetLine(account string, actual, budget *domain.Balance) dto.BudgetLine {
+	difference := actual.Copy()
+	difference.SubtractBalance(budget)
+
+	return dto.BudgetLine{
+		Account:    account,
+		Actual:     actual.String(),
+		Budget:     budget.String(),
+		Difference: difference.String(),
+		Percent:    budgetPercent(actual, budget),
+	}
+}
+
+// budgetPercent returns the actual amount as a whole percentage of the
+// budget, or "na" when they can't be compared
+func budgetPercent(actual, budget *domain.Balance) string {
+	budgets := budget.GetAmounts()
+	if len(budgets) != 1 || budgets[0].IsZero() {
+		return "na"
+	}
+
+	spent := big.NewRat(0, 1)
+	if amount := actual.GetAmount(budgets[0].Commodity.Symbol); amount != nil {
+		spent = amount.Number
+	} else if !actual.IsZero() {
+		return "na"
+	}
+
+	percent := new(big.Rat).Quo(spent, budgets[0].Number)
+	percent.Mul(percent, big.NewRat(100, 1))
+	return percent.FloatString(0) + "%"
+}
+
+// balanceOrEmpty returns the balance, or an empty one if it is nil
+func balanceOrEmpty(balance *domain.Balance) *domain.Balance {
+	if balance == nil {
+		return domain.NewBalance()
+	}
+	return balance
+}
@@ -0,0 +1,186 @@
+package usecases
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hirosato/gledger/adapters/outbound/filesystem"
+	"github.com/hirosato/gledger/application"
+	"github.com/hirosato/gledger/application/dto"
+	"github.com/hirosato/gledger/domain"
+)
+
+// loadJournal loads a journal from its text
+func loadJournal(t *testing.T, input string) *application.Journal {
+	t.Helper()
+	journal := application.NewJournal(filesystem.NewParserAdapter())
+	if err := journal.LoadFromReader(strings.NewReader(input)); err != nil {
+		t.Fatalf("Failed to load journal: %v", err)
+	}
+	return journal
+}
+
+// date returns midnight UTC on a day
+func date(year int, month time.Month, day int) *time.Time {
+	d := time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+	return &d
+}
+
+// budgetLines returns the lines of a budget period, total last, as
+// "account: actual | budget | difference | percent" strings
+func budgetLines(period dto.BudgetPeriod) []string {
+	lines := period.Lines
+	if period.Total != nil {
+		lines = append(lines, *period.Total)
+	}
+	var rows []string
+	for _, line := range lines {
+		account := line.Account
+		if line.IsTotal {
+			account = "<Total>"
+		}
+		rows = append(rows, account+": "+strings.Join([]string{line.Actual, line.Budget, line.Difference, line.Percent}, " | "))
+	}
+	return rows
+}
+
+const budgetJournal = `~ Monthly
+    Expenses:Food               $100.00
+    Assets:Cash
+
+2012-01-05 * Grocery
+    Expenses:Food:Groceries      $30.00
+    Assets:Cash
+
+2012-01-20 * Rent
+    Expenses:Rent               $500.00
+    Assets:Checking
+
+2012-02-10 * Grocery
+    Expenses:Food               $120.00
+    Assets:Cash
+`
+
+func TestGetBudget(t *testing.T) {
+	journal := loadJournal(t, budgetJournal)
+
+	tests := []struct {
+		name     string
+		options  GetBudgetOptions
+		expected [][]string // lines of each period
+	}{
+		{
+			// Postings to sub-accounts count towards the budgeted parent
+			name:    "budget",
+			options: GetBudgetOptions{Mode: BudgetOnly},
+			expected: [][]string{
+				{
+					"Assets:Cash: $-30.00 | $-100.00 | $70.00 | 30%",
+					"Expenses:Food: $30.00 | $100.00 | $-70.00 | 30%",
+					"<Total>: 0 | 0 | 0 | na",
+				},
+				{
+					"Assets:Cash: $-120.00 | $-100.00 | $-20.00 | 120%",
+					"Expenses:Food: $120.00 | $100.00 | $20.00 | 120%",
+					"<Total>: 0 | 0 | 0 | na",
+				},
+			},
+		},
+		{
+			name:    "unbudgeted",
+			options: GetBudgetOptions{Mode: BudgetUnbudgeted},
+			expected: [][]string{
+				{
+					"Assets:Checking: $-500.00 | 0 | $-500.00 | na",
+					"Expenses:Rent: $500.00 | 0 | $500.00 | na",
+					"<Total>: 0 | 0 | 0 | na",
+				},
+				nil,
+			},
+		},
+		{
+			name:    "add budget",
+			options: GetBudgetOptions{Mode: BudgetAll, NoTotal: true},
+			expected: [][]string{
+				{
+					"Assets:Cash: $-30.00 | $-100.00 | $70.00 | 30%",
+					"Assets:Checking: $-500.00 | 0 | $-500.00 | na",
+					"Expenses:Food: $30.00 | $100.00 | $-70.00 | 30%",
+					"Expenses:Rent: $500.00 | 0 | $500.00 | na",
+				},
+				{
+					"Assets:Cash: $-120.00 | $-100.00 | $-20.00 | 120%",
+					"Expenses:Food: $120.00 | $100.00 | $20.00 | 120%",
+				},
+			},
+		},
+		{
+			// The query selects budget postings as well as actual ones
+			name:    "query",
+			options: GetBudgetOptions{Mode: BudgetAll, Query: []string{"Food"}},
+			expected: [][]string{
+				{
+					"Expenses:Food: $30.00 | $100.00 | $-70.00 | 30%",
+					"<Total>: $30.00 | $100.00 | $-70.00 | 30%",
+				},
+				{
+					"Expenses:Food: $120.00 | $100.00 | $20.00 | 120%",
+					"<Total>: $120.00 | $100.00 | $20.00 | 120%",
+				},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		test.options.Interval = domain.Interval{Unit: domain.IntervalMonth, Count: 1}
+		test.options.Begin = date(2012, time.January, 1)
+		test.options.End = date(2012, time.March, 1)
+
+		report, err := NewGetBudget(journal).Execute(test.options)
+		if err != nil {
+			t.Errorf("%s: Unexpected error: %v", test.name, err)
+			continue
+		}
+		if len(report.Periods) != len(test.expected) {
+			t.Errorf("%s: Expected %d periods, got %d", test.name, len(test.expected), len(report.Periods))
+			continue
+		}
+		for i, period := range report.Periods {
+			if got := budgetLines(period); strings.Join(got, "\n") != strings.Join(test.expected[i], "\n") {
+				t.Errorf("%s: Expected period %s\n%s\ngot\n%s", test.name, period.Begin,
+					strings.Join(test.expected[i], "\n"), strings.Join(got, "\n"))
+			}
+		}
+	}
+}
+
+func TestGetBudgetPeriods(t *testing.T) {
+	journal := loadJournal(t, budgetJournal)
+
+	// Without an interval the whole range is one period, budgeted once per
+	// month it overlaps
+	report, err := NewGetBudget(journal).Execute(GetBudgetOptions{
+		Mode:  BudgetOnly,
+		Begin: date(2012, time.January, 1),
+		End:   date(2012, time.March, 1),
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(report.Periods) != 1 {
+		t.Fatalf("Expected 1 period, got %d", len(report.Periods))
+	}
+	period := report.Periods[0]
+	if period.Begin != "2012/01/01" || period.End != "2012/02/29" {
+		t.Errorf("Expected the period 2012/01/01 to 2012/02/29, got %s to %s", period.Begin, period.End)
+	}
+	expected := []string{
+		"Assets:Cash: $-150.00 | $-200.00 | $50.00 | 75%",
+		"Expenses:Food: $150.00 | $200.00 | $-50.00 | 75%",
+		"<Total>: 0 | 0 | 0 | na",
+	}
+	if got := budgetLines(period); strings.Join(got, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("Expected\n%s\ngot\n%s", strings.Join(expected, "\n"), strings.Join(got, "\n"))
+	}
+}
@@ -0,0 +1,120 @@
+package usecases
+
+import (
+	"time"
+
+	"github.com/hirosato/gledger/application"
+	"github.com/hirosato/gledger/application/dto"
+	"github.com/hirosato/gledger/domain"
+)
+
+// GetGainsOptions contains options for the capital gains calculation
+type GetGainsOptions struct {
+	Method     domain.BookingMethod // Which lots sales relieve
+	Realized   bool                 // Report gains realized by sales
+	Unrealized bool                 // Report gains on the lots still held
+	Begin      *time.Time           // Only report sales on or after Begin
+	Date       time.Time            // Values held lots at this date
+	Query      []string             // Query terms selecting postings
+	NoTotal    bool                 // Don't show total lines
+}
+
+// GetGains reports realized and unrealized capital gains on lots
+type GetGains struct {
+	journal *application.Journal
+}
+
+// NewGetGains creates a new GetGains use case
+func NewGetGains(journal *application.Journal) *GetGains {
+	return &GetGains{
+		journal: journal,
+	}
+}
+
+// Execute books the journal's lots and returns a GainsReport
+func (gg *GetGains) Execute(options GetGainsOptions) (*dto.GainsReport, error) {
+	report := &dto.GainsReport{
+		Realized:   []dto.GainLine{},
+		Unrealized: []dto.GainLine{},
+	}
+
+	matches, err := gg.journal.CompileQuery(options.Query)
+	if err != nil {
+		return nil, err
+	}
+	inventory, err := gg.journal.Filter(matches).BookLots(options.Method)
+	if err != nil {
+		return nil, err
+	}
+
+	if options.Realized {
+		totals := newGainTotals()
+		for _, sale := range inventory.Sales() {
+			if options.Begin != nil && sale.Date.Before(*options.Begin) {
+				continue
+			}
+			line := gainLine(sale.Date, &sale.Lot, sale.Proceeds)
+			report.Realized = append(report.Realized, line)
+			totals.add(&sale.Lot, sale.Proceeds)
+		}
+		if !options.NoTotal && len(report.Realized) > 0 {
+			report.RealizedTotal = totals.line()
+		}
+	}
+
+	if options.Unrealized {
+		totals := newGainTotals()
+		for _, lot := range inventory.Lots() {
+			value, ok := inventory.MarketValue(lot, options.Date)
+			if !ok {
+				continue
+			}
+			report.Unrealized = append(report.Unrealized, gainLine(options.Date, lot, value))
+			totals.add(lot, value)
+		}
+		if !options.NoTotal && len(report.Unrealized) > 0 {
+			report.UnrealizedTotal = totals.line()
+		}
+	}
+
+	return report, nil
+}
+
+// gainLine builds a report line for a lot worth value at date
+func gainLine(date time.Time, lot *domain.Lot, value *domain.Amount) dto.GainLine {
+	return dto.GainLine{
+		Date:     date.Format("2006/01/02"),
+		Acquired: lot.Date.Format("2006/01/02"),
+		Account:  lot.Account,
+		Quantity: lot.Quantity.Format(true),
+		Cost:     lot.CostBasis().Format(true),
+		Value:    value.Format(true),
+		Gain:     value.Subtract(lot.CostBasis()).Format(true),
+	}
+}
+
+// gainTotals sums cost, value and gain across lots
+type gainTotals struct {
+	cost  *domain.Balance
+	value *domain.Balance
+	gain  *domain.Balance
+}
+
+func newGainTotals() *gainTotals {
+	return &gainTotals{cost: domain.NewBalance(), value: domain.NewBalance(), gain: domain.NewBalance()}
+}
+
+func (t *gainTotals) add(lot *domain.Lot, value *domain.Amount) {
+	t.cost.Add(lot.CostBasis())
+	t.value.Add(value)
+	t.gain.Add(value.Subtract(lot.CostBasis()))
+}
+
+func (t *gainTotals) line() *dto.GainLine {
+	return &dto.GainLine{
+		Cost:    t.cost.String(),
+		Value:   t.value.String(),
+		Gain:    t.gain.String(),
+		IsTotal: true,
+	}
+}
@@ -0,0 +1,91 @@
+package usecases
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/hirosato/gledger/application"
+	"github.com/hirosato/gledger/application/dto"
+	"github.com/hirosato/gledger/domain"
+)
+
+// GetLotsOptions contains options for the lots report
+type GetLotsOptions struct {
+	Method  domain.BookingMethod // Which lots earlier sales relieved
+	Date    time.Time            // Values lots and measures holding periods at this date
+	Query   []string             // Query terms selecting postings
+	NoTotal bool                 // Don't show the total line
+}
+
+// GetLots lists the open lots in a journal
+type GetLots struct {
+	journal *application.Journal
+}
+
+// NewGetLots creates a new GetLots use case
+func NewGetLots(journal *application.Journal) *GetLots {
+	return &GetLots{
+		journal: journal,
+	}
+}
+
+// Execute books the journal's lots and returns those still open
+func (gl *GetLots) Execute(options GetLotsOptions) (*dto.LotsReport, error) {
+	report := &dto.LotsReport{
+		Lots: []dto.LotLine{},
+	}
+
+	matches, err := gl.journal.CompileQuery(options.Query)
+	if err != nil {
+		return nil, err
+	}
+	inventory, err := gl.journal.Filter(matches).BookLots(options.Method)
+	if err != nil {
+		return nil, err
+	}
+
+	cost := domain.NewBalance()
+	value := domain.NewBalance()
+	for _, lot := range inventory.Lots() {
+		line := dto.LotLine{
+			Account:  lot.Account,
+			Quantity: lot.Quantity.Format(true),
+			Price:    lot.Cost.Format(true),
+			Cost:     lot.CostBasis().Format(true),
+			Acquired: lot.Date.Format("2006/01/02"),
+			Label:    lot.Label,
+			Held:     holdingPeriod(lot.Date, options.Date),
+		}
+		cost.Add(lot.CostBasis())
+		if market, ok := inventory.MarketValue(lot, options.Date); ok {
+			line.Value = market.Format(true)
+			value.Add(market)
+		}
+		report.Lots = append(report.Lots, line)
+	}
+
+	if !options.NoTotal && len(report.Lots) > 0 {
+		report.Total = &dto.LotLine{
+			Cost:    cost.String(),
+			IsTotal: true,
+		}
+		if !value.IsZero() {
+			report.Total.Value = value.String()
+		}
+	}
+
+	return report, nil
+}
+
+// holdingPeriod describes how long a lot acquired at acquired has been held
+// at date, in days
+func holdingPeriod(acquired, date time.Time) string {
+	days := int(date.Sub(acquired).Hours() / 24)
+	if days < 0 {
+		days = 0
+	}
+	if days == 1 {
+		return "1 day"
+	}
+	return fmt.Sprintf("%d days", days)
+}
@@ -0,0 +1,242 @@
+package usecases
+
+import (
+	"math/big"
+	"sort"
+	"time"
+
+	"github.com/hirosato/gledger/application"
+	"github.com/hirosato/gledger/application/dto"
+	"github.com/hirosato/gledger/domain"
+)
+
+// PeriodBalanceMode selects what each column of a multi-period balance shows
+type PeriodBalanceMode int
+
+const (
+	PeriodChange     PeriodBalanceMode = iota // Change during the period
+	PeriodCumulative                          // Change from the report's start to the period's end
+	PeriodHistorical                          // Balance at the period's end, including earlier postings
+)
+
+// GetPeriodBalanceOptions contains options for the multi-period balance
+type GetPeriodBalanceOptions struct {
+	Mode     PeriodBalanceMode
+	Interval domain.Interval // Width of each column, e.g. monthly
+	Begin    *time.Time      // Inclusive; defaults to the first posting
+	End      *time.Time      // Exclusive; defaults to the day after the last posting
+	Query    []string        // Query terms selecting postings
+	Empty    bool            // Show accounts whose balances are all zero
+	RowTotal bool            // Add a column with each account's total change
+	Average  bool            // Add a column with each account's average change
+	NoTotal  bool            // Don't show the total line
+}
+
+// GetPeriodBalance calculates account balances with one column per period
+type GetPeriodBalance struct {
+	journal *application.Journal
+}
+
+// NewGetPeriodBalance creates a new GetPeriodBalance use case
+func NewGetPeriodBalance(journal *application.Journal) *GetPeriodBalance {
+	return &GetPeriodBalance{
+		journal: journal,
+	}
+}
+
+// Execute performs the calculation and returns a PeriodBalanceReport
+func (gp *GetPeriodBalance) Execute(options GetPeriodBalanceOptions) (*dto.PeriodBalanceReport, error) {
+	report := &dto.PeriodBalanceReport{
+		Periods:  []string{},
+		Rows:     []dto.PeriodBalanceRow{},
+		RowTotal: options.RowTotal,
+		Average:  options.Average,
+	}
+
+	matches, err := gp.journal.CompileQuery(options.Query)
+	if err != nil {
+		return nil, err
+	}
+
+	begin, end, ok := gp.reportRange(options, matches)
+	if !ok {
+		return report, nil
+	}
+
+	split := &domain.Period{Interval: options.Interval}
+	ranges := split.Split(begin, end)
+	for _, r := range ranges {
+		report.Periods = append(report.Periods, periodLabel(r, options.Interval))
+	}
+
+	changes, opening := gp.calculateChanges(ranges, matches)
+
+	var accounts []string
+	for account := range changes {
+		accounts = append(accounts, account)
+	}
+	sort.Strings(accounts)
+
+	totals := make([]*domain.Balance, len(ranges))
+	for i := range totals {
+		totals[i] = domain.NewBalance()
+	}
+	totalOpening := domain.NewBalance()
+	for _, account := range accounts {
+		if options.Mode == PeriodHistorical {
+			totalOpening.AddBalance(balanceOrEmpty(opening[account]))
+		}
+		for i, change := range changes[account] {
+			totals[i].AddBalance(change)
+		}
+	}
+
+	for _, account := range accounts {
+		start := domain.NewBalance()
+		if options.Mode == PeriodHistorical {
+			start = balanceOrEmpty(opening[account])
+		}
+		row := periodBalanceRow(account, start, changes[account], options.Mode)
+		if !options.Empty && row.isZero {
+			continue
+		}
+		report.Rows = append(report.Rows, row.PeriodBalanceRow)
+	}
+
+	if !options.NoTotal && len(report.Rows) > 0 {
+		total := periodBalanceRow("", totalOpening, totals, options.Mode)
+		total.IsTotal = true
+		report.Total = &total.PeriodBalanceRow
+	}
+
+	return report, nil
+}
+
+// reportRange returns the range of dates the report covers
+func (gp *GetPeriodBalance) reportRange(options GetPeriodBalanceOptions, matches application.PostingPredicate) (time.Time, time.Time, bool) {
+	var begin, end time.Time
+	for _, tx := range gp.journal.GetTransactions() {
+		for _, posting := range tx.Postings {
+			if posting.Amount == nil || !matches(posting) {
+				continue
+			}
+			if begin.IsZero() || tx.Date.Before(begin) {
+				begin = tx.Date
+			}
+			if last := tx.Date.AddDate(0, 0, 1); last.After(end) {
+				end = last
+			}
+		}
+	}
+
+	if options.Begin != nil {
+		begin = *options.Begin
+	}
+	if options.End != nil {
+		end = *options.End
+	}
+	return begin, end, !begin.IsZero() && begin.Before(end)
+}
+
+// calculateChanges sums the postings of each range by account, along with
+// what each account held before the first range
+func (gp *GetPeriodBalance) calculateChanges(ranges []domain.DateRange, matches application.PostingPredicate) (map[string][]*domain.Balance, map[string]*domain.Balance) {
+	changes := make(map[string][]*domain.Balance)
+	opening := make(map[string]*domain.Balance)
+	for _, tx := range gp.journal.GetTransactions() {
+		for _, posting := range tx.Postings {
+			if posting.Amount == nil || !matches(posting) {
+				continue
+			}
+			name := posting.Account.FullName
+
+			if tx.Date.Before(ranges[0].Begin) {
+				if opening[name] == nil {
+					opening[name] = domain.NewBalance()
+				}
+				opening[name].Add(posting.Amount)
+				continue
+			}
+			for i, r := range ranges {
+				if !r.Contains(tx.Date) {
+					continue
+				}
+				if changes[name] == nil {
+					changes[name] = make([]*domain.Balance, len(ranges))
+					for j := range changes[name] {
+						changes[name][j] = domain.NewBalance()
+					}
+				}
+				changes[name][i].Add(posting.Amount)
+				break
+			}
+		}
+	}
+
+	// Accounts with only earlier postings still have a historical balance
+	for name := range opening {
+		if changes[name] == nil {
+			changes[name] = make([]*domain.Balance, len(ranges))
+			for j := range changes[name] {
+				changes[name][j] = domain.NewBalance()
+			}
+		}
+	}
+	return changes, opening
+}
+
+// periodBalance is a report row along with whether all its balances are zero
+type periodBalance struct {
+	dto.PeriodBalanceRow
+	isZero bool
+}
+
+// periodBalanceRow builds a report row from an account's changes per period.
+// The cumulative and historical modes show running totals from start.
+func periodBalanceRow(account string, start *domain.Balance, changes []*domain.Balance, mode PeriodBalanceMode) periodBalance {
+	row := periodBalance{
+		PeriodBalanceRow: dto.PeriodBalanceRow{Account: account, Balances: []string{}},
+		isZero:           true,
+	}
+
+	running := start.Copy()
+	total := domain.NewBalance()
+	for _, change := range changes {
+		total.AddBalance(change)
+		shown := change
+		if mode != PeriodChange {
+			running.AddBalance(change)
+			shown = running
+		}
+		if !shown.IsZero() {
+			row.isZero = false
+		}
+		row.Balances = append(row.Balances, shown.String())
+	}
+
+	row.Total = total.String()
+	average := domain.NewBalance()
+	if len(changes) > 0 {
+		count := big.NewRat(int64(len(changes)), 1)
+		for _, amount := range total.GetAmounts() {
+			average.Add(amount.Divide(count).RoundToPrecision())
+		}
+	}
+	row.Average = average.String()
+	return row
+}
+
+// periodLabel names a report column after the start of its range
+func periodLabel(r domain.DateRange, interval domain.Interval) string {
+	switch interval.Unit {
+	case domain.IntervalYear:
+		return r.Begin.Format("2006")
+	case domain.IntervalQuarter:
+		return r.Begin.Format("2006") + "q" + string(rune('1'+(int(r.Begin.Month())-1)/3))
+	case domain.IntervalMonth:
+		return r.Begin.Format("2006/01")
+	case domain.IntervalNone:
+		return r.Begin.Format("2006/01/02") + " - " + r.End.AddDate(0, 0, -1).Format("2006/01/02")
+	}
+	return r.Begin.Format("2006/01/02")
+}
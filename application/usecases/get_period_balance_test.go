@@ -0,0 +1,168 @@
+package usecases
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hirosato/gledger/application/dto"
+	"github.com/hirosato/gledger/domain"
+)
+
+// periodRows returns the rows of a period balance report, total last, as
+// "account: balance, balance | total | average" strings
+func periodRows(report *dto.PeriodBalanceReport) []string {
+	rows := report.Rows
+	if report.Total != nil {
+		rows = append(rows, *report.Total)
+	}
+	var lines []string
+	for _, row := range rows {
+		account := row.Account
+		if row.IsTotal {
+			account = "<Total>"
+		}
+		lines = append(lines, account+": "+strings.Join(row.Balances, ", ")+" | "+row.Total+" | "+row.Average)
+	}
+	return lines
+}
+
+const periodJournal = `2011-12-15 * Opening
+    Assets:Cash                 $100.00
+    Equity:Opening
+
+2012-01-05 * Grocery
+    Expenses:Food                $10.00
+    Assets:Cash
+
+2012-01-20 * Restaurant
+    Expenses:Food                $20.00
+    Assets:Cash
+
+2012-03-10 * Grocery
+    Expenses:Food                 $5.00
+    Assets:Cash
+`
+
+func TestGetPeriodBalance(t *testing.T) {
+	journal := loadJournal(t, periodJournal)
+	monthly := domain.Interval{Unit: domain.IntervalMonth, Count: 1}
+
+	tests := []struct {
+		name     string
+		options  GetPeriodBalanceOptions
+		periods  string
+		expected []string
+	}{
+		{
+			name:    "change",
+			options: GetPeriodBalanceOptions{Mode: PeriodChange, Query: []string{"Cash", "Food"}},
+			periods: "2012/01, 2012/02, 2012/03",
+			expected: []string{
+				"Assets:Cash: $-30.00, 0, $-5.00 | $-35.00 | $-11.67",
+				"Expenses:Food: $30.00, 0, $5.00 | $35.00 | $11.67",
+				"<Total>: 0, 0, 0 | 0 | 0",
+			},
+		},
+		{
+			name:    "cumulative",
+			options: GetPeriodBalanceOptions{Mode: PeriodCumulative, Query: []string{"Cash", "Food"}},
+			periods: "2012/01, 2012/02, 2012/03",
+			expected: []string{
+				"Assets:Cash: $-30.00, $-30.00, $-35.00 | $-35.00 | $-11.67",
+				"Expenses:Food: $30.00, $30.00, $35.00 | $35.00 | $11.67",
+				"<Total>: 0, 0, 0 | 0 | 0",
+			},
+		},
+		{
+			// Historical balances include the postings before the report,
+			// while the row total and average are still of the changes
+			name:    "historical",
+			options: GetPeriodBalanceOptions{Mode: PeriodHistorical, Query: []string{"Cash", "Food"}},
+			periods: "2012/01, 2012/02, 2012/03",
+			expected: []string{
+				"Assets:Cash: $70.00, $70.00, $65.00 | $-35.00 | $-11.67",
+				"Expenses:Food: $30.00, $30.00, $35.00 | $35.00 | $11.67",
+				"<Total>: $100.00, $100.00, $100.00 | 0 | 0",
+			},
+		},
+		{
+			// Accounts that only moved before the report hold a historical
+			// balance, but show nothing in the change mode
+			name:    "historical before the report",
+			options: GetPeriodBalanceOptions{Mode: PeriodHistorical, Query: []string{"Equity"}},
+			periods: "2012/01, 2012/02, 2012/03",
+			expected: []string{
+				"Equity:Opening: $-100.00, $-100.00, $-100.00 | 0 | 0",
+				"<Total>: $-100.00, $-100.00, $-100.00 | 0 | 0",
+			},
+		},
+		{
+			name:     "change before the report",
+			options:  GetPeriodBalanceOptions{Mode: PeriodChange, Query: []string{"Equity"}},
+			periods:  "2012/01, 2012/02, 2012/03",
+			expected: nil,
+		},
+		{
+			name:    "change before the report with empty",
+			options: GetPeriodBalanceOptions{Mode: PeriodChange, Query: []string{"Equity"}, Empty: true},
+			periods: "2012/01, 2012/02, 2012/03",
+			expected: []string{
+				"Equity:Opening: 0, 0, 0 | 0 | 0",
+				"<Total>: 0, 0, 0 | 0 | 0",
+			},
+		},
+		{
+			name:    "no total",
+			options: GetPeriodBalanceOptions{Mode: PeriodChange, Query: []string{"Food"}, NoTotal: true},
+			periods: "2012/01, 2012/02, 2012/03",
+			expected: []string{
+				"Expenses:Food: $30.00, 0, $5.00 | $35.00 | $11.67",
+			},
+		},
+	}
+
+	for _, test := range tests {
+		test.options.Interval = monthly
+		test.options.Begin = date(2012, time.January, 1)
+		test.options.End = date(2012, time.April, 1)
+
+		report, err := NewGetPeriodBalance(journal).Execute(test.options)
+		if err != nil {
+			t.Errorf("%s: Unexpected error: %v", test.name, err)
+			continue
+		}
+		if got := strings.Join(report.Periods, ", "); got != test.periods {
+			t.Errorf("%s: Expected periods %s, got %s", test.name, test.periods, got)
+		}
+		if got := periodRows(report); strings.Join(got, "\n") != strings.Join(test.expected, "\n") {
+			t.Errorf("%s: Expected\n%s\ngot\n%s", test.name, strings.Join(test.expected, "\n"), strings.Join(got, "\n"))
+		}
+	}
+}
+
+func TestGetPeriodBalanceRange(t *testing.T) {
+	journal := loadJournal(t, periodJournal)
+
+	// Without a begin or end the report runs from the first posting to the
+	// last one
+	report, err := NewGetPeriodBalance(journal).Execute(GetPeriodBalanceOptions{
+		Interval: domain.Interval{Unit: domain.IntervalMonth, Count: 1},
+		Query:    []string{"Food"},
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if got := strings.Join(report.Periods, ", "); got != "2012/01, 2012/02, 2012/03" {
+		t.Errorf("Expected periods from January to March, got %s", got)
+	}
+
+	// A query matching nothing gives an empty report
+	report, err = NewGetPeriodBalance(journal).Execute(GetPeriodBalanceOptions{Query: []string{"Rent"}})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(report.Periods) != 0 || len(report.Rows) != 0 || report.Total != nil {
+		t.Errorf("Expected an empty report, got %+v", report)
+	}
+}
@@ -1,6 +1,9 @@
 package usecases
 
 import (
+	"strings"
+	"time"
+
 	"github.com/hirosato/gledger/application"
 	"github.com/hirosato/gledger/application/dto"
 	"github.com/hirosato/gledger/domain"
@@ -8,10 +11,10 @@ import (
 
 // ShowRegisterOptions contains options for the register display
 type ShowRegisterOptions struct {
-	Account   string // Filter by account
-	StartDate string // Start date filter
-	EndDate   string // End date filter
-	Payee     string // Filter by payee
+	Account string     // Filter by account
+	Begin   *time.Time // Inclusive start date; nil for no lower bound
+	End     *time.Time // Exclusive end date; nil for no upper bound
+	Payee   string     // Filter by payee
 }
 
 // ShowRegister displays transaction register
@@ -67,7 +70,15 @@ func (sr *ShowRegister) Execute(options ShowRegisterOptions) (*dto.RegisterRepor
 }
 
 func (sr *ShowRegister) shouldInclude(tx domain.Transaction, options ShowRegisterOptions) bool {
-	// Implement filtering logic for date range and payee
+	if options.Begin != nil && tx.Date.Before(*options.Begin) {
+		return false
+	}
+	if options.End != nil && !tx.Date.Before(*options.End) {
+		return false
+	}
+	if options.Payee != "" && !strings.Contains(strings.ToLower(tx.Payee), strings.ToLower(options.Payee)) {
+		return false
+	}
 	return true
 }
 
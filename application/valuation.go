@@ -0,0 +1,103 @@
+package application
+
+import (
+	"math/big"
+	"time"
+
+	"github.com/hirosato/gledger/domain"
+)
+
+// ValuationMode selects how reports value amounts
+type ValuationMode int
+
+const (
+	ValueAsIs     ValuationMode = iota // Amounts as written
+	ValueBasis                         // -B, --basis: at their cost
+	ValueMarket                        // -V, --market: at their latest market price
+	ValueExchange                      // -X, --exchange: at their market price in a commodity
+)
+
+// Valuation configures how reports value amounts
+type Valuation struct {
+	Mode       ValuationMode
+	Commodity  string // Target commodity for ValueExchange
+	Historical bool   // Value postings at their own date rather than the report's
+}
+
+// Valued returns a view of the journal with posting amounts replaced by
+// their value. Market values use the prices at date, or at each posting's
+// transaction date for historical valuation.
+func (j *Journal) Valued(valuation Valuation, date time.Time) *Journal {
+	if valuation.Mode == ValueAsIs {
+		return j
+	}
+
+	view := *j
+	view.transactions = make([]domain.Transaction, 0, len(j.transactions))
+	for _, tx := range j.transactions {
+		valuedAt := date
+		if valuation.Historical {
+			valuedAt = tx.Date
+		}
+
+		valued := tx
+		valued.Postings = make([]*domain.Posting, 0, len(tx.Postings))
+		for _, posting := range tx.Postings {
+			if posting.Amount != nil {
+				copied := *posting
+				copied.Amount = j.value(posting, valuation, valuedAt)
+				posting = &copied
+			}
+			valued.Postings = append(valued.Postings, posting)
+		}
+		view.transactions = append(view.transactions, valued)
+	}
+	return &view
+}
+
+// GetPriceDB returns the journal's prices, from both P directives and the
+// prices postings were exchanged at
+func (j *Journal) GetPriceDB() *PriceDB {
+	return j.prices
+}
+
+// value returns a posting's amount valued as configured. Market values may
+// convert through other commodities when there's no direct price.
+func (j *Journal) value(posting *domain.Posting, valuation Valuation, date time.Time) *domain.Amount {
+	amount := posting.Amount
+	switch valuation.Mode {
+	case ValueBasis:
+		if posting.HasPrice() || posting.HasCost() {
+			return posting.GetBalancingAmount()
+		}
+	case ValueMarket:
+		return amount.MarketValue(j.prices, date)
+	case ValueExchange:
+		if amount.Commodity.NoMarket {
+			return amount
+		}
+		converted, _ := j.prices.Convert(amount, valuation.Commodity, date)
+		return converted
+	}
+	return amount
+}
+
+// recordTransactionPrices adds the prices postings were exchanged at to
+// their commodity's price history, alongside those of P directives
+func (j *Journal) recordTransactionPrices() {
+	for _, tx := range j.transactions {
+		for _, posting := range tx.Postings {
+			if !posting.HasPrice() || posting.Amount == nil || posting.Amount.IsZero() || posting.Price.Amount == nil {
+				continue
+			}
+
+			price := posting.Price.Amount
+			if posting.Price.IsTotal {
+				price = price.Divide(new(big.Rat).Abs(posting.Amount.Number))
+			}
+			commodity := j.registerCommodityIfAbsent(posting.Amount.Commodity)
+			commodity.AddPrice(tx.Date, price)
+			j.prices.AddPrice(commodity, tx.Date, price)
+		}
+	}
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// defaultInitFile is read for default options when --init-file isn't given
+const defaultInitFile = "~/.ledgerrc"
+
+// initOption is an option read from the init file
+type initOption struct {
+	name     string // without leading dashes
+	value    string
+	hasValue bool
+}
+
+// readInitFile reads the options in an init file, one per line, as in
+// "--file ~/books/main.ledger" or "--monthly". Blank lines and lines starting
+// with ;, #, % or * are comments.
+func readInitFile(path string) ([]initOption, error) {
+	file, err := os.Open(expandHome(path))
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var options []initOption
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.ContainsRune(";#%*", rune(line[0])) {
+			continue
+		}
+
+		option := initOption{name: line}
+		if idx := strings.IndexAny(line, " \t="); idx >= 0 {
+			option.name = line[:idx]
+			option.value = strings.TrimSpace(line[idx+1:])
+			option.hasValue = true
+		}
+		option.name = strings.TrimLeft(option.name, "-")
+		options = append(options, option)
+	}
+	return options, scanner.Err()
+}
+
+// args returns the option as command line arguments
+func (o initOption) args() []string {
+	prefix := "--"
+	if len(o.name) == 1 {
+		prefix = "-"
+	}
+	if !o.hasValue {
+		return []string{prefix + o.name}
+	}
+	return []string{prefix + o.name, o.value}
+}
+
+// expandHome replaces a leading ~ in a path with the home directory
+func expandHome(path string) string {
+	if path != "~" && !strings.HasPrefix(path, "~/") {
+		return path
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return path
+	}
+	return filepath.Join(home, path[1:])
+}
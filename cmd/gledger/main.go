@@ -1,7 +1,6 @@
 package main
 
 import (
-	"flag"
 	"fmt"
 	"os"
 	"strings"
@@ -9,82 +8,97 @@ import (
 	"github.com/hirosato/gledger/adapters/inbound/cli/commands"
 	"github.com/hirosato/gledger/adapters/outbound/filesystem"
 	"github.com/hirosato/gledger/application"
+	"github.com/hirosato/gledger/domain"
 )
 
 const version = "0.1.0-alpha"
 
 func main() {
-	// Parse command-line flags
-	var (
-		fileFlag    = flag.String("f", "", "Read journal from FILE")
-		fileFlagAlt = flag.String("file", "", "Read journal from FILE")
-		helpFlag    = flag.Bool("h", false, "Display help")
-		helpFlagAlt = flag.Bool("help", false, "Display help")
-		versionFlag = flag.Bool("v", false, "Display version")
-		versionFlagAlt = flag.Bool("version", false, "Display version")
-	)
-
-	flag.Parse()
+	// Options may come before or after the command
+	args, err := commands.ParseArguments(os.Args[1:])
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		fmt.Println("Run 'gledger --help' for usage information")
+		os.Exit(1)
+	}
+	for _, warning := range args.Warnings {
+		fmt.Fprintf(os.Stderr, "Warning: %s\n", warning)
+	}
 
 	// Handle help and version flags
-	if *helpFlag || *helpFlagAlt {
+	if _, ok := args.Value("help"); ok {
 		printHelp()
 		os.Exit(0)
 	}
 
-	if *versionFlag || *versionFlagAlt {
+	if _, ok := args.Value("version"); ok {
 		fmt.Printf("gledger %s\n", version)
 		fmt.Println("A Go implementation of ledger-cli")
 		fmt.Println("Copyright (c) 2024")
 		os.Exit(0)
 	}
 
-	// Get remaining args after flags
-	args := flag.Args()
-	
-	if len(args) == 0 {
+	if len(args.Terms) == 0 {
 		fmt.Println("gledger: No command specified")
 		fmt.Println("Run 'gledger --help' for usage information")
 		os.Exit(1)
 	}
 
-	// Determine the journal file
-	journalFile := *fileFlag
-	if journalFile == "" {
-		journalFile = *fileFlagAlt
+	// The init file's options are defaults the command line overrides
+	initPath, _ := args.Value("init-file")
+	defaults, err := initFileDefaults(initPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error reading init file: %v\n", err)
+		os.Exit(1)
 	}
-	
-	// If no file specified, try to read from stdin or look for default
-	var inputFile *os.File
-	var err error
-	
-	if journalFile != "" {
-		inputFile, err = os.Open(journalFile)
-		if err != nil {
-			fmt.Fprintf(os.Stderr, "Error opening file %s: %v\n", journalFile, err)
-			os.Exit(1)
+
+	// Without -f, the journal comes from the init file or LEDGER_FILE
+	files := args.Values("file")
+	if len(files) == 0 {
+		files = defaults.Values("file")
+	}
+	if len(files) == 0 {
+		if ledgerFile := os.Getenv("LEDGER_FILE"); ledgerFile != "" {
+			files = []string{ledgerFile}
 		}
-		defer inputFile.Close()
-	} else {
-		// For now, require a file to be specified
-		// In the future, we could look for .ledger or read from stdin
-		fmt.Fprintf(os.Stderr, "Error: No journal file specified. Use -f or --file option.\n")
+	}
+	if len(files) == 0 {
+		fmt.Fprintf(os.Stderr, "Error: No journal file specified. Use -f, LEDGER_FILE or --file in ~/.ledgerrc.\n")
 		os.Exit(1)
 	}
+	for i, file := range files {
+		files[i] = expandHome(file)
+	}
+
+	// Get command and remaining arguments; the init file's options come
+	// first so the command line's override them
+	command := strings.ToLower(args.Terms[0])
+	commandArgs := &commands.Arguments{
+		Options: append(defaults.Options, args.Options...),
+		Terms:   args.Terms[1:],
+	}
 
 	// Create dependencies
 	parser := filesystem.NewParserAdapter()
 	
 	// Create and load journal with injected dependencies
 	journal := application.NewJournal(parser)
-	if err := journal.LoadFromReader(inputFile); err != nil {
+	if nowOption, ok := commandArgs.Value("now"); ok {
+		now, err := domain.ParseDate(nowOption)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: invalid date for --now: %s\n", nowOption)
+			os.Exit(1)
+		}
+		journal.SetNow(now)
+	}
+	// Included files are read relative to the journal file
+	if err := journal.LoadFromFiles(files); err != nil {
 		fmt.Fprintf(os.Stderr, "Error parsing journal: %v\n", err)
 		os.Exit(1)
 	}
-
-	// Get command and remaining arguments
-	command := strings.ToLower(args[0])
-	commandArgs := args[1:]
+	for _, warning := range journal.GetWarnings() {
+		fmt.Fprintf(os.Stderr, "Warning: %s\n", warning)
+	}
 
 	// Execute command
 	switch command {
@@ -102,6 +116,13 @@ func main() {
 			os.Exit(1)
 		}
 	
+	case "budget":
+		cmd := commands.NewBudgetCommand(journal)
+		if err := cmd.Execute(commandArgs); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+	
 	case "register", "reg", "r":
 		cmd := commands.NewRegisterCommand(journal)
 		if err := cmd.Execute(commandArgs); err != nil {
@@ -151,6 +172,13 @@ func main() {
 			os.Exit(1)
 		}
 	
+	case "lots":
+		cmd := commands.NewLotsCommand(journal)
+		if err := cmd.Execute(commandArgs); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+	
 	default:
 		fmt.Fprintf(os.Stderr, "Unknown command: %s\n", command)
 		fmt.Println("Run 'gledger --help' for usage information")
@@ -158,14 +186,43 @@ func main() {
 	}
 }
 
+// initFileDefaults parses the options of the init file, which the command
+// line overrides. Only an init file given with --init-file has to exist.
+func initFileDefaults(path string) (*commands.Arguments, error) {
+	if path == "" {
+		path = defaultInitFile
+		if _, err := os.Stat(expandHome(path)); err != nil {
+			return &commands.Arguments{}, nil
+		}
+	}
+	options, err := readInitFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var args []string
+	for _, option := range options {
+		args = append(args, option.args()...)
+	}
+	defaults, err := commands.ParseArguments(args)
+	if err != nil {
+		return nil, err
+	}
+	if len(defaults.Terms) > 0 {
+		return nil, fmt.Errorf("unexpected argument: %s", defaults.Terms[0])
+	}
+	return defaults, nil
+}
+
 func printHelp() {
 	fmt.Println("gledger - A Go implementation of ledger-cli")
 	fmt.Println()
 	fmt.Println("Usage:")
-	fmt.Println("  gledger [command] [options] [file...]")
+	fmt.Println("  gledger [options] command [options] [query...]")
 	fmt.Println()
 	fmt.Println("Commands:")
 	fmt.Println("  balance, bal      Show account balances")
+	fmt.Println("  budget            Compare actual amounts against periodic budgets")
 	fmt.Println("  register, reg     Show transaction register")
 	fmt.Println("  print             Print transactions")
 	fmt.Println("  accounts          List all accounts")
@@ -174,11 +231,32 @@ func printHelp() {
 	fmt.Println("  stats             Show journal statistics")
 	fmt.Println("  prices            Show price history")
 	fmt.Println("  equity            Generate opening balance entries")
+	fmt.Println("  lots              List open lots with their cost, market value and holding period")
 	fmt.Println()
 	fmt.Println("Options:")
-	fmt.Println("  -f, --file FILE   Read journal from FILE")
+	fmt.Println("  -f, --file FILE   Read journal from FILE; repeat for several files, - for stdin")
+	fmt.Println("  --init-file FILE  Read default options from FILE (default ~/.ledgerrc)")
+	fmt.Println("  --now DATE        Use DATE as the current date")
 	fmt.Println("  -h, --help        Display this help")
 	fmt.Println("  -v, --version     Display version information")
 	fmt.Println()
+	fmt.Println("Report options:")
+	fmt.Println("  -b, --begin DATE  Only include transactions on or after DATE")
+	fmt.Println("  -e, --end DATE    Only include transactions before DATE")
+	fmt.Println("  -p, --period EXPR Only include transactions in the period, e.g. \"last month\"")
+	fmt.Println("  -M, --monthly     Group register rows or balance columns by month (also -D, -W, -Y, --quarterly)")
+	fmt.Println("  -B, --basis       Report amounts at their cost")
+	fmt.Println("  -V, --market      Report amounts at their market value")
+	fmt.Println("  -X COMMODITY      Report market values in COMMODITY")
+	fmt.Println("  -H, --historical  Value amounts at their posting date")
+	fmt.Println("  -G, --gain        Report realized capital gains (print: add the gain postings)")
+	fmt.Println("  --unrealized      Report unrealized gains on the lots still held")
+	fmt.Println("  --booking METHOD  Relieve lots by fifo, lifo, average or strict")
+	fmt.Println("  --lot-prices      Show lot prices (also --lot-dates, --lot-notes, --lots)")
+	fmt.Println("  -O, --output-format FORMAT  Write json, csv or xml instead of text")
+	fmt.Println()
+	fmt.Println("Options may come before or after the command, as --name value, --name=value")
+	fmt.Println("or -x value.")
+	fmt.Println()
 	fmt.Println("For more information, see: https://github.com/hirosato/gledger")
 }
\ No newline at end of file
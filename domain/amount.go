@@ -3,11 +3,13 @@ package domain
 import (
 	"fmt"
 	"math/big"
+	"time"
 )
 
 type Amount struct {
 	Number    *big.Rat
 	Commodity *Commodity
+	Lot       *CostBasis // lot annotation the amount was acquired with, if any
 }
 
 func NewAmount(number *big.Rat, commodity *Commodity) *Amount {
@@ -83,7 +85,9 @@ func (a *Amount) DivideByFloat(divisor float64) *Amount {
 
 func (a *Amount) Negate() *Amount {
 	result := new(big.Rat).Neg(a.Number)
-	return NewAmount(result, a.Commodity)
+	negated := NewAmount(result, a.Commodity)
+	negated.Lot = a.Lot
+	return negated
 }
 
 func (a *Amount) Abs() *Amount {
@@ -103,6 +107,18 @@ func (a *Amount) IsNegative() bool {
 	return a.Number.Sign() < 0
 }
 
+// IsNegligible reports whether the amount rounds to zero at its commodity's
+// display precision, which is the tolerance used when balancing transactions
+func (a *Amount) IsNegligible() bool {
+	if a.IsZero() {
+		return true
+	}
+	tolerance := new(big.Rat).SetFrac(big.NewInt(1),
+		new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(a.Commodity.Precision)), nil))
+	tolerance.Quo(tolerance, big.NewRat(2, 1))
+	return new(big.Rat).Abs(a.Number).Cmp(tolerance) < 0
+}
+
 func (a *Amount) Compare(other *Amount) int {
 	if a.Commodity.Symbol != other.Commodity.Symbol {
 		panic(fmt.Sprintf("cannot compare different commodities: %s and %s", 
@@ -115,8 +131,25 @@ func (a *Amount) Equals(other *Amount) bool {
 	return a.Commodity.Symbol == other.Commodity.Symbol && a.Number.Cmp(other.Number) == 0
 }
 
+// MarketValue returns the amount's value at date in the commodity it was
+// most recently quoted in, as -V reports it. Amounts without a price at date
+// are returned unchanged.
+func (a *Amount) MarketValue(prices PriceSource, date time.Time) *Amount {
+	if a.Commodity.NoMarket {
+		return a
+	}
+	target, ok := prices.MarketCommodity(a.Commodity.Symbol, date)
+	if !ok {
+		return a
+	}
+	value, _ := prices.Convert(a, target, date)
+	return value
+}
+
 func (a *Amount) Copy() *Amount {
-	return NewAmount(new(big.Rat).Set(a.Number), a.Commodity)
+	copy := NewAmount(new(big.Rat).Set(a.Number), a.Commodity)
+	copy.Lot = a.Lot
+	return copy
 }
 
 func (a *Amount) String() string {
@@ -124,29 +157,14 @@ func (a *Amount) String() string {
 }
 
 func (a *Amount) Format(showCommodity bool) string {
-	numberStr := a.formatNumber()
+	numberStr := a.Commodity.FormatNumber(a.Number)
 	
-	if !showCommodity || a.Commodity.Symbol == "" {
+	if !showCommodity {
 		return numberStr
 	}
 	
-	// Format consistently as "number commodity"
-	
-	return numberStr + " " + a.Commodity.Symbol
-}
-
-func (a *Amount) formatNumber() string {
-	if a.Commodity.Precision == 0 {
-		if a.Number.IsInt() {
-			return a.Number.Num().String()
-		}
-		return a.Number.String()
-	}
-	
-	floatValue, _ := a.Number.Float64()
-	precision := a.Commodity.Precision
-	format := fmt.Sprintf("%%.%df", precision)
-	return fmt.Sprintf(format, floatValue)
+	// Place the symbol the way the commodity was written
+	return a.Commodity.Decorate(numberStr)
 }
 
 func (a *Amount) ConvertTo(targetCommodity *Commodity, conversionRate *big.Rat) *Amount {
@@ -160,25 +178,19 @@ func (a *Amount) ToFloat64() float64 {
 }
 
 func (a *Amount) RoundToPrecision() *Amount {
-	if a.Commodity.Precision <= 0 {
+	if a.Commodity.Precision < 0 {
 		return a.Copy()
 	}
 	
 	precision := int64(a.Commodity.Precision)
 	multiplier := new(big.Rat).SetInt(new(big.Int).Exp(big.NewInt(10), big.NewInt(precision), nil))
 	
-	temp := new(big.Rat).Mul(a.Number, multiplier)
-	rounded := new(big.Int)
-	temp.Num().DivMod(temp.Num(), temp.Denom(), rounded)
-	
-	if temp.Sign() < 0 {
-		if new(big.Rat).Sub(temp, new(big.Rat).SetInt(rounded)).Cmp(new(big.Rat).SetFloat64(-0.5)) <= 0 {
-			rounded.Sub(rounded, big.NewInt(1))
-		}
-	} else {
-		if new(big.Rat).Sub(temp, new(big.Rat).SetInt(rounded)).Cmp(new(big.Rat).SetFloat64(0.5)) >= 0 {
-			rounded.Add(rounded, big.NewInt(1))
-		}
+	// Round half away from zero
+	scaled := new(big.Rat).Mul(new(big.Rat).Abs(a.Number), multiplier)
+	scaled.Add(scaled, big.NewRat(1, 2))
+	rounded := new(big.Int).Quo(scaled.Num(), scaled.Denom())
+	if a.Number.Sign() < 0 {
+		rounded.Neg(rounded)
 	}
 	
 	result := new(big.Rat).SetInt(rounded)
@@ -0,0 +1,208 @@
+package domain
+
+import (
+	"fmt"
+	"math/big"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// invalidSymbolChars are characters that cannot appear in an unquoted commodity symbol
+const invalidSymbolChars = " \t\r\n0123456789.,;:?!-+*/^&|=<>{}[]()@\""
+
+// ParseAmount parses a complete amount such as "$1,000.00", "-$5", "$-5",
+// "10EUR", "1.000,50 EUR" or "\"ABC 123\" 10". The returned amount carries a
+// fresh Commodity describing the style the amount was written in.
+func ParseAmount(text string) (*Amount, error) {
+	trimmed := strings.TrimSpace(text)
+	amount, n, err := ScanAmount(trimmed)
+	if err != nil {
+		return nil, err
+	}
+	if n != len(trimmed) {
+		return nil, fmt.Errorf("invalid amount: %s", text)
+	}
+	return amount, nil
+}
+
+// ScanAmount parses the amount at the start of text and returns it together
+// with the number of bytes consumed. Text after the amount is left alone so
+// callers can continue with prices, costs or assertions.
+func ScanAmount(text string) (*Amount, int, error) {
+	pos := skipSpaces(text, 0)
+	negative := false
+	if pos < len(text) && text[pos] == '-' {
+		negative = true
+		pos++
+	}
+
+	commodity := NewCommodity("")
+	var number *big.Rat
+
+	if pos < len(text) && (isDigit(text[pos]) || text[pos] == '.' || text[pos] == ',') {
+		// Number first, optionally followed by a suffix commodity
+		value, end, err := scanNumber(text, pos, commodity)
+		if err != nil {
+			return nil, 0, err
+		}
+		number = value
+		pos = end
+
+		symbolStart := skipSpaces(text, pos)
+		symbol, symbolEnd, err := scanSymbol(text, symbolStart)
+		if err != nil {
+			return nil, 0, err
+		}
+		if symbol != "" {
+			commodity.Symbol = symbol
+			commodity.Spaced = symbolStart > pos
+			pos = symbolEnd
+		}
+	} else {
+		// Prefix commodity, optionally followed by a sign
+		symbol, symbolEnd, err := scanSymbol(text, pos)
+		if err != nil {
+			return nil, 0, err
+		}
+		if symbol == "" {
+			return nil, 0, fmt.Errorf("invalid amount: %s", text)
+		}
+		commodity.Symbol = symbol
+		commodity.Prefix = true
+
+		pos = skipSpaces(text, symbolEnd)
+		commodity.Spaced = pos > symbolEnd
+		if pos < len(text) && text[pos] == '-' {
+			negative = !negative
+			pos++
+		}
+
+		value, end, err := scanNumber(text, pos, commodity)
+		if err != nil {
+			return nil, 0, err
+		}
+		number = value
+		pos = end
+	}
+
+	if negative {
+		number.Neg(number)
+	}
+	return &Amount{Number: number, Commodity: commodity}, pos, nil
+}
+
+// scanNumber reads the digits and separators starting at pos and records the
+// precision, decimal mark and thousands separator on the commodity
+func scanNumber(text string, pos int, commodity *Commodity) (*big.Rat, int, error) {
+	start := pos
+	for pos < len(text) && (isDigit(text[pos]) || text[pos] == '.' || text[pos] == ',') {
+		pos++
+	}
+	raw := text[start:pos]
+	if raw == "" || !strings.ContainsAny(raw, "0123456789") {
+		return nil, 0, fmt.Errorf("invalid amount: %s", text)
+	}
+
+	decimalMark, thousandsSeparator := detectSeparators(raw)
+
+	var digits strings.Builder
+	precision := 0
+	seenDecimal := false
+	for i := 0; i < len(raw); i++ {
+		c := rune(raw[i])
+		switch {
+		case c == decimalMark:
+			if seenDecimal {
+				return nil, 0, fmt.Errorf("invalid amount: %s", text)
+			}
+			seenDecimal = true
+			digits.WriteByte('.')
+		case c == thousandsSeparator:
+			if seenDecimal {
+				return nil, 0, fmt.Errorf("invalid amount: %s", text)
+			}
+		default:
+			digits.WriteByte(raw[i])
+			if seenDecimal {
+				precision++
+			}
+		}
+	}
+
+	number, ok := new(big.Rat).SetString(digits.String())
+	if !ok {
+		return nil, 0, fmt.Errorf("invalid amount: %s", text)
+	}
+
+	commodity.Precision = precision
+	if decimalMark != 0 {
+		commodity.DecimalMark = decimalMark
+	}
+	commodity.ThousandsSeparator = thousandsSeparator
+	return number, pos, nil
+}
+
+// detectSeparators decides which of '.' and ',' is the decimal mark in a
+// number. When both appear, the last one is the decimal mark. A lone comma
+// followed by exactly three digits is taken as a thousands separator.
+func detectSeparators(raw string) (decimalMark rune, thousandsSeparator rune) {
+	lastDot := strings.LastIndex(raw, ".")
+	lastComma := strings.LastIndex(raw, ",")
+
+	switch {
+	case lastDot >= 0 && lastComma >= 0:
+		if lastDot > lastComma {
+			return '.', ','
+		}
+		return ',', '.'
+	case lastComma >= 0:
+		if strings.Count(raw, ",") > 1 || (len(raw)-lastComma-1 == 3 && lastComma > 0) {
+			return 0, ','
+		}
+		return ',', 0
+	case lastDot >= 0:
+		if strings.Count(raw, ".") > 1 {
+			return 0, '.'
+		}
+		return '.', 0
+	}
+	return 0, 0
+}
+
+// scanSymbol reads a quoted or unquoted commodity symbol starting at pos
+func scanSymbol(text string, pos int) (string, int, error) {
+	if pos >= len(text) {
+		return "", pos, nil
+	}
+
+	if text[pos] == '"' {
+		end := strings.IndexByte(text[pos+1:], '"')
+		if end < 0 {
+			return "", 0, fmt.Errorf("unterminated quoted commodity: %s", text)
+		}
+		return text[pos+1 : pos+1+end], pos + end + 2, nil
+	}
+
+	end := pos
+	for end < len(text) {
+		r, size := utf8.DecodeRuneInString(text[end:])
+		if strings.ContainsRune(invalidSymbolChars, r) || unicode.IsSpace(r) {
+			break
+		}
+		end += size
+	}
+	return text[pos:end], end, nil
+}
+
+// skipSpaces returns the position of the first non-blank character at or after pos
+func skipSpaces(text string, pos int) int {
+	for pos < len(text) && (text[pos] == ' ' || text[pos] == '\t') {
+		pos++
+	}
+	return pos
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
@@ -0,0 +1,70 @@
+package domain
+
+import (
+	"math/big"
+	"testing"
+)
+
+func TestParseAmountStyles(t *testing.T) {
+	tests := []struct {
+		input     string
+		number    *big.Rat
+		symbol    string
+		prefix    bool
+		spaced    bool
+		thousands rune
+		decimal   rune
+		precision int
+	}{
+		{"$1,000.00", big.NewRat(1000, 1), "$", true, false, ',', '.', 2},
+		{"-$5", big.NewRat(-5, 1), "$", true, false, 0, '.', 0},
+		{"$-5", big.NewRat(-5, 1), "$", true, false, 0, '.', 0},
+		{"10EUR", big.NewRat(10, 1), "EUR", false, false, 0, '.', 0},
+		{"1.000,50 EUR", big.NewRat(200100, 200), "EUR", false, true, '.', ',', 2},
+		{"10,25 EUR", big.NewRat(1025, 100), "EUR", false, true, 0, ',', 2},
+		{"\"ABC 123\" 10", big.NewRat(10, 1), "ABC 123", true, true, 0, '.', 0},
+		{"-0.00000001 BTC", big.NewRat(-1, 100000000), "BTC", false, true, 0, '.', 8},
+		{"42", big.NewRat(42, 1), "", false, true, 0, '.', 0},
+	}
+
+	for _, test := range tests {
+		amount, err := ParseAmount(test.input)
+		if err != nil {
+			t.Errorf("Failed to parse amount '%s': %v", test.input, err)
+			continue
+		}
+
+		c := amount.Commodity
+		if amount.Number.Cmp(test.number) != 0 {
+			t.Errorf("For '%s', expected number %s, got %s", test.input, test.number.RatString(), amount.Number.RatString())
+		}
+		if c.Symbol != test.symbol || c.Prefix != test.prefix || c.Spaced != test.spaced {
+			t.Errorf("For '%s', unexpected symbol style: %q prefix=%v spaced=%v", test.input, c.Symbol, c.Prefix, c.Spaced)
+		}
+		if c.ThousandsSeparator != test.thousands || c.DecimalMark != test.decimal || c.Precision != test.precision {
+			t.Errorf("For '%s', unexpected number style: thousands=%q decimal=%q precision=%d",
+				test.input, c.ThousandsSeparator, c.DecimalMark, c.Precision)
+		}
+		if test.symbol != "" && amount.Format(true) != test.input && test.input[0] != '-' {
+			t.Errorf("For '%s', expected round trip, got '%s'", test.input, amount.Format(true))
+		}
+	}
+}
+
+func TestScanAmountStopsAtPrice(t *testing.T) {
+	amount, n, err := ScanAmount("10 AAPL @ $50.00")
+	if err != nil {
+		t.Fatalf("Failed to scan amount: %v", err)
+	}
+	if amount.Commodity.Symbol != "AAPL" || n != len("10 AAPL") {
+		t.Errorf("Expected to scan '10 AAPL', got %s (%d bytes)", amount.Format(true), n)
+	}
+}
+
+func TestParseAmountRejectsGarbage(t *testing.T) {
+	for _, input := range []string{"", "$", "abc", "1.2.3,4,5", "(Budget)"} {
+		if _, err := ParseAmount(input); err == nil {
+			t.Errorf("Expected '%s' to be rejected", input)
+		}
+	}
+}
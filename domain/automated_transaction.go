@@ -0,0 +1,50 @@
+package domain
+
+import "strings"
+
+// matchedAccountPlaceholder stands for the matched posting's account in an
+// automated transaction's postings
+const matchedAccountPlaceholder = "$account"
+
+// AutomatedTransaction is an "= PREDICATE" rule. Its postings are added to
+// every transaction for each posting that matches the predicate, and its
+// assert and check directives are evaluated against each matching posting.
+type AutomatedTransaction struct {
+	Predicate string
+	Postings  []*Posting
+	Checks    []Directive
+}
+
+func (a *AutomatedTransaction) Type() DirectiveType {
+	return DirectiveTypeAutomated
+}
+
+func (a *AutomatedTransaction) String() string {
+	return "= " + a.Predicate
+}
+
+// Generate returns the postings the rule adds for a matched posting. An
+// amount without a commodity multiplies the matched posting's amount, and
+// the account "$account" stands for the matched posting's account.
+func (a *AutomatedTransaction) Generate(matched *Posting) []*Posting {
+	var generated []*Posting
+	for _, template := range a.Postings {
+		posting := template.Copy()
+		posting.IsGenerated = true
+
+		if strings.Contains(template.Account.FullName, matchedAccountPlaceholder) {
+			name := strings.ReplaceAll(template.Account.FullName, matchedAccountPlaceholder, matched.Account.FullName)
+			posting.Account = NewAccount(name)
+		}
+
+		if template.Amount != nil && template.Amount.Commodity.Symbol == "" {
+			if matched.Amount == nil {
+				continue
+			}
+			posting.Amount = matched.Amount.Multiply(template.Amount.Number)
+		}
+
+		generated = append(generated, posting)
+	}
+	return generated
+}
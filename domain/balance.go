@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"sort"
 	"strings"
+	"time"
 )
 
 type Balance struct {
@@ -140,39 +141,24 @@ func (b *Balance) Abs() *Balance {
 	return result
 }
 
-func (b *Balance) ConvertTo(targetCommodity *Commodity, commodityRepo CommodityRepository) *Balance {
-	if b.IsZero() {
-		return NewBalance()
-	}
-	
-	if b.HasSingleCommodity() {
-		amount := b.GetAmounts()[0]
-		if amount.Commodity.Symbol == targetCommodity.Symbol {
-			return b.Copy()
-		}
-		
-		price := amount.Commodity.GetLatestPrice(targetCommodity.Symbol)
-		if price != nil {
-			convertedAmount := amount.ConvertTo(targetCommodity, price.Number)
-			return NewBalanceFromAmount(convertedAmount)
-		}
+// ConvertTo converts every amount of the balance into the target commodity at
+// date. Amounts the prices can't convert are kept as they are.
+func (b *Balance) ConvertTo(target string, prices PriceSource, date time.Time) *Balance {
+	result := NewBalance()
+	for _, amount := range b.GetAmounts() {
+		converted, _ := prices.Convert(amount, target, date)
+		result.Add(converted)
 	}
-	
+	return result
+}
+
+// MarketValue returns the market value of every amount of the balance at
+// date, as -V reports it
+func (b *Balance) MarketValue(prices PriceSource, date time.Time) *Balance {
 	result := NewBalance()
 	for _, amount := range b.GetAmounts() {
-		if amount.Commodity.Symbol == targetCommodity.Symbol {
-			result.Add(amount)
-		} else {
-			price := amount.Commodity.GetLatestPrice(targetCommodity.Symbol)
-			if price != nil {
-				convertedAmount := amount.ConvertTo(targetCommodity, price.Number)
-				result.Add(convertedAmount)
-			} else {
-				result.Add(amount)
-			}
-		}
+		result.Add(amount.MarketValue(prices, date))
 	}
-	
 	return result
 }
 
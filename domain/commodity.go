@@ -2,6 +2,7 @@ package domain
 
 import (
 	"math/big"
+	"strings"
 	"time"
 )
 
@@ -18,6 +19,12 @@ type Commodity struct {
 	Note         string
 	Alias        string
 	PriceHistory []*PricePoint
+
+	// Display style, remembered from how amounts were written
+	Prefix             bool // symbol goes before the number, as in "$10"
+	Spaced             bool // symbol and number are separated by a space
+	ThousandsSeparator rune // 0 when no thousands separator is used
+	DecimalMark        rune
 }
 
 func NewCommodity(symbol string) *Commodity {
@@ -25,9 +32,20 @@ func NewCommodity(symbol string) *Commodity {
 		Symbol:       symbol,
 		Precision:    2,
 		PriceHistory: make([]*PricePoint, 0),
+		Spaced:       true,
+		DecimalMark:  '.',
 	}
 }
 
+// AdoptStyle copies the display style of another commodity
+func (c *Commodity) AdoptStyle(other *Commodity) {
+	c.Prefix = other.Prefix
+	c.Spaced = other.Spaced
+	c.ThousandsSeparator = other.ThousandsSeparator
+	c.DecimalMark = other.DecimalMark
+	c.Precision = other.Precision
+}
+
 func (c *Commodity) AddPrice(date time.Time, amount *Amount) {
 	pricePoint := &PricePoint{
 		Date:   date,
@@ -71,6 +89,23 @@ func (c *Commodity) GetPriceAt(date time.Time, targetCommodity string) *Amount {
 	return nil
 }
 
+// GetMarketPriceAt returns the most recent price on or before date, in
+// whichever commodity it was quoted
+func (c *Commodity) GetMarketPriceAt(date time.Time) *Amount {
+	var bestPrice *PricePoint
+	for _, p := range c.PriceHistory {
+		if p.Date.After(date) {
+			break
+		}
+		bestPrice = p
+	}
+	
+	if bestPrice != nil {
+		return bestPrice.Amount
+	}
+	return nil
+}
+
 func (c *Commodity) GetLatestPrice(targetCommodity string) *Amount {
 	for i := len(c.PriceHistory) - 1; i >= 0; i-- {
 		p := c.PriceHistory[i]
@@ -85,25 +120,79 @@ func (c *Commodity) HasPriceHistory() bool {
 	return len(c.PriceHistory) > 0
 }
 
+// FormatNumber formats a number with the commodity's precision, decimal mark
+// and thousands separator. Numbers more precise than the commodity are
+// rounded to its precision.
 func (c *Commodity) FormatNumber(number *big.Rat) string {
-	if c.Format != "" {
-		return c.formatWithCustomFormat(number)
+	digits := number.FloatString(max(c.Precision, 0))
+	sign := ""
+	if strings.HasPrefix(digits, "-") {
+		digits = digits[1:]
+		// Numbers that round to zero lose their sign
+		if strings.Trim(digits, "0.") != "" {
+			sign = "-"
+		}
 	}
-	return c.formatWithPrecision(number)
+
+	integer, fraction, hasFraction := strings.Cut(digits, ".")
+	if c.ThousandsSeparator != 0 {
+		integer = groupThousands(integer, c.ThousandsSeparator)
+	}
+
+	result := sign + integer
+	if hasFraction {
+		decimalMark := c.DecimalMark
+		if decimalMark == 0 {
+			decimalMark = '.'
+		}
+		result += string(decimalMark) + fraction
+	}
+	return result
 }
 
-func (c *Commodity) formatWithPrecision(number *big.Rat) string {
-	if c.Precision == 0 {
-		return number.RatString()
+// Decorate places the commodity symbol around a formatted number
+func (c *Commodity) Decorate(number string) string {
+	if c.Symbol == "" {
+		return number
 	}
-	
-	floatValue, _ := number.Float64()
-	format := "%." + string(rune('0'+c.Precision)) + "f"
-	return string(rune(len(format))) + string(rune(int(floatValue)))
+
+	separator := ""
+	if c.Spaced {
+		separator = " "
+	}
+	if c.Prefix {
+		return c.QuotedSymbol() + separator + number
+	}
+	return number + separator + c.QuotedSymbol()
 }
 
-func (c *Commodity) formatWithCustomFormat(_ *big.Rat) string {
-	return c.Format
+// QuotedSymbol returns the symbol, quoted when it contains characters that
+// would otherwise end it
+func (c *Commodity) QuotedSymbol() string {
+	if strings.ContainsAny(c.Symbol, invalidSymbolChars) {
+		return "\"" + c.Symbol + "\""
+	}
+	return c.Symbol
+}
+
+// groupThousands inserts a separator between each group of three digits
+func groupThousands(digits string, separator rune) string {
+	if len(digits) <= 3 {
+		return digits
+	}
+
+	var result strings.Builder
+	head := len(digits) % 3
+	if head > 0 {
+		result.WriteString(digits[:head])
+	}
+	for i := head; i < len(digits); i += 3 {
+		if result.Len() > 0 {
+			result.WriteRune(separator)
+		}
+		result.WriteString(digits[i : i+3])
+	}
+	return result.String()
 }
 
 func (c *Commodity) Copy() *Commodity {
@@ -115,6 +204,11 @@ func (c *Commodity) Copy() *Commodity {
 		Note:         c.Note,
 		Alias:        c.Alias,
 		PriceHistory: make([]*PricePoint, 0, len(c.PriceHistory)),
+
+		Prefix:             c.Prefix,
+		Spaced:             c.Spaced,
+		ThousandsSeparator: c.ThousandsSeparator,
+		DecimalMark:        c.DecimalMark,
 	}
 	
 	for _, p := range c.PriceHistory {
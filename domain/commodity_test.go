@@ -0,0 +1,32 @@
+package domain
+
+import (
+	"math/big"
+	"testing"
+)
+
+func TestCommodityFormatNumber(t *testing.T) {
+	tests := []struct {
+		number    string
+		precision int
+		expected  string
+	}{
+		{"15/2", 0, "8"},
+		{"-67/3", 0, "-22"},
+		{"-1/3", 0, "0"},
+		{"1/3", 2, "0.33"},
+		{"-2/3", 2, "-0.67"},
+		{"1234567/2", 1, "617,283.5"},
+		{"10", 2, "10.00"},
+	}
+
+	for _, test := range tests {
+		number, _ := new(big.Rat).SetString(test.number)
+		commodity := NewCommodity("$")
+		commodity.Precision = test.precision
+		commodity.ThousandsSeparator = ','
+		if got := commodity.FormatNumber(number); got != test.expected {
+			t.Errorf("For %s at precision %d, expected %s, got %s", test.number, test.precision, test.expected, got)
+		}
+	}
+}
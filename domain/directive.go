@@ -17,6 +17,8 @@ const (
 	DirectiveBucket
 	DirectiveTypeAssert
 	DirectiveTypeCheck
+	DirectiveTypeAutomated
+	DirectiveTypePeriodic
 )
 
 // Directive represents a ledger directive
@@ -46,8 +48,11 @@ func (d *AccountDirective) String() string {
 type CommodityDirective struct {
 	Symbol    string
 	Format    string
-	Precision int
+	Precision int // -1 when no format was given
 	Note      string
+	NoMarket  bool
+	Alias     string
+	Default   bool
 }
 
 func (d *CommodityDirective) Type() DirectiveType {
@@ -92,7 +97,8 @@ func (d *AliasDirective) String() string {
 
 // IncludeDirective represents an include statement
 type IncludeDirective struct {
-	Path string
+	Path  string
+	Files []string // the files the path resolved to, in the order they were read
 }
 
 func (d *IncludeDirective) Type() DirectiveType {
@@ -0,0 +1,467 @@
+package expr
+
+import (
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/hirosato/gledger/domain"
+)
+
+// Context supplies the values an expression's variables refer to. Reports
+// evaluate expressions against a posting; balance reports, which have an
+// account total rather than a posting, set Account and Amount instead.
+type Context struct {
+	Posting     *domain.Posting
+	Transaction *domain.Transaction // Defaults to the posting's transaction
+	Account     string              // Used when there is no posting
+	Amount      *domain.Balance     // Overrides the posting's amount
+	Total       *domain.Balance     // Running total; defaults to the amount
+	Now         time.Time           // Defaults to the current time
+	Prices      domain.PriceSource  // Values amounts for market(); without it amounts keep their value
+}
+
+func (ctx *Context) transaction() *domain.Transaction {
+	if ctx.Transaction != nil {
+		return ctx.Transaction
+	}
+	if ctx.Posting != nil {
+		return ctx.Posting.Transaction
+	}
+	return nil
+}
+
+func (ctx *Context) accountName() string {
+	if ctx.Posting != nil && ctx.Posting.Account != nil {
+		return ctx.Posting.Account.FullName
+	}
+	return ctx.Account
+}
+
+func (ctx *Context) amount() Value {
+	if ctx.Amount != nil {
+		return BalanceValue(ctx.Amount)
+	}
+	if ctx.Posting != nil {
+		return AmountValue(ctx.Posting.Amount)
+	}
+	return Null
+}
+
+func (ctx *Context) total() Value {
+	if ctx.Total != nil {
+		return BalanceValue(ctx.Total)
+	}
+	return ctx.amount()
+}
+
+func (ctx *Context) now() time.Time {
+	if ctx.Now.IsZero() {
+		return time.Now()
+	}
+	return ctx.Now
+}
+
+// date returns the transaction's date, or now when there's no transaction
+func (ctx *Context) date() time.Time {
+	if tx := ctx.transaction(); tx != nil {
+		return tx.Date
+	}
+	return ctx.now()
+}
+
+// metadata returns the posting's metadata merged over its transaction's
+func (ctx *Context) metadata() map[string]string {
+	merged := make(map[string]string)
+	if tx := ctx.transaction(); tx != nil {
+		for key, value := range tx.Metadata {
+			merged[key] = value
+		}
+	}
+	if ctx.Posting != nil {
+		for key, value := range ctx.Posting.Metadata {
+			merged[key] = value
+		}
+	}
+	return merged
+}
+
+// variables are the names an expression can refer to, with their short forms
+var variables = map[string]func(ctx *Context) Value{
+	"amount": (*Context).amount,
+	"a":      (*Context).amount,
+	"total":  (*Context).total,
+	"T":      (*Context).total,
+	"cost": func(ctx *Context) Value {
+		if ctx.Posting != nil && ctx.Amount == nil {
+			return AmountValue(ctx.Posting.GetBalancingAmount())
+		}
+		return ctx.amount()
+	},
+	"commodity": func(ctx *Context) Value {
+		if amount, err := ctx.amount().Amount(); err == nil {
+			return StringValue(amount.Commodity.Symbol)
+		}
+		return Null
+	},
+	"date": func(ctx *Context) Value { return DateValue(ctx.date()) },
+	"d":    func(ctx *Context) Value { return DateValue(ctx.date()) },
+	"now":  func(ctx *Context) Value { return DateValue(ctx.now()) },
+	"m":    func(ctx *Context) Value { return DateValue(ctx.now()) },
+	"today": func(ctx *Context) Value {
+		now := ctx.now()
+		return DateValue(time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC))
+	},
+	"account": func(ctx *Context) Value { return StringValue(ctx.accountName()) },
+	"A":       func(ctx *Context) Value { return StringValue(ctx.accountName()) },
+	"account_base": func(ctx *Context) Value {
+		name := ctx.accountName()
+		return StringValue(name[strings.LastIndex(name, ":")+1:])
+	},
+	"depth": func(ctx *Context) Value {
+		name := ctx.accountName()
+		if name == "" {
+			return NumberValue(new(big.Rat))
+		}
+		return NumberValue(big.NewRat(int64(strings.Count(name, ":")+1), 1))
+	},
+	"payee": func(ctx *Context) Value {
+		return transactionString(ctx, func(tx *domain.Transaction) string { return tx.Payee })
+	},
+	"P": func(ctx *Context) Value {
+		return transactionString(ctx, func(tx *domain.Transaction) string { return tx.Payee })
+	},
+	"code": func(ctx *Context) Value {
+		return transactionString(ctx, func(tx *domain.Transaction) string { return tx.Code })
+	},
+	"note": noteVariable,
+	"N":    noteVariable,
+	"cleared": func(ctx *Context) Value {
+		tx := ctx.transaction()
+		return BoolValue(tx != nil && tx.Status == domain.TransactionStatusCleared)
+	},
+	"X": func(ctx *Context) Value {
+		tx := ctx.transaction()
+		return BoolValue(tx != nil && tx.Status == domain.TransactionStatusCleared)
+	},
+	"uncleared": func(ctx *Context) Value {
+		tx := ctx.transaction()
+		return BoolValue(tx != nil && tx.Status != domain.TransactionStatusCleared)
+	},
+	"real":    func(ctx *Context) Value { return BoolValue(ctx.Posting == nil || !ctx.Posting.IsVirtual()) },
+	"R":       func(ctx *Context) Value { return BoolValue(ctx.Posting == nil || !ctx.Posting.IsVirtual()) },
+	"virtual": func(ctx *Context) Value { return BoolValue(ctx.Posting != nil && ctx.Posting.IsVirtual()) },
+	"true":    func(*Context) Value { return BoolValue(true) },
+	"false":   func(*Context) Value { return BoolValue(false) },
+	"null":    func(*Context) Value { return Null },
+}
+
+func transactionString(ctx *Context, field func(tx *domain.Transaction) string) Value {
+	if tx := ctx.transaction(); tx != nil {
+		return StringValue(field(tx))
+	}
+	return Null
+}
+
+// noteVariable is the posting's note, or its transaction's
+func noteVariable(ctx *Context) Value {
+	if ctx.Posting != nil && ctx.Posting.Note != "" {
+		return StringValue(ctx.Posting.Note)
+	}
+	return transactionString(ctx, func(tx *domain.Transaction) string { return tx.Note })
+}
+
+// function is a built-in function taking between min and max arguments
+type function struct {
+	min, max int
+	call     func(ctx *Context, args []Value) (Value, error)
+}
+
+// functions are the built-in functions by name
+var functions = map[string]function{
+	"abs":      {1, 1, absFunction},
+	"round":    {1, 1, roundFunction},
+	"quantity": {1, 1, quantityFunction},
+	"commodity": {1, 1, func(ctx *Context, args []Value) (Value, error) {
+		amount, err := args[0].Amount()
+		if err != nil {
+			return Null, fmt.Errorf("commodity: %w", err)
+		}
+		return StringValue(amount.Commodity.Symbol), nil
+	}},
+	"market":  {1, 3, marketFunction},
+	"P":       {1, 3, marketFunction},
+	"tag":     {1, 1, tagFunction},
+	"has_tag": {1, 1, hasTagFunction},
+	"date":    {0, 1, dateFunction},
+	"now": {0, 0, func(ctx *Context, args []Value) (Value, error) {
+		return DateValue(ctx.now()), nil
+	}},
+}
+
+func absFunction(ctx *Context, args []Value) (Value, error) {
+	switch args[0].kind {
+	case KindAmount:
+		return AmountValue(args[0].amount.Abs()), nil
+	case KindBalance:
+		return BalanceValue(args[0].balance.Abs()), nil
+	}
+	return Null, fmt.Errorf("abs: expected an amount, got %s", args[0].describe())
+}
+
+// roundFunction rounds to the commodity's display precision
+func roundFunction(ctx *Context, args []Value) (Value, error) {
+	if args[0].kind == KindAmount {
+		return AmountValue(args[0].amount.RoundToPrecision()), nil
+	}
+	balance, err := args[0].Balance()
+	if err != nil {
+		return Null, fmt.Errorf("round: %w", err)
+	}
+	rounded := domain.NewBalance()
+	for _, amount := range balance.GetAmounts() {
+		rounded.Add(amount.RoundToPrecision())
+	}
+	return BalanceValue(rounded), nil
+}
+
+// quantityFunction strips the commodity from an amount
+func quantityFunction(ctx *Context, args []Value) (Value, error) {
+	amount, err := args[0].Amount()
+	if err != nil {
+		return Null, fmt.Errorf("quantity: %w", err)
+	}
+	commodity := domain.NewCommodity("")
+	commodity.Precision = amount.Commodity.Precision
+	return AmountValue(domain.NewAmount(new(big.Rat).Set(amount.Number), commodity)), nil
+}
+
+// marketFunction values an amount or balance at a date, by default the
+// transaction's, in the commodity it's quoted in or the one given
+func marketFunction(ctx *Context, args []Value) (Value, error) {
+	date := ctx.date()
+	if len(args) > 1 {
+		d, err := args[1].Date()
+		if err != nil {
+			return Null, fmt.Errorf("market: %w", err)
+		}
+		date = d
+	}
+
+	balance, err := args[0].Balance()
+	if err != nil {
+		return Null, fmt.Errorf("market: %w", err)
+	}
+	if ctx.Prices == nil {
+		return BalanceValue(balance), nil
+	}
+	if len(args) > 2 {
+		if args[2].kind != KindString {
+			return Null, fmt.Errorf("market: expected a commodity, got %s", args[2].describe())
+		}
+		return BalanceValue(balance.ConvertTo(args[2].text, ctx.Prices, date)), nil
+	}
+	return BalanceValue(balance.MarketValue(ctx.Prices, date)), nil
+}
+
+// tagFunction returns the value of the first tag whose name matches, or null
+func tagFunction(ctx *Context, args []Value) (Value, error) {
+	key, found, err := findTag(ctx, args[0])
+	if err != nil || !found {
+		return Null, err
+	}
+	return StringValue(ctx.metadata()[key]), nil
+}
+
+func hasTagFunction(ctx *Context, args []Value) (Value, error) {
+	_, found, err := findTag(ctx, args[0])
+	return BoolValue(found), err
+}
+
+// findTag looks up a tag by exact name, or by regex when given a mask
+func findTag(ctx *Context, name Value) (string, bool, error) {
+	metadata := ctx.metadata()
+	switch name.kind {
+	case KindString:
+		_, found := metadata[name.text]
+		return name.text, found, nil
+	case KindMask:
+		for key := range metadata {
+			if name.mask.MatchString(key) {
+				return key, true, nil
+			}
+		}
+		return "", false, nil
+	}
+	return "", false, fmt.Errorf("expected a tag name, got %s", name.describe())
+}
+
+// dateFunction returns the transaction's date, or converts a string to a date
+func dateFunction(ctx *Context, args []Value) (Value, error) {
+	if len(args) == 0 {
+		return DateValue(ctx.date()), nil
+	}
+	switch args[0].kind {
+	case KindDate:
+		return args[0], nil
+	case KindString:
+		date, err := domain.ParseDate(args[0].text)
+		if err != nil {
+			return Null, err
+		}
+		return DateValue(date), nil
+	}
+	return Null, fmt.Errorf("date: expected a date, got %s", args[0].describe())
+}
+
+// node is a node of a parsed expression
+type node interface {
+	eval(ctx *Context) (Value, error)
+}
+
+type literalNode struct {
+	value Value
+}
+
+func (n *literalNode) eval(*Context) (Value, error) {
+	return n.value, nil
+}
+
+type variableNode struct {
+	name string
+}
+
+func (n *variableNode) eval(ctx *Context) (Value, error) {
+	variable, ok := variables[n.name]
+	if !ok {
+		return Null, fmt.Errorf("unknown identifier '%s'", n.name)
+	}
+	return variable(ctx), nil
+}
+
+type callNode struct {
+	name      string
+	function  function
+	arguments []node
+}
+
+func (n *callNode) eval(ctx *Context) (Value, error) {
+	if len(n.arguments) < n.function.min || len(n.arguments) > n.function.max {
+		return Null, fmt.Errorf("wrong number of arguments to %s()", n.name)
+	}
+	args := make([]Value, len(n.arguments))
+	for i, argument := range n.arguments {
+		value, err := argument.eval(ctx)
+		if err != nil {
+			return Null, err
+		}
+		args[i] = value
+	}
+	return n.function.call(ctx, args)
+}
+
+type unaryNode struct {
+	operator string
+	operand  node
+}
+
+func (n *unaryNode) eval(ctx *Context) (Value, error) {
+	value, err := n.operand.eval(ctx)
+	if err != nil {
+		return Null, err
+	}
+	if n.operator == "!" {
+		return BoolValue(!value.Truthy()), nil
+	}
+	return negate(value)
+}
+
+// logicalNode is a short-circuiting "and" or "or"
+type logicalNode struct {
+	and         bool
+	left, right node
+}
+
+func (n *logicalNode) eval(ctx *Context) (Value, error) {
+	left, err := n.left.eval(ctx)
+	if err != nil {
+		return Null, err
+	}
+	if left.Truthy() != n.and {
+		return BoolValue(!n.and), nil
+	}
+	right, err := n.right.eval(ctx)
+	if err != nil {
+		return Null, err
+	}
+	return BoolValue(right.Truthy()), nil
+}
+
+type conditionalNode struct {
+	condition, then, otherwise node
+}
+
+func (n *conditionalNode) eval(ctx *Context) (Value, error) {
+	condition, err := n.condition.eval(ctx)
+	if err != nil {
+		return Null, err
+	}
+	if condition.Truthy() {
+		return n.then.eval(ctx)
+	}
+	return n.otherwise.eval(ctx)
+}
+
+type binaryNode struct {
+	operator    string
+	left, right node
+}
+
+func (n *binaryNode) eval(ctx *Context) (Value, error) {
+	left, err := n.left.eval(ctx)
+	if err != nil {
+		return Null, err
+	}
+	right, err := n.right.eval(ctx)
+	if err != nil {
+		return Null, err
+	}
+
+	switch n.operator {
+	case "+":
+		return add(left, right)
+	case "-":
+		return subtract(left, right)
+	case "*":
+		return multiply(left, right)
+	case "/":
+		return divide(left, right)
+	case "==", "!=":
+		same, err := equal(left, right)
+		return BoolValue(same == (n.operator == "==")), err
+	case "=~", "!~":
+		mask, err := right.Mask()
+		if err != nil {
+			return Null, err
+		}
+		if left.kind != KindString {
+			return Null, fmt.Errorf("cannot match %s against a regex", left.describe())
+		}
+		return BoolValue(mask.MatchString(left.text) == (n.operator == "=~")), nil
+	}
+
+	order, err := compare(left, right)
+	if err != nil {
+		return Null, err
+	}
+	switch n.operator {
+	case "<":
+		return BoolValue(order < 0), nil
+	case "<=":
+		return BoolValue(order <= 0), nil
+	case ">":
+		return BoolValue(order > 0), nil
+	}
+	return BoolValue(order >= 0), nil
+}
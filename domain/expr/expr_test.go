@@ -0,0 +1,136 @@
+package expr
+
+import (
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/hirosato/gledger/domain"
+)
+
+func testContext() *Context {
+	dollar := domain.NewCommodity("$")
+	dollar.Prefix = true
+	dollar.Spaced = false
+
+	tx := domain.NewTransaction(time.Date(2012, time.March, 15, 0, 0, 0, 0, time.UTC))
+	tx.Payee = "Grocery Store"
+	tx.Code = "101"
+	tx.Status = domain.TransactionStatusCleared
+	tx.Metadata["shopping"] = ""
+
+	posting := domain.NewPosting(domain.NewAccount("Expenses:Food"))
+	posting.Account.FullName = "Expenses:Food"
+	posting.Amount = domain.NewAmount(big.NewRat(2550, 100), dollar)
+	posting.Metadata["Receipt"] = "42"
+	tx.AddPosting(posting)
+
+	total := domain.NewBalanceFromAmount(domain.NewAmount(big.NewRat(100, 1), dollar))
+	return &Context{
+		Posting: posting,
+		Total:   total,
+		Now:     time.Date(2012, time.April, 1, 0, 0, 0, 0, time.UTC),
+	}
+}
+
+func TestEval(t *testing.T) {
+	tests := []struct {
+		expr     string
+		expected string
+	}{
+		{"$10 * 2", "$20"},
+		{"(1 + 2) * 3", "9"},
+		{"10 - 4 / 2", "8"},
+		{"-$5 + 2", "$-3"},
+		{"$10 + 5 EUR", "$10, 5 EUR"},
+		{"amount", "$25.50"},
+		{"a * 2", "$51.00"},
+		{"total - amount", "$74.50"},
+		{"abs(-$3)", "$3"},
+		{"quantity(amount)", "25.50"},
+		{"commodity(amount)", "$"},
+		{"account", "Expenses:Food"},
+		{"account_base", "Food"},
+		{"depth", "2"},
+		{"payee", "Grocery Store"},
+		{"date", "2012/03/15"},
+		{"now", "2012/04/01"},
+		{"date('2011-05-01')", "2011/05/01"},
+		{"tag('Receipt')", "42"},
+		{"amount > 20 ? 'big' : 'small'", "big"},
+		{"round(amount / 7)", "$3.64"},
+		{"market(amount)", "$25.50"},
+	}
+
+	for _, test := range tests {
+		e, err := Parse(test.expr)
+		if err != nil {
+			t.Errorf("Unexpected error parsing %q: %v", test.expr, err)
+			continue
+		}
+		value, err := e.Eval(testContext())
+		if err != nil {
+			t.Errorf("Unexpected error evaluating %q: %v", test.expr, err)
+			continue
+		}
+		if value.String() != test.expected {
+			t.Errorf("Expected %q to be %s, got %s", test.expr, test.expected, value.String())
+		}
+	}
+}
+
+func TestTest(t *testing.T) {
+	tests := []struct {
+		expr     string
+		expected bool
+	}{
+		{"amount > $20", true},
+		{"amount >= 30", false},
+		{"amount == $25.50", true},
+		{"account =~ /food/", true},
+		{"account !~ /^assets/", true},
+		{"/food/", true},
+		{"payee =~ /grocery/ and not (code == '102')", true},
+		{"has_tag('shopping') & has_tag(/^rec/)", true},
+		{"has_tag('travel') | cleared", true},
+		{"date >= [2012/03] && date < [2012/04]", true},
+		{"d < [2012]", false},
+		{"real and !virtual", true},
+		{"tag('Missing')", false},
+	}
+
+	for _, test := range tests {
+		e, err := Parse(test.expr)
+		if err != nil {
+			t.Errorf("Unexpected error parsing %q: %v", test.expr, err)
+			continue
+		}
+		result, err := e.Test(testContext())
+		if err != nil {
+			t.Errorf("Unexpected error evaluating %q: %v", test.expr, err)
+			continue
+		}
+		if result != test.expected {
+			t.Errorf("Expected %q to be %v, got %v", test.expr, test.expected, result)
+		}
+	}
+}
+
+func TestErrors(t *testing.T) {
+	for _, text := range []string{"", "1 +", "(1 + 2", "foo(1)", "'open", "/open", "1 2", "[2012/13]"} {
+		if _, err := Parse(text); err == nil {
+			t.Errorf("Expected error parsing %q", text)
+		}
+	}
+
+	for _, text := range []string{"unknown", "1 / 0", "$1 < 1 EUR", "abs(1, 2)", "'a' * 2"} {
+		e, err := Parse(text)
+		if err != nil {
+			t.Errorf("Unexpected error parsing %q: %v", text, err)
+			continue
+		}
+		if _, err := e.Eval(testContext()); err == nil {
+			t.Errorf("Expected error evaluating %q", text)
+		}
+	}
+}
@@ -0,0 +1,230 @@
+package expr
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/hirosato/gledger/domain"
+)
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenAmount
+	tokenString
+	tokenMask
+	tokenDate
+	tokenIdent
+	tokenOperator
+)
+
+// token is a lexical unit of an expression
+type token struct {
+	kind   tokenKind
+	text   string
+	amount *domain.Amount
+	pos    int
+}
+
+// operators are the operator tokens, longest first so that "==" wins over "="
+var operators = []string{
+	"==", "!=", "<=", ">=", "=~", "!~", "&&", "||",
+	"+", "-", "*", "/", "<", ">", "=", "!", "&", "|", "?", ":", "(", ")", ",",
+}
+
+// operatorChars can't appear in a commodity symbol written in an expression
+const operatorChars = "+-*/=!<>&|?:(),'\"[]%~@;."
+
+// keywords are the word operators, which never name a commodity
+var keywords = map[string]bool{
+	"and": true,
+	"or":  true,
+	"not": true,
+}
+
+// lexer splits an expression into tokens
+type lexer struct {
+	text   string
+	pos    int
+	tokens []token
+}
+
+// tokenize returns the tokens of an expression, ending with an EOF token
+func tokenize(text string) ([]token, error) {
+	l := &lexer{text: text}
+	for {
+		l.skipSpaces()
+		if l.pos >= len(l.text) {
+			l.tokens = append(l.tokens, token{kind: tokenEOF, pos: l.pos})
+			return l.tokens, nil
+		}
+		if err := l.next(); err != nil {
+			return nil, err
+		}
+	}
+}
+
+func (l *lexer) skipSpaces() {
+	for l.pos < len(l.text) && (l.text[l.pos] == ' ' || l.text[l.pos] == '\t') {
+		l.pos++
+	}
+}
+
+// afterOperand reports whether the previous token ends an operand, in which
+// case a slash divides rather than starting a regex
+func (l *lexer) afterOperand() bool {
+	if len(l.tokens) == 0 {
+		return false
+	}
+	last := l.tokens[len(l.tokens)-1]
+	return last.kind != tokenOperator || last.text == ")"
+}
+
+// next scans one token
+func (l *lexer) next() error {
+	start := l.pos
+	c := l.text[l.pos]
+	r, _ := utf8.DecodeRuneInString(l.text[l.pos:])
+
+	switch {
+	case c == '\'' || c == '"':
+		end := strings.IndexByte(l.text[l.pos+1:], c)
+		if end < 0 {
+			return fmt.Errorf("unterminated string in expression: %s", l.text)
+		}
+		l.emit(tokenString, l.text[l.pos+1:l.pos+1+end], start)
+		l.pos += end + 2
+
+	case c == '/' && !l.afterOperand():
+		end := strings.IndexByte(l.text[l.pos+1:], '/')
+		if end < 0 {
+			return fmt.Errorf("unterminated regex in expression: %s", l.text)
+		}
+		l.emit(tokenMask, l.text[l.pos+1:l.pos+1+end], start)
+		l.pos += end + 2
+
+	case c == '[':
+		end := strings.IndexByte(l.text[l.pos:], ']')
+		if end < 0 {
+			return fmt.Errorf("unterminated date in expression: %s", l.text)
+		}
+		l.emit(tokenDate, strings.TrimSpace(l.text[l.pos+1:l.pos+end]), start)
+		l.pos += end + 1
+
+	case isDigit(c) || (c == '.' && l.pos+1 < len(l.text) && isDigit(l.text[l.pos+1])):
+		return l.scanAmount(l.numberEnd(l.pos), true)
+
+	case unicode.IsLetter(r) || c == '_':
+		for l.pos < len(l.text) {
+			r, size := utf8.DecodeRuneInString(l.text[l.pos:])
+			if !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '_' {
+				break
+			}
+			l.pos += size
+		}
+		l.emit(tokenIdent, l.text[start:l.pos], start)
+
+	case isSymbolRune(r):
+		// A prefix commodity such as "$10" or "€ -5"
+		end := l.symbolEnd(l.pos)
+		end = skipSpaces(l.text, end)
+		if end < len(l.text) && l.text[end] == '-' {
+			end++
+		}
+		if end >= len(l.text) || !isDigit(l.text[end]) {
+			return fmt.Errorf("unexpected '%s' in expression: %s", l.text[start:l.symbolEnd(start)], l.text)
+		}
+		return l.scanAmount(l.numberEnd(end), false)
+
+	default:
+		for _, operator := range operators {
+			if strings.HasPrefix(l.text[l.pos:], operator) {
+				l.emit(tokenOperator, operator, start)
+				l.pos += len(operator)
+				return nil
+			}
+		}
+		return fmt.Errorf("unexpected '%c' in expression: %s", r, l.text)
+	}
+	return nil
+}
+
+// scanAmount emits the amount from the current position up to end. A number
+// may be followed by a suffix commodity unless the word after it is a keyword
+// or a function call.
+func (l *lexer) scanAmount(end int, suffix bool) error {
+	if suffix {
+		symbolStart := skipSpaces(l.text, end)
+		if symbolStart < len(l.text) {
+			r, _ := utf8.DecodeRuneInString(l.text[symbolStart:])
+			if isSymbolRune(r) || unicode.IsLetter(r) {
+				symbolEnd := l.symbolEnd(symbolStart)
+				word := l.text[symbolStart:symbolEnd]
+				called := strings.HasPrefix(l.text[skipSpaces(l.text, symbolEnd):], "(")
+				if !keywords[word] && !called {
+					end = symbolEnd
+				}
+			}
+		}
+	}
+
+	text := l.text[l.pos:end]
+	amount, err := domain.ParseAmount(text)
+	if err != nil {
+		return fmt.Errorf("invalid amount '%s' in expression: %s", text, l.text)
+	}
+	l.tokens = append(l.tokens, token{kind: tokenAmount, text: text, amount: amount, pos: l.pos})
+	l.pos = end
+	return nil
+}
+
+// numberEnd returns the end of the number starting at pos. Separators only
+// belong to the number when a digit follows them, so "max(1, 2)" works.
+func (l *lexer) numberEnd(pos int) int {
+	for pos < len(l.text) {
+		c := l.text[pos]
+		if isDigit(c) || ((c == '.' || c == ',') && pos+1 < len(l.text) && isDigit(l.text[pos+1])) {
+			pos++
+			continue
+		}
+		break
+	}
+	return pos
+}
+
+// symbolEnd returns the end of the commodity symbol starting at pos
+func (l *lexer) symbolEnd(pos int) int {
+	for pos < len(l.text) {
+		r, size := utf8.DecodeRuneInString(l.text[pos:])
+		if !isSymbolRune(r) && !unicode.IsLetter(r) {
+			break
+		}
+		pos += size
+	}
+	return pos
+}
+
+func (l *lexer) emit(kind tokenKind, text string, pos int) {
+	l.tokens = append(l.tokens, token{kind: kind, text: text, pos: pos})
+}
+
+// isSymbolRune reports whether r may appear in a commodity symbol other than
+// as a letter, as "$" or "€" do
+func isSymbolRune(r rune) bool {
+	return !unicode.IsLetter(r) && !unicode.IsDigit(r) && !unicode.IsSpace(r) &&
+		r != '_' && !strings.ContainsRune(operatorChars, r)
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func skipSpaces(text string, pos int) int {
+	for pos < len(text) && (text[pos] == ' ' || text[pos] == '\t') {
+		pos++
+	}
+	return pos
+}
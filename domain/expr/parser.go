@@ -0,0 +1,332 @@
+package expr
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/hirosato/gledger/domain"
+)
+
+// Expr is a compiled value expression, such as "amount > $100" or
+// "account =~ /food/ & date >= [2012/03]"
+type Expr struct {
+	text string
+	root node
+}
+
+// Parse compiles a value expression. Operators, from lowest precedence:
+//
+//	c ? a : b            conditional
+//	|  ||  or            logical or
+//	&  &&  and           logical and
+//	== != < <= > >= =~ !~  comparison and regex match
+//	+ -                  addition and subtraction
+//	* /                  multiplication and division
+//	- ! not              negation
+//
+// Operands are amounts ("$10", "2.5 EUR", "3"), 'strings', /regexes/,
+// [dates], variables and function calls.
+func Parse(text string) (*Expr, error) {
+	tokens, err := tokenize(text)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &parser{text: text, tokens: tokens}
+	root, err := p.parseConditional()
+	if err != nil {
+		return nil, err
+	}
+	if p.peek().kind != tokenEOF {
+		return nil, p.unexpected()
+	}
+	return &Expr{text: strings.TrimSpace(text), root: root}, nil
+}
+
+// String returns the expression as written
+func (e *Expr) String() string {
+	return e.text
+}
+
+// Eval evaluates the expression in a context
+func (e *Expr) Eval(ctx *Context) (Value, error) {
+	if ctx == nil {
+		ctx = &Context{}
+	}
+	return e.root.eval(ctx)
+}
+
+// Test evaluates the expression as a predicate. A bare regex matches the
+// account, as it does in ledger.
+func (e *Expr) Test(ctx *Context) (bool, error) {
+	value, err := e.Eval(ctx)
+	if err != nil {
+		return false, err
+	}
+	if value.kind == KindMask {
+		return value.mask.MatchString(ctx.accountName()), nil
+	}
+	return value.Truthy(), nil
+}
+
+// parser is a recursive-descent parser over expression tokens
+type parser struct {
+	text   string
+	tokens []token
+	pos    int
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	t := p.tokens[p.pos]
+	if t.kind != tokenEOF {
+		p.pos++
+	}
+	return t
+}
+
+// accept consumes the next token if it is one of the given operators or
+// keywords and returns it
+func (p *parser) accept(operators ...string) (string, bool) {
+	t := p.peek()
+	if t.kind != tokenOperator && t.kind != tokenIdent {
+		return "", false
+	}
+	for _, operator := range operators {
+		if t.text == operator {
+			p.pos++
+			return operator, true
+		}
+	}
+	return "", false
+}
+
+func (p *parser) unexpected() error {
+	t := p.peek()
+	if t.kind == tokenEOF {
+		return fmt.Errorf("expression ends unexpectedly: %s", p.text)
+	}
+	return fmt.Errorf("unexpected '%s' in expression: %s", p.text[t.pos:t.pos+tokenWidth(p.text, t)], p.text)
+}
+
+// tokenWidth returns how much of the source text a token covers
+func tokenWidth(text string, t token) int {
+	switch t.kind {
+	case tokenString, tokenMask, tokenDate:
+		return len(t.text) + 2
+	}
+	if t.pos+len(t.text) > len(text) {
+		return len(text) - t.pos
+	}
+	return len(t.text)
+}
+
+func (p *parser) parseConditional() (node, error) {
+	condition, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := p.accept("?"); !ok {
+		return condition, nil
+	}
+
+	then, err := p.parseConditional()
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := p.accept(":"); !ok {
+		return nil, p.unexpected()
+	}
+	otherwise, err := p.parseConditional()
+	if err != nil {
+		return nil, err
+	}
+	return &conditionalNode{condition: condition, then: then, otherwise: otherwise}, nil
+}
+
+func (p *parser) parseOr() (node, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		if _, ok := p.accept("|", "||", "or"); !ok {
+			return left, nil
+		}
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = &logicalNode{and: false, left: left, right: right}
+	}
+}
+
+func (p *parser) parseAnd() (node, error) {
+	left, err := p.parseComparison()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		if _, ok := p.accept("&", "&&", "and"); !ok {
+			return left, nil
+		}
+		right, err := p.parseComparison()
+		if err != nil {
+			return nil, err
+		}
+		left = &logicalNode{and: true, left: left, right: right}
+	}
+}
+
+func (p *parser) parseComparison() (node, error) {
+	left, err := p.parseAdditive()
+	if err != nil {
+		return nil, err
+	}
+	operator, ok := p.accept("==", "=", "!=", "<", "<=", ">", ">=", "=~", "!~")
+	if !ok {
+		return left, nil
+	}
+	right, err := p.parseAdditive()
+	if err != nil {
+		return nil, err
+	}
+	if operator == "=" {
+		operator = "=="
+	}
+	return &binaryNode{operator: operator, left: left, right: right}, nil
+}
+
+func (p *parser) parseAdditive() (node, error) {
+	left, err := p.parseMultiplicative()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		operator, ok := p.accept("+", "-")
+		if !ok {
+			return left, nil
+		}
+		right, err := p.parseMultiplicative()
+		if err != nil {
+			return nil, err
+		}
+		left = &binaryNode{operator: operator, left: left, right: right}
+	}
+}
+
+func (p *parser) parseMultiplicative() (node, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		operator, ok := p.accept("*", "/")
+		if !ok {
+			return left, nil
+		}
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = &binaryNode{operator: operator, left: left, right: right}
+	}
+}
+
+func (p *parser) parseUnary() (node, error) {
+	operator, ok := p.accept("-", "!", "not")
+	if !ok {
+		return p.parsePrimary()
+	}
+	operand, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	if operator == "not" {
+		operator = "!"
+	}
+	return &unaryNode{operator: operator, operand: operand}, nil
+}
+
+func (p *parser) parsePrimary() (node, error) {
+	t := p.peek()
+	switch t.kind {
+	case tokenAmount:
+		p.next()
+		return &literalNode{value: AmountValue(t.amount)}, nil
+
+	case tokenString:
+		p.next()
+		return &literalNode{value: StringValue(t.text)}, nil
+
+	case tokenMask:
+		p.next()
+		mask, err := compileMask(t.text)
+		if err != nil {
+			return nil, err
+		}
+		return &literalNode{value: MaskValue(mask)}, nil
+
+	case tokenDate:
+		p.next()
+		date, err := domain.ParseDate(t.text)
+		if err != nil {
+			return nil, err
+		}
+		return &literalNode{value: DateValue(date)}, nil
+
+	case tokenIdent:
+		if keywords[t.text] {
+			return nil, p.unexpected()
+		}
+		p.next()
+		if _, ok := p.accept("("); !ok {
+			return &variableNode{name: t.text}, nil
+		}
+		return p.parseCall(t.text)
+
+	case tokenOperator:
+		if t.text == "(" {
+			p.next()
+			inner, err := p.parseConditional()
+			if err != nil {
+				return nil, err
+			}
+			if _, ok := p.accept(")"); !ok {
+				return nil, fmt.Errorf("missing ')' in expression: %s", p.text)
+			}
+			return inner, nil
+		}
+	}
+	return nil, p.unexpected()
+}
+
+// parseCall parses the arguments of a function call after its "("
+func (p *parser) parseCall(name string) (node, error) {
+	function, ok := functions[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown function '%s' in expression: %s", name, p.text)
+	}
+
+	call := &callNode{name: name, function: function}
+	if _, ok := p.accept(")"); ok {
+		return call, nil
+	}
+	for {
+		argument, err := p.parseConditional()
+		if err != nil {
+			return nil, err
+		}
+		call.arguments = append(call.arguments, argument)
+
+		if _, ok := p.accept(")"); ok {
+			return call, nil
+		}
+		if _, ok := p.accept(","); !ok {
+			return nil, p.unexpected()
+		}
+	}
+}
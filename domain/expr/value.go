@@ -0,0 +1,378 @@
+package expr
+
+import (
+	"fmt"
+	"math/big"
+	"regexp"
+	"time"
+
+	"github.com/hirosato/gledger/domain"
+)
+
+// Kind is the type of a Value
+type Kind int
+
+const (
+	KindNull Kind = iota
+	KindBool
+	KindAmount
+	KindBalance
+	KindString
+	KindDate
+	KindMask
+)
+
+// Value is the result of evaluating an expression. Plain numbers are amounts
+// whose commodity has an empty symbol.
+type Value struct {
+	kind    Kind
+	boolean bool
+	amount  *domain.Amount
+	balance *domain.Balance
+	text    string
+	date    time.Time
+	mask    *regexp.Regexp
+}
+
+// Null is the value of anything that doesn't apply, such as the amount of a
+// posting that has none
+var Null = Value{}
+
+// BoolValue returns a boolean value
+func BoolValue(b bool) Value {
+	return Value{kind: KindBool, boolean: b}
+}
+
+// AmountValue returns an amount value, or Null for a nil amount
+func AmountValue(amount *domain.Amount) Value {
+	if amount == nil {
+		return Null
+	}
+	return Value{kind: KindAmount, amount: amount}
+}
+
+// NumberValue returns an amount without a commodity
+func NumberValue(number *big.Rat) Value {
+	commodity := domain.NewCommodity("")
+	commodity.Precision = 0
+	return AmountValue(domain.NewAmount(number, commodity))
+}
+
+// BalanceValue returns a balance value. Balances holding at most one
+// commodity become amounts, so they compare and combine like amounts.
+func BalanceValue(balance *domain.Balance) Value {
+	if balance == nil {
+		return Null
+	}
+	amounts := balance.GetAmounts()
+	switch len(amounts) {
+	case 0:
+		return NumberValue(new(big.Rat))
+	case 1:
+		return AmountValue(amounts[0])
+	}
+	return Value{kind: KindBalance, balance: balance}
+}
+
+// StringValue returns a string value
+func StringValue(s string) Value {
+	return Value{kind: KindString, text: s}
+}
+
+// DateValue returns a date value
+func DateValue(t time.Time) Value {
+	return Value{kind: KindDate, date: t}
+}
+
+// MaskValue returns a regular expression value
+func MaskValue(mask *regexp.Regexp) Value {
+	return Value{kind: KindMask, mask: mask}
+}
+
+// Kind returns the type of the value
+func (v Value) Kind() Kind {
+	return v.kind
+}
+
+// Truthy reports whether the value counts as true: non-zero amounts and
+// balances, non-empty strings and set dates are true
+func (v Value) Truthy() bool {
+	switch v.kind {
+	case KindBool:
+		return v.boolean
+	case KindAmount:
+		return !v.amount.IsZero()
+	case KindBalance:
+		return !v.balance.IsZero()
+	case KindString:
+		return v.text != ""
+	case KindDate:
+		return !v.date.IsZero()
+	case KindMask:
+		return true
+	}
+	return false
+}
+
+// Amount returns the value as an amount
+func (v Value) Amount() (*domain.Amount, error) {
+	if v.kind != KindAmount {
+		return nil, fmt.Errorf("expected an amount, got %s", v.describe())
+	}
+	return v.amount, nil
+}
+
+// Balance returns the value as a balance
+func (v Value) Balance() (*domain.Balance, error) {
+	switch v.kind {
+	case KindAmount:
+		return domain.NewBalanceFromAmount(v.amount), nil
+	case KindBalance:
+		return v.balance, nil
+	}
+	return nil, fmt.Errorf("expected an amount, got %s", v.describe())
+}
+
+// Date returns the value as a date
+func (v Value) Date() (time.Time, error) {
+	if v.kind != KindDate {
+		return time.Time{}, fmt.Errorf("expected a date, got %s", v.describe())
+	}
+	return v.date, nil
+}
+
+// Mask returns the value as a regular expression. Strings are compiled.
+func (v Value) Mask() (*regexp.Regexp, error) {
+	switch v.kind {
+	case KindMask:
+		return v.mask, nil
+	case KindString:
+		return compileMask(v.text)
+	}
+	return nil, fmt.Errorf("expected a regex, got %s", v.describe())
+}
+
+// String formats the value for display
+func (v Value) String() string {
+	switch v.kind {
+	case KindBool:
+		if v.boolean {
+			return "true"
+		}
+		return "false"
+	case KindAmount:
+		return v.amount.Format(true)
+	case KindBalance:
+		return v.balance.String()
+	case KindString:
+		return v.text
+	case KindDate:
+		return v.date.Format("2006/01/02")
+	case KindMask:
+		return "/" + v.mask.String() + "/"
+	}
+	return ""
+}
+
+// describe names the value's type for error messages
+func (v Value) describe() string {
+	switch v.kind {
+	case KindBool:
+		return "a boolean"
+	case KindAmount:
+		return "an amount"
+	case KindBalance:
+		return "a balance"
+	case KindString:
+		return "a string"
+	case KindDate:
+		return "a date"
+	case KindMask:
+		return "a regex"
+	}
+	return "null"
+}
+
+// compileMask compiles a case-insensitive regular expression, as ledger's masks are
+func compileMask(pattern string) (*regexp.Regexp, error) {
+	mask, err := regexp.Compile("(?i)" + pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid regex /%s/: %w", pattern, err)
+	}
+	return mask, nil
+}
+
+// add returns left + right
+func add(left, right Value) (Value, error) {
+	switch {
+	case left.kind == KindString && right.kind == KindString:
+		return StringValue(left.text + right.text), nil
+	case left.kind == KindAmount && right.kind == KindAmount:
+		return combineAmounts(left.amount, right.amount, false), nil
+	}
+	return combineBalances(left, right, false)
+}
+
+// subtract returns left - right
+func subtract(left, right Value) (Value, error) {
+	if left.kind == KindAmount && right.kind == KindAmount {
+		return combineAmounts(left.amount, right.amount, true), nil
+	}
+	return combineBalances(left, right, true)
+}
+
+// combineAmounts adds or subtracts two amounts. A number without a commodity
+// takes the other amount's commodity; different commodities give a balance.
+func combineAmounts(left, right *domain.Amount, negate bool) Value {
+	if negate {
+		right = right.Negate()
+	}
+	switch {
+	case left.Commodity.Symbol == right.Commodity.Symbol:
+		return AmountValue(domain.NewAmount(new(big.Rat).Add(left.Number, right.Number), left.Commodity))
+	case left.Commodity.Symbol == "":
+		return AmountValue(domain.NewAmount(new(big.Rat).Add(left.Number, right.Number), right.Commodity))
+	case right.Commodity.Symbol == "":
+		return AmountValue(domain.NewAmount(new(big.Rat).Add(left.Number, right.Number), left.Commodity))
+	}
+	balance := domain.NewBalanceFromAmount(left)
+	balance.Add(right)
+	return BalanceValue(balance)
+}
+
+// combineBalances adds or subtracts values of which at least one is a balance
+func combineBalances(left, right Value, negate bool) (Value, error) {
+	leftBalance, err := left.Balance()
+	if err != nil {
+		return Null, fmt.Errorf("cannot add %s and %s", left.describe(), right.describe())
+	}
+	rightBalance, err := right.Balance()
+	if err != nil {
+		return Null, fmt.Errorf("cannot add %s and %s", left.describe(), right.describe())
+	}
+	result := leftBalance.Copy()
+	if negate {
+		result.SubtractBalance(rightBalance)
+	} else {
+		result.AddBalance(rightBalance)
+	}
+	return BalanceValue(result), nil
+}
+
+// multiply returns left * right. The result keeps the commodity of whichever
+// side has one.
+func multiply(left, right Value) (Value, error) {
+	if left.kind == KindBalance && right.kind == KindAmount && right.amount.Commodity.Symbol == "" {
+		return scaleBalance(left.balance, right.amount.Number), nil
+	}
+	if right.kind == KindBalance && left.kind == KindAmount && left.amount.Commodity.Symbol == "" {
+		return scaleBalance(right.balance, left.amount.Number), nil
+	}
+	if left.kind != KindAmount || right.kind != KindAmount {
+		return Null, fmt.Errorf("cannot multiply %s by %s", left.describe(), right.describe())
+	}
+
+	number := new(big.Rat).Mul(left.amount.Number, right.amount.Number)
+	commodity := left.amount.Commodity
+	if commodity.Symbol == "" {
+		commodity = right.amount.Commodity
+	}
+	return AmountValue(domain.NewAmount(number, commodity)), nil
+}
+
+// divide returns left / right
+func divide(left, right Value) (Value, error) {
+	if right.kind == KindAmount && right.amount.IsZero() {
+		return Null, fmt.Errorf("divide by zero")
+	}
+	if left.kind == KindBalance && right.kind == KindAmount && right.amount.Commodity.Symbol == "" {
+		return scaleBalance(left.balance, new(big.Rat).Inv(right.amount.Number)), nil
+	}
+	if left.kind != KindAmount || right.kind != KindAmount {
+		return Null, fmt.Errorf("cannot divide %s by %s", left.describe(), right.describe())
+	}
+
+	number := new(big.Rat).Quo(left.amount.Number, right.amount.Number)
+	commodity := left.amount.Commodity
+	if commodity.Symbol == "" {
+		commodity = right.amount.Commodity
+	}
+	return AmountValue(domain.NewAmount(number, commodity)), nil
+}
+
+// scaleBalance multiplies every amount of a balance by a number
+func scaleBalance(balance *domain.Balance, factor *big.Rat) Value {
+	result := domain.NewBalance()
+	for _, amount := range balance.GetAmounts() {
+		result.Add(amount.Multiply(factor))
+	}
+	return BalanceValue(result)
+}
+
+// negate returns -value
+func negate(value Value) (Value, error) {
+	switch value.kind {
+	case KindAmount:
+		return AmountValue(value.amount.Negate()), nil
+	case KindBalance:
+		return BalanceValue(value.balance.Negate()), nil
+	}
+	return Null, fmt.Errorf("cannot negate %s", value.describe())
+}
+
+// Compare orders two values, as sort expressions such as --period-sort do
+func Compare(left, right Value) (int, error) {
+	return compare(left, right)
+}
+
+// compare returns -1, 0 or 1 as left is less than, equal to or greater than
+// right. Amounts compare by number when either lacks a commodity.
+func compare(left, right Value) (int, error) {
+	switch {
+	case left.kind == KindAmount && right.kind == KindAmount:
+		l, r := left.amount, right.amount
+		if l.Commodity.Symbol != r.Commodity.Symbol && l.Commodity.Symbol != "" && r.Commodity.Symbol != "" {
+			return 0, fmt.Errorf("cannot compare %s with %s", l.Format(true), r.Format(true))
+		}
+		return l.Number.Cmp(r.Number), nil
+	case left.kind == KindString && right.kind == KindString:
+		switch {
+		case left.text < right.text:
+			return -1, nil
+		case left.text > right.text:
+			return 1, nil
+		}
+		return 0, nil
+	case left.kind == KindDate && right.kind == KindDate:
+		return left.date.Compare(right.date), nil
+	}
+	return 0, fmt.Errorf("cannot compare %s with %s", left.describe(), right.describe())
+}
+
+// equal reports whether two values are equal. Values of different types are
+// never equal.
+func equal(left, right Value) (bool, error) {
+	switch {
+	case left.kind == KindBool && right.kind == KindBool:
+		return left.boolean == right.boolean, nil
+	case left.kind == KindNull || right.kind == KindNull:
+		return left.kind == right.kind, nil
+	case left.kind == KindBalance || right.kind == KindBalance:
+		leftBalance, err := left.Balance()
+		if err != nil {
+			return false, nil
+		}
+		rightBalance, err := right.Balance()
+		if err != nil {
+			return false, nil
+		}
+		return leftBalance.Equals(rightBalance), nil
+	case left.kind != right.kind:
+		return false, nil
+	}
+	order, err := compare(left, right)
+	if err != nil {
+		return false, err
+	}
+	return order == 0, nil
+}
@@ -0,0 +1,116 @@
+package domain
+
+import (
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+)
+
+// Lot is a quantity of a commodity acquired at a cost and still held
+type Lot struct {
+	Account  string
+	Quantity *Amount   // Quantity still held
+	Cost     *Amount   // Cost of one unit
+	Date     time.Time // Acquisition date
+	Label    string
+}
+
+// CostBasis returns what the lot's remaining quantity cost in total
+func (l *Lot) CostBasis() *Amount {
+	return l.Cost.Multiply(l.Quantity.Number)
+}
+
+// Matches reports whether the lot fits a sale's lot annotation: every part
+// the annotation gives must agree with the lot
+func (l *Lot) Matches(annotation *CostBasis, quantity *Amount) bool {
+	if annotation == nil {
+		return true
+	}
+	if cost := annotation.UnitCost(quantity); cost != nil {
+		if cost.Commodity.Symbol != l.Cost.Commodity.Symbol || cost.Number.Cmp(l.Cost.Number) != 0 {
+			return false
+		}
+	}
+	if annotation.Date != nil && !annotation.Date.Equal(l.Date) {
+		return false
+	}
+	if annotation.Label != "" && annotation.Label != l.Label {
+		return false
+	}
+	return true
+}
+
+// LotSale is the part of a lot relieved by a sale, with the gain it realized
+type LotSale struct {
+	Lot      Lot       // The lot as sold from: its quantity is the quantity sold
+	Date     time.Time // Sale date
+	Payee    string
+	Proceeds *Amount  // What the quantity sold fetched, in the lot's cost commodity
+	Posting  *Posting // The sale's posting
+}
+
+// Gain returns the sale's proceeds minus the cost of the quantity sold
+func (s *LotSale) Gain() *Amount {
+	return s.Proceeds.Subtract(s.Lot.CostBasis())
+}
+
+// BookingMethod selects which lots a sale relieves
+type BookingMethod int
+
+const (
+	BookingFIFO    BookingMethod = iota // Oldest lots first
+	BookingLIFO                         // Newest lots first
+	BookingAverage                      // All lots at their average cost
+	BookingStrict                       // Only the lots the sale's annotation names
+)
+
+var bookingMethods = map[string]BookingMethod{
+	"fifo":    BookingFIFO,
+	"lifo":    BookingLIFO,
+	"average": BookingAverage,
+	"strict":  BookingStrict,
+}
+
+// ParseBookingMethod parses "fifo", "lifo", "average" or "strict"
+func ParseBookingMethod(name string) (BookingMethod, error) {
+	method, ok := bookingMethods[strings.ToLower(name)]
+	if !ok {
+		return BookingFIFO, fmt.Errorf("unknown booking method: %s", name)
+	}
+	return method, nil
+}
+
+// averageCost returns the average unit cost of lots that share a cost commodity
+func averageCost(lots []*Lot) *Amount {
+	quantity := new(big.Rat)
+	total := new(big.Rat)
+	for _, lot := range lots {
+		quantity.Add(quantity, lot.Quantity.Number)
+		total.Add(total, lot.CostBasis().Number)
+	}
+	if quantity.Sign() == 0 {
+		return nil
+	}
+	return NewAmount(total.Quo(total, quantity), lots[0].Cost.Commodity)
+}
+
+// AverageLots sets every lot's cost to their average, as average-cost
+// booking does before a sale
+func AverageLots(lots []*Lot) {
+	if len(lots) == 0 {
+		return
+	}
+	for _, lot := range lots[1:] {
+		if lot.Cost.Commodity.Symbol != lots[0].Cost.Commodity.Symbol {
+			return
+		}
+	}
+	average := averageCost(lots)
+	if average == nil {
+		return
+	}
+	for _, lot := range lots {
+		lot.Cost = average
+	}
+}
@@ -0,0 +1,328 @@
+package domain
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// IntervalUnit is the unit of a reporting or recurrence interval
+type IntervalUnit int
+
+const (
+	IntervalNone IntervalUnit = iota
+	IntervalDay
+	IntervalWeek
+	IntervalMonth
+	IntervalQuarter
+	IntervalYear
+)
+
+// Interval is a step of Count units, such as "every 2 weeks"
+type Interval struct {
+	Unit  IntervalUnit
+	Count int
+}
+
+// IsZero reports whether no interval was given
+func (i Interval) IsZero() bool {
+	return i.Unit == IntervalNone
+}
+
+// Next returns the date one interval after t
+func (i Interval) Next(t time.Time) time.Time {
+	count := i.Count
+	if count == 0 {
+		count = 1
+	}
+	switch i.Unit {
+	case IntervalDay:
+		return t.AddDate(0, 0, count)
+	case IntervalWeek:
+		return t.AddDate(0, 0, 7*count)
+	case IntervalMonth:
+		return t.AddDate(0, count, 0)
+	case IntervalQuarter:
+		return t.AddDate(0, 3*count, 0)
+	case IntervalYear:
+		return t.AddDate(count, 0, 0)
+	}
+	return t
+}
+
+// Align returns the start of the interval unit containing t. Weeks start on
+// Sunday, as in ledger.
+func (i Interval) Align(t time.Time) time.Time {
+	t = time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	switch i.Unit {
+	case IntervalWeek:
+		return t.AddDate(0, 0, -int(t.Weekday()))
+	case IntervalMonth:
+		return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+	case IntervalQuarter:
+		month := time.Month((int(t.Month())-1)/3*3 + 1)
+		return time.Date(t.Year(), month, 1, 0, 0, 0, 0, time.UTC)
+	case IntervalYear:
+		return time.Date(t.Year(), time.January, 1, 0, 0, 0, 0, time.UTC)
+	}
+	return t
+}
+
+// DateRange is the half-open range of dates [Begin, End)
+type DateRange struct {
+	Begin time.Time
+	End   time.Time
+}
+
+// Contains reports whether date falls within the range
+func (r DateRange) Contains(date time.Time) bool {
+	return !date.Before(r.Begin) && date.Before(r.End)
+}
+
+// Period is a ledger period expression such as "monthly from 2012/01 to 2012/06".
+// Begin is inclusive and End exclusive; either may be nil when unbounded.
+type Period struct {
+	Interval Interval
+	Begin    *time.Time
+	End      *time.Time
+}
+
+// Split divides the range [begin, end) into consecutive ranges of the
+// period's interval, aligned to the interval's unit. Without an interval
+// the whole range is returned.
+func (p *Period) Split(begin, end time.Time) []DateRange {
+	if p.Interval.IsZero() {
+		return []DateRange{{Begin: begin, End: end}}
+	}
+
+	var ranges []DateRange
+	for start := p.Interval.Align(begin); start.Before(end); start = p.Interval.Next(start) {
+		ranges = append(ranges, DateRange{Begin: start, End: p.Interval.Next(start)})
+	}
+	return ranges
+}
+
+var intervalWords = map[string]Interval{
+	"daily":     {IntervalDay, 1},
+	"weekly":    {IntervalWeek, 1},
+	"biweekly":  {IntervalWeek, 2},
+	"monthly":   {IntervalMonth, 1},
+	"bimonthly": {IntervalMonth, 2},
+	"quarterly": {IntervalQuarter, 1},
+	"yearly":    {IntervalYear, 1},
+	"annually":  {IntervalYear, 1},
+}
+
+var intervalUnits = map[string]IntervalUnit{
+	"day":     IntervalDay,
+	"week":    IntervalWeek,
+	"month":   IntervalMonth,
+	"quarter": IntervalQuarter,
+	"year":    IntervalYear,
+}
+
+// ParsePeriod parses a period expression, resolving relative dates such as
+// "last month" against the current time
+func ParsePeriod(text string) (*Period, error) {
+	return ParsePeriodAt(text, time.Now())
+}
+
+// ParsePeriodAt parses a period expression made of an optional interval
+// ("monthly", "every 2 weeks") and optional bounds ("from DATE", "to DATE",
+// "until DATE", "in DATE" or a bare DATE). Dates may be a year, a year and
+// month, or a full date, or be relative to now: "today", "yesterday",
+// "this/last/next week/month/quarter/year", a quarter such as "q1" or a
+// month name, either of which may be followed by a year.
+func ParsePeriodAt(text string, now time.Time) (*Period, error) {
+	period := &Period{}
+	words := strings.Fields(strings.ToLower(text))
+
+	for i := 0; i < len(words); i++ {
+		word := words[i]
+		if interval, ok := intervalWords[word]; ok {
+			period.Interval = interval
+			continue
+		}
+
+		switch word {
+		case "every":
+			interval, consumed, err := parseEvery(words[i+1:])
+			if err != nil {
+				return nil, err
+			}
+			period.Interval = interval
+			i += consumed
+		case "from", "since", "to", "until", "in":
+			if i+1 >= len(words) {
+				return nil, fmt.Errorf("missing date after '%s' in period: %s", word, text)
+			}
+			begin, end, consumed, err := parseSpan(words[i+1:], now)
+			if err != nil {
+				return nil, err
+			}
+			i += consumed
+			switch word {
+			case "from", "since":
+				period.Begin = &begin
+			case "to", "until":
+				period.End = &begin
+			default:
+				period.Begin, period.End = &begin, &end
+			}
+		default:
+			begin, end, consumed, err := parseSpan(words[i:], now)
+			if err != nil || period.Begin != nil || period.End != nil {
+				return nil, fmt.Errorf("invalid period: %s", text)
+			}
+			i += consumed - 1
+			period.Begin, period.End = &begin, &end
+		}
+	}
+
+	return period, nil
+}
+
+// ParseDate parses a date such as "2012/03/15", "2012-03" or "2012", giving
+// the first day of a month or year
+func ParseDate(text string) (time.Time, error) {
+	begin, _, err := parsePeriodDate(text)
+	return begin, err
+}
+
+var monthNames = map[string]time.Month{
+	"jan": time.January, "january": time.January,
+	"feb": time.February, "february": time.February,
+	"mar": time.March, "march": time.March,
+	"apr": time.April, "april": time.April,
+	"may": time.May,
+	"jun": time.June, "june": time.June,
+	"jul": time.July, "july": time.July,
+	"aug": time.August, "august": time.August,
+	"sep": time.September, "september": time.September,
+	"oct": time.October, "october": time.October,
+	"nov": time.November, "november": time.November,
+	"dec": time.December, "december": time.December,
+}
+
+// parseSpan parses the date range at the start of words and returns it with
+// the number of words used
+func parseSpan(words []string, now time.Time) (time.Time, time.Time, int, error) {
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	day := Interval{Unit: IntervalDay, Count: 1}
+	word := words[0]
+
+	switch word {
+	case "today":
+		return today, day.Next(today), 1, nil
+	case "yesterday":
+		begin := today.AddDate(0, 0, -1)
+		return begin, today, 1, nil
+	case "tomorrow":
+		begin := day.Next(today)
+		return begin, day.Next(begin), 1, nil
+	case "this", "last", "next":
+		if len(words) < 2 {
+			return time.Time{}, time.Time{}, 0, fmt.Errorf("missing unit after '%s'", word)
+		}
+		unit, ok := intervalUnits[strings.TrimSuffix(words[1], "s")]
+		if !ok {
+			return time.Time{}, time.Time{}, 0, fmt.Errorf("unknown period: %s %s", word, words[1])
+		}
+		interval := Interval{Unit: unit, Count: 1}
+		begin := interval.Align(today)
+		switch word {
+		case "last":
+			begin = Interval{Unit: unit, Count: -1}.Next(begin)
+		case "next":
+			begin = interval.Next(begin)
+		}
+		return begin, interval.Next(begin), 2, nil
+	}
+
+	// Month names and quarters are of the current year unless one follows
+	year, consumed := today.Year(), 1
+	if len(words) > 1 && len(words[1]) == 4 {
+		if n, err := strconv.Atoi(words[1]); err == nil {
+			year, consumed = n, 2
+		}
+	}
+
+	if month, ok := monthNames[word]; ok {
+		begin := time.Date(year, month, 1, 0, 0, 0, 0, time.UTC)
+		return begin, begin.AddDate(0, 1, 0), consumed, nil
+	}
+
+	if len(word) == 2 && word[0] == 'q' && word[1] >= '1' && word[1] <= '4' {
+		month := time.Month(int(word[1]-'1')*3 + 1)
+		begin := time.Date(year, month, 1, 0, 0, 0, 0, time.UTC)
+		return begin, begin.AddDate(0, 3, 0), consumed, nil
+	}
+
+	begin, end, err := parsePeriodDate(word)
+	if err != nil {
+		return time.Time{}, time.Time{}, 0, err
+	}
+	return begin, end, 1, nil
+}
+
+// parseEvery parses the words after "every": "day", "2 weeks" and so on
+func parseEvery(words []string) (Interval, int, error) {
+	if len(words) == 0 {
+		return Interval{}, 0, fmt.Errorf("missing interval after 'every'")
+	}
+
+	count := 1
+	consumed := 0
+	if n, err := strconv.Atoi(words[0]); err == nil {
+		if len(words) < 2 {
+			return Interval{}, 0, fmt.Errorf("missing interval unit after 'every %d'", n)
+		}
+		count = n
+		words = words[1:]
+		consumed++
+	}
+
+	unit, ok := intervalUnits[strings.TrimSuffix(words[0], "s")]
+	if !ok {
+		return Interval{}, 0, fmt.Errorf("unknown interval: %s", words[0])
+	}
+	return Interval{Unit: unit, Count: count}, consumed + 1, nil
+}
+
+// parsePeriodDate parses "2012", "2012/03" or "2012/03/15" and returns the
+// range of dates it covers
+func parsePeriodDate(text string) (time.Time, time.Time, error) {
+	parts := strings.FieldsFunc(text, func(r rune) bool {
+		return r == '/' || r == '-' || r == '.'
+	})
+
+	numbers := make([]int, len(parts))
+	for i, part := range parts {
+		n, err := strconv.Atoi(part)
+		if err != nil {
+			return time.Time{}, time.Time{}, fmt.Errorf("invalid date: %s", text)
+		}
+		numbers[i] = n
+	}
+	if (len(numbers) > 1 && (numbers[1] < 1 || numbers[1] > 12)) || (len(numbers) > 2 && (numbers[2] < 1 || numbers[2] > 31)) {
+		return time.Time{}, time.Time{}, fmt.Errorf("invalid date: %s", text)
+	}
+
+	switch len(numbers) {
+	case 1:
+		begin := time.Date(numbers[0], time.January, 1, 0, 0, 0, 0, time.UTC)
+		return begin, begin.AddDate(1, 0, 0), nil
+	case 2:
+		begin := time.Date(numbers[0], time.Month(numbers[1]), 1, 0, 0, 0, 0, time.UTC)
+		return begin, begin.AddDate(0, 1, 0), nil
+	case 3:
+		begin := time.Date(numbers[0], time.Month(numbers[1]), numbers[2], 0, 0, 0, 0, time.UTC)
+		if begin.Day() != numbers[2] {
+			// time.Date moved a day past the month's end into the next
+			return time.Time{}, time.Time{}, fmt.Errorf("invalid date: %s", text)
+		}
+		return begin, begin.AddDate(0, 0, 1), nil
+	}
+	return time.Time{}, time.Time{}, fmt.Errorf("invalid date: %s", text)
+}
@@ -0,0 +1,136 @@
+package domain
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParsePeriod(t *testing.T) {
+	tests := []struct {
+		input    string
+		interval Interval
+		begin    string
+		end      string
+	}{
+		{"monthly", Interval{IntervalMonth, 1}, "", ""},
+		{"Monthly", Interval{IntervalMonth, 1}, "", ""},
+		{"every 2 weeks", Interval{IntervalWeek, 2}, "", ""},
+		{"every day", Interval{IntervalDay, 1}, "", ""},
+		{"2012", Interval{}, "2012-01-01", "2013-01-01"},
+		{"in 2012/03", Interval{}, "2012-03-01", "2012-04-01"},
+		{"quarterly from 2012/01 to 2012/07", Interval{IntervalQuarter, 1}, "2012-01-01", "2012-07-01"},
+		{"yearly since 2012-06-15", Interval{IntervalYear, 1}, "2012-06-15", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			period, err := ParsePeriod(tt.input)
+			if err != nil {
+				t.Fatalf("Failed to parse period: %v", err)
+			}
+			if period.Interval != tt.interval {
+				t.Errorf("Expected interval %v, got %v", tt.interval, period.Interval)
+			}
+			if got := formatOptionalDate(period.Begin); got != tt.begin {
+				t.Errorf("Expected begin '%s', got '%s'", tt.begin, got)
+			}
+			if got := formatOptionalDate(period.End); got != tt.end {
+				t.Errorf("Expected end '%s', got '%s'", tt.end, got)
+			}
+		})
+	}
+
+	for _, input := range []string{"every", "every 2", "fortnightly", "from", "2012/13"} {
+		if _, err := ParsePeriod(input); err == nil {
+			t.Errorf("Expected error for period '%s'", input)
+		}
+	}
+}
+
+func TestParsePeriodAt(t *testing.T) {
+	now := time.Date(2025, time.May, 14, 10, 30, 0, 0, time.UTC)
+	tests := []struct {
+		input    string
+		interval Interval
+		begin    string
+		end      string
+	}{
+		{"today", Interval{}, "2025-05-14", "2025-05-15"},
+		{"yesterday", Interval{}, "2025-05-13", "2025-05-14"},
+		{"last month", Interval{}, "2025-04-01", "2025-05-01"},
+		{"this year", Interval{}, "2025-01-01", "2026-01-01"},
+		{"next quarter", Interval{}, "2025-07-01", "2025-10-01"},
+		{"this week", Interval{}, "2025-05-11", "2025-05-18"},
+		{"q1", Interval{}, "2025-01-01", "2025-04-01"},
+		{"march", Interval{}, "2025-03-01", "2025-04-01"},
+		{"from 2025/03 to 2025/06", Interval{}, "2025-03-01", "2025-06-01"},
+		{"monthly from last year", Interval{IntervalMonth, 1}, "2024-01-01", ""},
+		{"every 2 weeks in q2", Interval{IntervalWeek, 2}, "2025-04-01", "2025-07-01"},
+		{"since last week until today", Interval{}, "2025-05-04", "2025-05-14"},
+		{"q2 2024", Interval{}, "2024-04-01", "2024-07-01"},
+		{"Q4 2012", Interval{}, "2012-10-01", "2013-01-01"},
+		{"march 2012", Interval{}, "2012-03-01", "2012-04-01"},
+		{"dec 2011", Interval{}, "2011-12-01", "2012-01-01"},
+		{"monthly in q1 2012", Interval{IntervalMonth, 1}, "2012-01-01", "2012-04-01"},
+		{"from feb 2012 to q3 2012", Interval{}, "2012-02-01", "2012-07-01"},
+		{"2012/02/29", Interval{}, "2012-02-29", "2012-03-01"},
+		{"2025/04/30", Interval{}, "2025-04-30", "2025-05-01"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			period, err := ParsePeriodAt(tt.input, now)
+			if err != nil {
+				t.Fatalf("Failed to parse period: %v", err)
+			}
+			if period.Interval != tt.interval {
+				t.Errorf("Expected interval %v, got %v", tt.interval, period.Interval)
+			}
+			if got := formatOptionalDate(period.Begin); got != tt.begin {
+				t.Errorf("Expected begin '%s', got '%s'", tt.begin, got)
+			}
+			if got := formatOptionalDate(period.End); got != tt.end {
+				t.Errorf("Expected end '%s', got '%s'", tt.end, got)
+			}
+		})
+	}
+
+	// Impossible days, and a second date that would replace the first
+	for _, input := range []string{"last", "this fortnight", "q5", "2025/02/29", "2025/04/31",
+		"2025/02/30", "2012/13/01", "2012/01/00", "q2 2025 q3", "2012 2013", "march april"} {
+		if _, err := ParsePeriodAt(input, now); err == nil {
+			t.Errorf("Expected error for period '%s'", input)
+		}
+	}
+}
+
+func TestPeriodicTransactionSpans(t *testing.T) {
+	period, err := ParsePeriod("monthly")
+	if err != nil {
+		t.Fatalf("Failed to parse period: %v", err)
+	}
+	template := &PeriodicTransaction{PeriodText: "monthly", Period: period}
+
+	begin := time.Date(2012, time.January, 5, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2012, time.March, 10, 0, 0, 0, 0, time.UTC)
+
+	spans := template.Spans(begin, end)
+	if len(spans) != 3 {
+		t.Fatalf("Expected 3 monthly spans, got %d", len(spans))
+	}
+	if got := spans[0].Begin.Format("2006-01-02"); got != "2012-01-01" {
+		t.Errorf("Expected first span to start 2012-01-01, got %s", got)
+	}
+
+	occurrences := template.Occurrences(begin, end)
+	if len(occurrences) != 2 {
+		t.Errorf("Expected 2 occurrences inside the range, got %d", len(occurrences))
+	}
+}
+
+func formatOptionalDate(date *time.Time) string {
+	if date == nil {
+		return ""
+	}
+	return date.Format("2006-01-02")
+}
@@ -0,0 +1,72 @@
+package domain
+
+import "time"
+
+// PeriodicTransaction is a "~ PERIOD" template, used for budgeting and
+// forecasting. It recurs at the start of each interval of its period.
+type PeriodicTransaction struct {
+	PeriodText string
+	Period     *Period
+	Postings   []*Posting
+}
+
+func (pt *PeriodicTransaction) Type() DirectiveType {
+	return DirectiveTypePeriodic
+}
+
+func (pt *PeriodicTransaction) String() string {
+	return "~ " + pt.PeriodText
+}
+
+// Occurrences returns the dates in [begin, end) on which the template recurs.
+// Without an interval it recurs monthly, as in ledger.
+func (pt *PeriodicTransaction) Occurrences(begin, end time.Time) []time.Time {
+	var dates []time.Time
+	for _, r := range pt.Spans(begin, end) {
+		if !r.Begin.Before(begin) {
+			dates = append(dates, r.Begin)
+		}
+	}
+	return dates
+}
+
+// Spans returns the recurrence ranges of the template that overlap
+// [begin, end), each running from one occurrence to the next
+func (pt *PeriodicTransaction) Spans(begin, end time.Time) []DateRange {
+	interval := pt.Period.Interval
+	if interval.IsZero() {
+		interval = Interval{Unit: IntervalMonth, Count: 1}
+	}
+
+	start := interval.Align(begin)
+	if pt.Period.Begin != nil {
+		// A bounded template recurs from its own start date
+		start = *pt.Period.Begin
+		for interval.Next(start).Before(begin) || interval.Next(start).Equal(begin) {
+			start = interval.Next(start)
+		}
+	}
+
+	var spans []DateRange
+	for ; start.Before(end); start = interval.Next(start) {
+		if pt.Period.End != nil && !start.Before(*pt.Period.End) {
+			break
+		}
+		spans = append(spans, DateRange{Begin: start, End: interval.Next(start)})
+	}
+	return spans
+}
+
+// Generate returns a transaction instantiating the template on the given
+// date. The transaction and its postings are marked as generated.
+func (pt *PeriodicTransaction) Generate(date time.Time) *Transaction {
+	transaction := NewTransaction(date)
+	transaction.Payee = "Forecast transaction"
+	transaction.IsGenerated = true
+	for _, template := range pt.Postings {
+		posting := template.Copy()
+		posting.IsGenerated = true
+		transaction.AddPosting(posting)
+	}
+	return transaction
+}
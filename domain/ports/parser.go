@@ -10,4 +10,8 @@ import (
 type Parser interface {
 	// Parse reads from the provided reader and returns transactions and directives
 	Parse(reader io.Reader) ([]domain.Transaction, []domain.Directive, error)
+
+	// ParseFiles reads the named files, and the files they include, as a
+	// single journal. The path "-" is standard input.
+	ParseFiles(paths []string) ([]domain.Transaction, []domain.Directive, error)
 }
\ No newline at end of file
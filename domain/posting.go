@@ -1,6 +1,7 @@
 package domain
 
 import (
+	"math/big"
 	"time"
 )
 
@@ -12,6 +13,15 @@ const (
 	PostingTypeBracket
 )
 
+// PostingStatus is a posting's own state, marked before its account
+type PostingStatus int
+
+const (
+	PostingStatusNone    PostingStatus = iota
+	PostingStatusPending               // !
+	PostingStatusCleared               // *
+)
+
 type BalanceAssertion struct {
 	Amount       *Amount
 	Date         *time.Time
@@ -20,15 +30,48 @@ type BalanceAssertion struct {
 }
 
 type CostBasis struct {
-	Amount        *Amount
-	Date          *time.Time
-	Label         string
-	PerUnitAmount *Amount
+	Amount        *Amount    // total cost, from {{}}
+	Date          *time.Time // lot date, from [date]
+	Label         string     // lot note, from (note)
+	PerUnitAmount *Amount    // per-unit cost, from {} or {=}
+	IsFixed       bool       // true for {=}, a price fixed at purchase
+}
+
+// UnitCost returns the per-unit cost of a lot holding the given quantity,
+// or nil when the lot carries no price
+func (c *CostBasis) UnitCost(quantity *Amount) *Amount {
+	if c.PerUnitAmount != nil {
+		return c.PerUnitAmount
+	}
+	if c.Amount != nil && quantity != nil && !quantity.IsZero() {
+		return c.Amount.Divide(new(big.Rat).Abs(quantity.Number))
+	}
+	return nil
+}
+
+// Copy returns a deep copy of the cost basis
+func (c *CostBasis) Copy() *CostBasis {
+	copy := &CostBasis{
+		Label:   c.Label,
+		IsFixed: c.IsFixed,
+	}
+	if c.Amount != nil {
+		copy.Amount = c.Amount.Copy()
+	}
+	if c.Date != nil {
+		date := *c.Date
+		copy.Date = &date
+	}
+	if c.PerUnitAmount != nil {
+		copy.PerUnitAmount = c.PerUnitAmount.Copy()
+	}
+	return copy
 }
 
 type PriceSpec struct {
-	Amount  *Amount
-	IsTotal bool // true for @@, false for @
+	Amount   *Amount
+	IsTotal  bool // true for @@, false for @
+	Inferred bool // true when computed while balancing rather than written
 }
 
 type Posting struct {
@@ -41,8 +84,10 @@ type Posting struct {
 	Metadata         map[string]string
 	Transaction      *Transaction
 	Type             PostingType
+	Status           PostingStatus
 	IsGenerated      bool
-	ExpressionAmount string // Original expression if amount couldn't be evaluated
+	ExpressionAmount string // Expression the amount was computed from, kept for printing
+	Source           SourcePosition // Where the posting was written
 }
 
 func NewPosting(account *Account) *Posting {
@@ -112,7 +157,7 @@ func (p *Posting) GetCostAmount() *Amount {
 	if p.Cost.PerUnitAmount != nil && p.Amount != nil {
 		return p.Cost.PerUnitAmount.Multiply(p.Amount.Number)
 	}
-	return p.Cost.Amount
+	return p.signedTotal(p.Cost.Amount)
 }
 
 func (p *Posting) GetDisplayAmount() *Amount {
@@ -126,7 +171,7 @@ func (p *Posting) GetMarketValue() *Amount {
 	if p.HasPrice() && p.Amount != nil && p.Price != nil {
 		if p.Price.IsTotal {
 			// @@ means total price regardless of quantity
-			return p.Price.Amount.Copy()
+			return p.signedTotal(p.Price.Amount)
 		} else {
 			// @ means per-unit price
 			return p.Price.Amount.Multiply(p.Amount.Number)
@@ -135,13 +180,65 @@ func (p *Posting) GetMarketValue() *Amount {
 	return p.Amount
 }
 
+// GetBalancingAmount returns what the posting contributes when balancing its
+// transaction: the @ or @@ price conversion when present, otherwise the {} cost,
+// otherwise the amount itself
+func (p *Posting) GetBalancingAmount() *Amount {
+	if p.Amount == nil {
+		return nil
+	}
+	if p.HasPrice() {
+		return p.GetMarketValue()
+	}
+	if p.HasCost() {
+		if cost := p.GetCostAmount(); cost != nil {
+			return cost
+		}
+	}
+	return p.Amount
+}
+
+// DisplayAccountName returns the account name wrapped the way the posting
+// was written: (Account) for virtual and [Account] for bracketed postings
+func (p *Posting) DisplayAccountName() string {
+	switch p.Type {
+	case PostingTypeVirtual:
+		return "(" + p.Account.Name + ")"
+	case PostingTypeBracket:
+		return "[" + p.Account.Name + "]"
+	}
+	return p.Account.Name
+}
+
+// MustBalance reports whether the posting takes part in transaction balancing.
+// (Virtual) postings don't have to balance, but [bracketed] ones do.
+func (p *Posting) MustBalance() bool {
+	return p.Type != PostingTypeVirtual
+}
+
+// signedTotal gives a total price or cost the sign of the posting's quantity,
+// so "-10 AAPL @@ $500" converts to $-500
+func (p *Posting) signedTotal(total *Amount) *Amount {
+	if total == nil {
+		return nil
+	}
+	if p.Amount != nil && p.Amount.IsNegative() != total.IsNegative() && !total.IsZero() {
+		return total.Negate()
+	}
+	return total.Copy()
+}
+
 func (p *Posting) Copy() *Posting {
 	copy := &Posting{
 		Account:     p.Account,
 		Note:        p.Note,
 		Type:        p.Type,
+		Status:      p.Status,
 		IsGenerated: p.IsGenerated,
 		Metadata:    make(map[string]string),
+
+		ExpressionAmount: p.ExpressionAmount,
+		Source:           p.Source,
 	}
 	
 	if p.Amount != nil {
@@ -149,26 +246,14 @@ func (p *Posting) Copy() *Posting {
 	}
 	
 	if p.Cost != nil {
-		costCopy := &CostBasis{
-			Label: p.Cost.Label,
-		}
-		if p.Cost.Amount != nil {
-			costCopy.Amount = p.Cost.Amount.Copy()
-		}
-		if p.Cost.Date != nil {
-			date := *p.Cost.Date
-			costCopy.Date = &date
-		}
-		if p.Cost.PerUnitAmount != nil {
-			costCopy.PerUnitAmount = p.Cost.PerUnitAmount.Copy()
-		}
-		copy.Cost = costCopy
+		copy.Cost = p.Cost.Copy()
 	}
 	
 	if p.Price != nil {
 		copy.Price = &PriceSpec{
-			Amount:  p.Price.Amount.Copy(),
-			IsTotal: p.Price.IsTotal,
+			Amount:   p.Price.Amount.Copy(),
+			IsTotal:  p.Price.IsTotal,
+			Inferred: p.Price.Inferred,
 		}
 	}
 	
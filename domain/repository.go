@@ -1,5 +1,7 @@
 package domain
 
+import "time"
+
 type AccountRepository interface {
 	FindAccount(fullName string) *Account
 	CreateAccount(fullName string) *Account
@@ -18,6 +20,16 @@ type CommodityRepository interface {
 	GetDefaultCommodity() *Commodity
 }
 
+// PriceSource converts amounts between commodities at a date, reporting
+// whether it found a way to
+type PriceSource interface {
+	Convert(amount *Amount, target string, date time.Time) (*Amount, bool)
+
+	// MarketCommodity returns the commodity a commodity was most recently
+	// quoted in on or before date
+	MarketCommodity(symbol string, date time.Time) (string, bool)
+}
+
 type TransactionRepository interface {
 	FindTransaction(id string) *Transaction
 	SaveTransaction(transaction *Transaction) error
@@ -0,0 +1,23 @@
+package domain
+
+import "fmt"
+
+// SourcePosition records the file and line a transaction or posting was
+// read from. File is empty for journals read from a stream without a name.
+type SourcePosition struct {
+	File string
+	Line int
+}
+
+// IsZero reports whether the position is unknown, as for generated entries
+func (s SourcePosition) IsZero() bool {
+	return s.Line == 0
+}
+
+// String formats the position the way the parser reports errors
+func (s SourcePosition) String() string {
+	if s.File == "" {
+		return fmt.Sprintf("line %d", s.Line)
+	}
+	return fmt.Sprintf("%s, line %d", s.File, s.Line)
+}
@@ -1,6 +1,7 @@
 package domain
 
 import (
+	"math/big"
 	"time"
 )
 
@@ -22,6 +23,9 @@ type Transaction struct {
 	Note     string
 	Postings []*Posting
 	Metadata map[string]string
+	Source   SourcePosition // where the transaction was written
+
+	IsGenerated bool // true for forecast transactions, which are never printed or saved
 }
 
 func NewTransaction(date time.Time) *Transaction {
@@ -43,28 +47,79 @@ func (t *Transaction) IsBalanced() bool {
 		return false
 	}
 	
-	balances := make(map[string]*Amount)
+	// Prices and costs convert postings before summing; anything smaller than
+	// the commodity's display precision is rounding noise
+	for _, amount := range t.GetResidual().GetAmounts() {
+		if !amount.IsNegligible() {
+			return false
+		}
+	}
 	
+	return true
+}
+
+// InferPrices balances a transaction written in exactly two commodities
+// without explicit prices, as in "10 AAPL" against "$-500". Postings in the
+// first commodity get an inferred total price in the second. It reports
+// whether prices were inferred.
+func (t *Transaction) InferPrices() bool {
+	residual := t.GetResidual()
+	if !residual.HasMultipleCommodities() || len(residual.GetCommodities()) != 2 {
+		return false
+	}
+	
+	var first string
 	for _, posting := range t.Postings {
-		if posting.Amount != nil {
-			commodity := posting.Amount.Commodity.Symbol
-			if existing, ok := balances[commodity]; ok {
-				balances[commodity] = existing.Add(posting.Amount)
-			} else {
-				balances[commodity] = posting.Amount.Copy()
-			}
+		if posting.Amount == nil || !posting.MustBalance() {
+			continue
+		}
+		if posting.HasPrice() || posting.HasCost() {
+			return false
+		}
+		if first == "" {
+			first = posting.Amount.Commodity.Symbol
 		}
 	}
 	
-	for _, balance := range balances {
-		if !balance.IsZero() {
-			return false
+	var second *Amount
+	for _, amount := range residual.GetAmounts() {
+		if amount.Commodity.Symbol != first {
+			second = amount
 		}
 	}
+	firstTotal := residual.GetAmount(first)
+	if second == nil || firstTotal == nil || firstTotal.IsNegative() == second.IsNegative() {
+		return false
+	}
 	
+	// Each posting of the first commodity is priced at its share of the second
+	unitPrice := new(big.Rat).Quo(second.Number, firstTotal.Number)
+	unitPrice.Neg(unitPrice)
+	for _, posting := range t.Postings {
+		if posting.Amount != nil && posting.MustBalance() && posting.Amount.Commodity.Symbol == first {
+			total := NewAmount(new(big.Rat).Mul(unitPrice, posting.Amount.Number), second.Commodity)
+			posting.Price = &PriceSpec{
+				Amount:   total.Abs(),
+				IsTotal:  true,
+				Inferred: true,
+			}
+		}
+	}
 	return true
 }
 
+// GetResidual sums the balancing amounts of every posting that must balance.
+// A balanced transaction has a zero residual.
+func (t *Transaction) GetResidual() *Balance {
+	residual := NewBalance()
+	for _, posting := range t.Postings {
+		if posting.MustBalance() {
+			residual.Add(posting.GetBalancingAmount())
+		}
+	}
+	return residual
+}
+
 func (t *Transaction) GetMetadata(key string) (string, bool) {
 	value, exists := t.Metadata[key]
 	return value, exists
@@ -84,6 +139,7 @@ func (t *Transaction) Copy() *Transaction {
 		Note:     t.Note,
 		Postings: make([]*Posting, 0, len(t.Postings)),
 		Metadata: make(map[string]string),
+		Source:   t.Source,
 	}
 	
 	if t.AuxDate != nil {
@@ -0,0 +1,123 @@
+package parser
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/hirosato/gledger/domain"
+)
+
+// parseBalanceAssertion parses the amount following a posting's "=", "==",
+// "=*" or "==*" operator. The starred forms include the balances of
+// sub-accounts.
+func (p *Parser) parseBalanceAssertion(operator, text string) (*domain.BalanceAssertion, error) {
+	assertion := &domain.BalanceAssertion{
+		IsAssignment: !strings.HasPrefix(operator, "=="),
+		Inclusive:    strings.HasSuffix(operator, "*"),
+	}
+
+	amount, err := domain.ParseAmount(text)
+	if err != nil {
+		return nil, fmt.Errorf("invalid balance assertion: %w", err)
+	}
+
+	// A bare zero keeps its empty commodity: it asserts that the account
+	// is empty in every commodity
+	if amount.Commodity.Symbol != "" || !amount.IsZero() {
+		amount.Commodity = p.commodity(amount.Commodity)
+	}
+	assertion.Amount = amount
+	return assertion, nil
+}
+
+// applyBalanceAssignments computes the amount of each posting that has a
+// balance assertion but no amount, so that the account reaches the asserted
+// balance
+func (p *Parser) applyBalanceAssignments(transaction *domain.Transaction) {
+	pending := make(map[string]*domain.Balance)
+
+	for _, posting := range transaction.Postings {
+		name := posting.Account.FullName
+		if posting.Amount == nil && posting.HasBalanceAssertion() {
+			target := posting.BalanceAssertion.Amount
+			current := p.accountBalance(name, posting.BalanceAssertion.Inclusive)
+			current.AddBalance(pendingBalance(pending, name, posting.BalanceAssertion.Inclusive))
+
+			if target.Commodity.Symbol == "" {
+				// "= 0" empties the account in the commodity it holds
+				posting.Amount = domain.ZeroAmount(p.commodity(target.Commodity))
+				if amounts := current.GetAmounts(); len(amounts) == 1 {
+					posting.Amount = amounts[0].Negate()
+				}
+			} else {
+				posting.Amount = target.Copy()
+				if existing := current.GetAmount(target.Commodity.Symbol); existing != nil {
+					posting.Amount = target.Subtract(existing)
+				}
+			}
+		}
+
+		if posting.Amount != nil {
+			if pending[name] == nil {
+				pending[name] = domain.NewBalance()
+			}
+			pending[name].Add(posting.Amount)
+		}
+	}
+}
+
+// checkBalanceAssertions adds the transaction's postings to the running
+// account balances in order, failing on the first assertion that doesn't hold
+func (p *Parser) checkBalanceAssertions(transaction *domain.Transaction) error {
+	for _, posting := range transaction.Postings {
+		name := posting.Account.FullName
+		if p.balances[name] == nil {
+			p.balances[name] = domain.NewBalance()
+		}
+		p.balances[name].Add(posting.Amount)
+
+		if !posting.HasBalanceAssertion() {
+			continue
+		}
+
+		assertion := posting.BalanceAssertion
+		actual := p.accountBalance(name, assertion.Inclusive)
+
+		if assertion.Amount.Commodity.Symbol == "" {
+			if !actual.IsZero() {
+				err := fmt.Errorf("balance assertion failed for %s: expected 0, got %s", name, actual.String())
+				return p.errorAtPosting(posting, err, TokenEqual, TokenDoubleEqual)
+			}
+			continue
+		}
+
+		got := domain.ZeroAmount(assertion.Amount.Commodity)
+		if amount := actual.GetAmount(assertion.Amount.Commodity.Symbol); amount != nil {
+			got = amount
+		}
+		if got.Number.Cmp(assertion.Amount.Number) != 0 {
+			err := fmt.Errorf("balance assertion failed for %s: expected %s, got %s",
+				name, assertion.Amount.Format(true), got.Format(true))
+			return p.errorAtPosting(posting, err, TokenEqual, TokenDoubleEqual)
+		}
+	}
+	return nil
+}
+
+// accountBalance returns the running balance of an account, including its
+// sub-accounts when inclusive is set
+func (p *Parser) accountBalance(name string, inclusive bool) *domain.Balance {
+	return pendingBalance(p.balances, name, inclusive)
+}
+
+// pendingBalance sums the balances of an account, and optionally its
+// sub-accounts, from a map of balances by account name
+func pendingBalance(balances map[string]*domain.Balance, name string, inclusive bool) *domain.Balance {
+	total := domain.NewBalance()
+	for account, balance := range balances {
+		if account == name || (inclusive && strings.HasPrefix(account, name+":")) {
+			total.AddBalance(balance)
+		}
+	}
+	return total
+}
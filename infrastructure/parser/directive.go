@@ -0,0 +1,380 @@
+package parser
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/hirosato/gledger/domain"
+)
+
+// parseDirective parses a directive: its keyword and arguments, and the
+// indented lines of the directives that have them
+func (p *Parser) parseDirective() error {
+	keyword := p.next().Value
+	var rest string
+	argument, ok := p.accept(TokenText)
+	if ok {
+		rest = argument.Value
+	}
+	if err := p.endLine(); err != nil {
+		return err
+	}
+
+	switch keyword {
+	case "=":
+		return p.parseAutomatedTransaction(rest)
+	case "~":
+		return p.parsePeriodicTransaction(rest)
+	case "account":
+		return p.parseAccountDirective(rest)
+	case "commodity":
+		return p.parseCommodityDirective(rest)
+	case "P":
+		return p.parsePriceDirective(rest)
+	case "alias":
+		return p.parseAliasDirective(rest)
+	case "include":
+		if rest == "" {
+			return fmt.Errorf("include directive requires a path")
+		}
+		if err := p.include(rest); err != nil {
+			// Point at the path; errors within included files keep theirs
+			return p.errorAtToken(argument, err)
+		}
+	case "apply":
+		return p.parseApplyDirective(rest)
+	case "end":
+		return p.parseEndDirective(rest)
+	case "bucket", "A":
+		if rest == "" {
+			return fmt.Errorf("bucket directive requires an account")
+		}
+		p.bucket = p.resolveAccount(rest)
+		p.directives = append(p.directives, &domain.BucketDirective{Account: p.bucket})
+	case "year", "Y":
+		year, err := strconv.Atoi(rest)
+		if err != nil || year <= 0 {
+			return fmt.Errorf("invalid year: %s", rest)
+		}
+		p.year = year
+	case "assert":
+		p.directives = append(p.directives, &domain.AssertDirective{Expression: rest})
+	case "check":
+		p.directives = append(p.directives, &domain.CheckDirective{Expression: rest})
+	case "comment", "test":
+		p.skipBlock("end " + keyword)
+	}
+
+	// Unknown directives are ignored, as ledger does for most of its legacy ones
+	return nil
+}
+
+// splitKeyword splits a directive line into its keyword and the remaining text
+func splitKeyword(line string) (string, string) {
+	idx := strings.IndexAny(line, " \t")
+	if idx < 0 {
+		return line, ""
+	}
+	return line[:idx], strings.TrimSpace(line[idx+1:])
+}
+
+// splitNote splits "text ; note" into its text and note parts
+func splitNote(text string) (string, string) {
+	if idx := strings.Index(text, ";"); idx >= 0 {
+		return strings.TrimSpace(text[:idx]), strings.TrimSpace(text[idx+1:])
+	}
+	return strings.TrimSpace(text), ""
+}
+
+// subDirectives returns the indented lines following a directive
+func (p *Parser) subDirectives() []string {
+	var lines []string
+	for p.peek().Type == TokenIndent {
+		indent := p.next()
+		p.finishLine()
+		trimmed := strings.TrimSpace(p.lexer.LineText(indent.Line))
+		if strings.HasPrefix(trimmed, ";") {
+			continue
+		}
+		lines = append(lines, trimmed)
+	}
+	return lines
+}
+
+// skipBlock skips lines up to and including the given terminator
+func (p *Parser) skipBlock(terminator string) {
+	for {
+		token := p.next()
+		if token.Type == TokenEOF {
+			return
+		}
+		if token.Type == TokenNewline && strings.TrimSpace(p.lexer.LineText(token.Line)) == terminator {
+			return
+		}
+	}
+}
+
+// parseAccountDirective parses "account NAME" and its sub-directives
+func (p *Parser) parseAccountDirective(rest string) error {
+	name, note := splitNote(rest)
+	if name == "" {
+		return fmt.Errorf("account directive requires a name")
+	}
+	name = p.resolveAccount(name)
+
+	for _, sub := range p.subDirectives() {
+		keyword, value := splitKeyword(sub)
+		switch keyword {
+		case "note":
+			note = value
+		case "alias":
+			p.aliases[value] = name
+			p.directives = append(p.directives, &domain.AliasDirective{Name: value, Value: name})
+		}
+	}
+
+	p.directives = append(p.directives, &domain.AccountDirective{Name: name, Note: note})
+	return nil
+}
+
+// parseCommodityDirective parses "commodity SYMBOL" and its sub-directives
+func (p *Parser) parseCommodityDirective(rest string) error {
+	symbol, note := splitNote(rest)
+	symbol = strings.Trim(symbol, "\"")
+	if symbol == "" {
+		return fmt.Errorf("commodity directive requires a symbol")
+	}
+
+	directive := &domain.CommodityDirective{
+		Symbol:    symbol,
+		Precision: -1,
+		Note:      note,
+	}
+
+	for _, sub := range p.subDirectives() {
+		keyword, value := splitKeyword(sub)
+		switch keyword {
+		case "note":
+			directive.Note = value
+		case "format":
+			sample, err := domain.ParseAmount(value)
+			if err != nil {
+				return fmt.Errorf("invalid commodity format: %w", err)
+			}
+			directive.Format = value
+			directive.Precision = sample.Commodity.Precision
+
+			// Amounts in this commodity are displayed like the format sample
+			commodity := p.commodity(domain.NewCommodity(symbol))
+			commodity.AdoptStyle(sample.Commodity)
+			p.styled[symbol] = true
+			p.fixedStyle[symbol] = true
+		case "nomarket":
+			directive.NoMarket = true
+		case "alias":
+			directive.Alias = value
+		case "default":
+			directive.Default = true
+		}
+	}
+
+	p.directives = append(p.directives, directive)
+	return nil
+}
+
+// parsePriceDirective parses "P DATE [TIME] SYMBOL AMOUNT"
+func (p *Parser) parsePriceDirective(rest string) error {
+	fields := strings.Fields(rest)
+	if len(fields) < 3 {
+		return fmt.Errorf("invalid price directive: P %s", rest)
+	}
+
+	date, err := p.parseDate(fields[0])
+	if err != nil {
+		return err
+	}
+	fields = fields[1:]
+
+	// An optional time of day follows the date
+	if strings.Contains(fields[0], ":") {
+		fields = fields[1:]
+	}
+	text := strings.Join(fields, " ")
+
+	// The commodity symbol may be quoted and contain spaces
+	var symbol string
+	if strings.HasPrefix(text, "\"") {
+		end := strings.Index(text[1:], "\"")
+		if end < 0 {
+			return fmt.Errorf("invalid price directive: P %s", rest)
+		}
+		symbol = text[1 : end+1]
+		text = text[end+2:]
+	} else {
+		symbol, text = splitKeyword(text)
+	}
+	if symbol == "" || strings.TrimSpace(text) == "" {
+		return fmt.Errorf("invalid price directive: P %s", rest)
+	}
+
+	price, err := p.parsePrice(text)
+	if err != nil {
+		return err
+	}
+
+	p.directives = append(p.directives, &domain.PriceDirective{
+		Date:      date,
+		Commodity: symbol,
+		Price:     price,
+	})
+	return nil
+}
+
+// parseAutomatedTransaction parses "= PREDICATE" and its template postings
+func (p *Parser) parseAutomatedTransaction(predicate string) error {
+	if predicate == "" {
+		return fmt.Errorf("automated transaction requires a predicate")
+	}
+
+	automated := &domain.AutomatedTransaction{Predicate: predicate}
+	for p.peek().Type == TokenIndent {
+		indent := p.next()
+		if _, ok := p.accept(TokenComment); ok {
+			if err := p.endLine(); err != nil {
+				return err
+			}
+			continue
+		}
+
+		line := strings.TrimSpace(p.lexer.LineText(indent.Line))
+		switch keyword, rest := splitKeyword(line); keyword {
+		case "assert":
+			automated.Checks = append(automated.Checks, &domain.AssertDirective{Expression: rest})
+			p.finishLine()
+			continue
+		case "check":
+			automated.Checks = append(automated.Checks, &domain.CheckDirective{Expression: rest})
+			p.finishLine()
+			continue
+		}
+
+		posting, err := p.parsePosting(true)
+		if err != nil {
+			return err
+		}
+		automated.Postings = append(automated.Postings, posting)
+	}
+
+	if len(automated.Postings) == 0 && len(automated.Checks) == 0 {
+		return fmt.Errorf("automated transaction has no postings")
+	}
+	p.directives = append(p.directives, automated)
+	return nil
+}
+
+// parsePeriodicTransaction parses "~ PERIOD" and its template postings,
+// which are balanced like a transaction's
+func (p *Parser) parsePeriodicTransaction(periodText string) error {
+	periodText, _ = splitNote(periodText)
+	period, err := domain.ParsePeriod(periodText)
+	if err != nil {
+		return err
+	}
+
+	template := &domain.Transaction{}
+	for p.peek().Type == TokenIndent {
+		p.next()
+		if _, ok := p.accept(TokenComment); ok {
+			if err := p.endLine(); err != nil {
+				return err
+			}
+			continue
+		}
+
+		posting, err := p.parsePosting(false)
+		if err != nil {
+			return err
+		}
+		template.AddPosting(posting)
+	}
+
+	if len(template.Postings) == 0 {
+		return fmt.Errorf("periodic transaction has no postings")
+	}
+	if err := p.applyAmountElision(template); err != nil {
+		return err
+	}
+
+	p.directives = append(p.directives, &domain.PeriodicTransaction{
+		PeriodText: periodText,
+		Period:     period,
+		Postings:   template.Postings,
+	})
+	return nil
+}
+
+// parseAliasDirective parses "alias NAME=ACCOUNT"
+func (p *Parser) parseAliasDirective(rest string) error {
+	idx := strings.Index(rest, "=")
+	if idx <= 0 {
+		return fmt.Errorf("invalid alias directive: alias %s", rest)
+	}
+
+	name := strings.TrimSpace(rest[:idx])
+	value := strings.TrimSpace(rest[idx+1:])
+	p.aliases[name] = value
+	p.directives = append(p.directives, &domain.AliasDirective{Name: name, Value: value})
+	return nil
+}
+
+// parseApplyDirective parses "apply account NAME"
+func (p *Parser) parseApplyDirective(rest string) error {
+	keyword, name := splitKeyword(rest)
+	if keyword != "account" {
+		// Other apply forms (tag, fixed) are not supported yet
+		return nil
+	}
+	if name == "" {
+		return fmt.Errorf("apply account directive requires an account")
+	}
+
+	name = p.resolveAccount(name)
+	p.applyStack = append(p.applyStack, name)
+	p.directives = append(p.directives, &domain.ApplyDirective{Account: name})
+	return nil
+}
+
+// parseEndDirective parses "end apply account", "end apply" and "end aliases"
+func (p *Parser) parseEndDirective(rest string) error {
+	switch {
+	case rest == "aliases":
+		p.aliases = make(map[string]string)
+	case strings.HasPrefix(rest, "apply"):
+		if len(p.applyStack) == 0 {
+			return fmt.Errorf("'end apply' without matching 'apply'")
+		}
+		p.applyStack = p.applyStack[:len(p.applyStack)-1]
+	}
+	return nil
+}
+
+// resolveAccount applies the active "apply account" prefix and aliases to an account name
+func (p *Parser) resolveAccount(name string) string {
+	if len(p.applyStack) > 0 {
+		name = p.applyStack[len(p.applyStack)-1] + ":" + name
+	}
+
+	if value, ok := p.aliases[name]; ok {
+		return value
+	}
+
+	// Aliases also match the first component of an account name
+	if idx := strings.Index(name, ":"); idx > 0 {
+		if value, ok := p.aliases[name[:idx]]; ok {
+			return value + name[idx:]
+		}
+	}
+
+	return name
+}
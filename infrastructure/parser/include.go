@@ -0,0 +1,87 @@
+package parser
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/hirosato/gledger/domain"
+)
+
+// include reads the files an include directive names, in place. Paths are
+// relative to the including file, or to the working directory when reading
+// a stream; a leading ~ is the home directory, and glob patterns include
+// every matching file in name order.
+func (p *Parser) include(path string) error {
+	pattern := path
+	if strings.HasPrefix(pattern, "~/") {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return fmt.Errorf("cannot include %s: %w", path, err)
+		}
+		pattern = filepath.Join(home, pattern[2:])
+	}
+	if !filepath.IsAbs(pattern) && p.fileName != "" {
+		pattern = filepath.Join(filepath.Dir(p.fileName), pattern)
+	}
+
+	files := []string{pattern}
+	if strings.ContainsAny(pattern, "*?[") {
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			return fmt.Errorf("cannot include %s: %w", path, err)
+		}
+		if len(matches) == 0 {
+			return fmt.Errorf("cannot include %s: no files match", path)
+		}
+		files = matches
+	}
+
+	directive := &domain.IncludeDirective{Path: path}
+	p.directives = append(p.directives, directive)
+	for _, file := range files {
+		if err := p.includeFile(file); err != nil {
+			return err
+		}
+		directive.Files = append(directive.Files, file)
+	}
+	return nil
+}
+
+// includeFile parses an included file with the including file's state, such
+// as its aliases and apply account, then resumes the including file
+func (p *Parser) includeFile(file string) error {
+	absolute, err := filepath.Abs(file)
+	if err != nil {
+		return fmt.Errorf("cannot include %s: %w", file, err)
+	}
+	for i, open := range p.includeStack {
+		if open == absolute {
+			cycle := append(append([]string{}, p.includeStack[i:]...), absolute)
+			return fmt.Errorf("include cycle: %s", strings.Join(cycle, " -> "))
+		}
+	}
+
+	reader, err := os.Open(file)
+	if err != nil {
+		return fmt.Errorf("cannot include %s: %w", file, err)
+	}
+	defer reader.Close()
+
+	// Save the including file's position
+	lexer, fileName := p.lexer, p.fileName
+	lineNumber, last := p.lineNumber, p.last
+	defer func() {
+		p.lexer, p.fileName = lexer, fileName
+		p.lineNumber, p.last = lineNumber, last
+		p.includeStack = p.includeStack[:len(p.includeStack)-1]
+	}()
+
+	p.lexer = NewLexer(reader)
+	p.fileName = file
+	p.lineNumber, p.last = 0, Token{Type: TokenNewline}
+	p.includeStack = append(p.includeStack, absolute)
+
+	return p.parseLines()
+}
@@ -5,35 +5,35 @@ import (
 	"fmt"
 	"io"
 	"strings"
-	"unicode"
+
+	"github.com/hirosato/gledger/domain"
 )
 
 // TokenType represents the type of a lexical token
 type TokenType int
 
 const (
-	TokenEOF TokenType = iota
-	TokenNewline
-	TokenWhitespace
-	TokenDate
-	TokenStatus       // *, !, ?
-	TokenCode         // (code)
-	TokenDescription  // Transaction description
-	TokenAccount      // Account name
-	TokenAmount       // Numeric amount
-	TokenCommodity    // Currency/commodity symbol
-	TokenComment      // ; comment
-	TokenIndent       // Leading whitespace for postings
-	TokenEqual        // = for balance assertions
-	TokenDoubleEqual  // == for balance checks
-	TokenAt           // @ for prices
-	TokenDoubleAt     // @@ for lot prices
-	TokenSemicolon    // ;
-	TokenColon        // :
-	TokenMinus        // -
-	TokenPlus         // +
-	TokenNumber       // Numeric value
-	TokenString       // General string
+	TokenEOF          TokenType = iota
+	TokenNewline                // End of a line
+	TokenIndent                 // Leading whitespace of a posting or sub-directive
+	TokenComment                // ; comment, without the marker
+	TokenDate                   // Transaction date or auxiliary date
+	TokenEqual                  // = before an aux date, or a balance assertion = or =*
+	TokenDoubleEqual            // Balance assertion == or ==*
+	TokenStatus                 // * or !
+	TokenCode                   // (code), without the parentheses
+	TokenDescription            // Transaction payee
+	TokenAccount                // Posting account, with any ( ) or [ ] around it
+	TokenAmount                 // Amount with its commodity
+	TokenExpression             // (value expression) written for an amount
+	TokenLotCost                // {cost} or {=fixed cost}, without the braces
+	TokenLotTotalCost           // {{total cost}}, without the braces
+	TokenLotDate                // [lot date], without the brackets
+	TokenLotNote                // (lot note), without the parentheses
+	TokenAt                     // @ before a per-unit price
+	TokenDoubleAt               // @@ before a total price
+	TokenDirective              // Directive keyword, or = and ~ for automated and periodic transactions
+	TokenText                   // Directive arguments, or text the grammar has no place for
 )
 
 // Token represents a lexical token
@@ -41,238 +41,317 @@ type Token struct {
 	Type   TokenType
 	Value  string
 	Line   int
-	Column int
+	Column int // 1-based byte column
 }
 
-// Lexer tokenizes ledger input
+// Lexer splits ledger input into tokens, one line at a time. Journals are
+// line oriented, so how a line is tokenized depends on how it starts: a date
+// in column zero starts a transaction header, leading whitespace a posting,
+// and any other word a directive.
 type Lexer struct {
-	reader  *bufio.Reader
-	line    int
-	column  int
-	current rune
-	peek    rune
-	atEOF   bool
+	scanner *bufio.Scanner
+	lines   []string // the lines read so far
+	tokens  []Token  // the rest of the current line's tokens
 }
 
 // NewLexer creates a new lexer for the given input
 func NewLexer(input io.Reader) *Lexer {
-	l := &Lexer{
-		reader: bufio.NewReader(input),
-		line:   1,
-		column: 0,
-	}
-	l.advance() // Read first character
-	l.advance() // Set up peek
-	return l
+	return &Lexer{scanner: bufio.NewScanner(input)}
 }
 
 // NextToken returns the next token from the input
 func (l *Lexer) NextToken() Token {
-	// Skip whitespace except newlines
-	for unicode.IsSpace(l.current) && l.current != '\n' {
-		l.advance()
+	token := l.PeekToken()
+	if token.Type != TokenEOF {
+		l.tokens = l.tokens[1:]
 	}
+	return token
+}
 
-	// Check for EOF
-	if l.atEOF {
-		return Token{Type: TokenEOF, Line: l.line, Column: l.column}
+// PeekToken returns the next token without consuming it
+func (l *Lexer) PeekToken() Token {
+	if len(l.tokens) == 0 {
+		if !l.scanner.Scan() {
+			return Token{Type: TokenEOF, Line: len(l.lines) + 1, Column: 1}
+		}
+		l.lines = append(l.lines, l.scanner.Text())
+		l.tokens = lexLine(l.scanner.Text(), len(l.lines))
 	}
+	return l.tokens[0]
+}
 
-	// Save position for token
-	line := l.line
-	column := l.column
-
-	// Handle newlines
-	if l.current == '\n' {
-		l.advance()
-		l.line++
-		l.column = 0
-		return Token{Type: TokenNewline, Value: "\n", Line: line, Column: column}
+// LineText returns the text of a line read so far
+func (l *Lexer) LineText(line int) string {
+	if line < 1 || line > len(l.lines) {
+		return ""
 	}
+	return l.lines[line-1]
+}
 
-	// Handle comments
-	if l.current == ';' {
-		return l.readComment()
-	}
+// Err returns the error that stopped reading, if any
+func (l *Lexer) Err() error {
+	return l.scanner.Err()
+}
+
+// lineLexer tokenizes a single line
+type lineLexer struct {
+	text   string
+	line   int
+	pos    int
+	tokens []Token
+}
 
-	// Handle dates (YYYY-MM-DD or YYYY/MM/DD)
-	if unicode.IsDigit(l.current) && l.isDateStart() {
-		return l.readDate()
+// lexLine returns the tokens of a line, ending with a newline token
+func lexLine(text string, line int) []Token {
+	l := &lineLexer{text: text, line: line}
+	switch {
+	case strings.TrimSpace(text) == "":
+		// Blank lines end entries
+	case text[0] == ' ' || text[0] == '\t':
+		l.emitUntil(TokenIndent, len(text)-len(strings.TrimLeft(text, " \t")))
+		if l.peek() == ';' {
+			l.comment()
+		} else {
+			l.posting()
+		}
+	case strings.ContainsRune(";#%|*", rune(text[0])):
+		// Ledger accepts these as comment markers in column zero
+		l.comment()
+	case isDigit(text[0]):
+		l.header()
+	default:
+		l.directive()
 	}
+	l.emit(TokenNewline, "", len(text))
+	return l.tokens
+}
 
-	// Handle amounts (numbers with optional decimal)
-	if unicode.IsDigit(l.current) || l.current == '-' {
-		return l.readAmount()
+// header tokenizes "DATE[=AUX DATE] [STATUS] [(CODE)] DESCRIPTION [; NOTE]"
+func (l *lineLexer) header() {
+	l.date()
+	if l.peek() == '=' {
+		l.emit(TokenEqual, "=", l.pos)
+		l.pos++
+		l.date()
 	}
+	l.skipSpaces()
 
-	// Handle status markers
-	if l.current == '*' || l.current == '!' || l.current == '?' {
-		status := string(l.current)
-		l.advance()
-		return Token{Type: TokenStatus, Value: status, Line: line, Column: column}
+	if c := l.peek(); c == '*' || c == '!' {
+		l.emit(TokenStatus, string(c), l.pos)
+		l.pos++
+		l.skipSpaces()
 	}
 
-	// Handle equals (balance assertions)
-	if l.current == '=' {
-		l.advance()
-		if l.current == '=' {
-			l.advance()
-			return Token{Type: TokenDoubleEqual, Value: "==", Line: line, Column: column}
+	if l.peek() == '(' {
+		if end := strings.IndexByte(l.text[l.pos:], ')'); end > 0 {
+			l.emit(TokenCode, l.text[l.pos+1:l.pos+end], l.pos)
+			l.pos += end + 1
+			l.skipSpaces()
 		}
-		return Token{Type: TokenEqual, Value: "=", Line: line, Column: column}
 	}
 
-	// Handle @ (price specifications)
-	if l.current == '@' {
-		l.advance()
-		if l.current == '@' {
-			l.advance()
-			return Token{Type: TokenDoubleAt, Value: "@@", Line: line, Column: column}
+	// As in ledger, a note on the header line starts at a ; after a tab
+	// or at least two spaces
+	end := len(l.text)
+	for i := l.pos; i < len(l.text); i++ {
+		if l.text[i] == ';' && (i == l.pos || l.text[i-1] == '\t' || strings.HasSuffix(l.text[:i], "  ")) {
+			end = i
+			break
 		}
-		return Token{Type: TokenAt, Value: "@", Line: line, Column: column}
 	}
-
-	// Handle account names and other text
-	return l.readText()
-}
-
-// advance reads the next character
-func (l *Lexer) advance() {
-	if l.atEOF {
-		return
+	if description := strings.TrimRight(l.text[l.pos:end], " \t"); description != "" {
+		l.emit(TokenDescription, description, l.pos)
 	}
-
-	l.current = l.peek
-	
-	r, _, err := l.reader.ReadRune()
-	if err != nil {
-		l.peek = 0
-		if l.current == 0 {
-			l.atEOF = true
-		}
-	} else {
-		l.peek = r
+	l.pos = end
+	if l.peek() == ';' {
+		l.comment()
 	}
-	
-	l.column++
 }
 
-// isDateStart checks if current position might be start of a date
-func (l *Lexer) isDateStart() bool {
-	// Simple check: 4 digits followed by - or /
-	// This is a simplified version; real implementation would be more robust
-	return unicode.IsDigit(l.current)
+// date tokenizes a date such as 2012/01/31, 2012-1-31 or 1/31
+func (l *lineLexer) date() {
+	start := l.pos
+	for l.pos < len(l.text) && (isDigit(l.text[l.pos]) || strings.IndexByte("/-.", l.text[l.pos]) >= 0) {
+		l.pos++
+	}
+	l.emit(TokenDate, l.text[start:l.pos], start)
 }
 
-// readDate reads a date token
-func (l *Lexer) readDate() Token {
-	line := l.line
-	column := l.column
-	var date strings.Builder
-
-	// Read date in format YYYY-MM-DD or YYYY/MM/DD
-	for unicode.IsDigit(l.current) || l.current == '-' || l.current == '/' {
-		date.WriteRune(l.current)
-		l.advance()
+// posting tokenizes "[STATUS] ACCOUNT  [AMOUNT] [LOT] [@ PRICE] [= BALANCE] [; NOTE]"
+func (l *lineLexer) posting() {
+	if c := l.peek(); (c == '*' || c == '!') && l.pos+1 < len(l.text) && (l.text[l.pos+1] == ' ' || l.text[l.pos+1] == '\t') {
+		l.emit(TokenStatus, string(c), l.pos)
+		l.pos++
+		l.skipSpaces()
 	}
 
-	dateStr := date.String()
-	// Simple validation: should have format XXXX-XX-XX or XXXX/XX/XX
-	if len(dateStr) == 10 && (dateStr[4] == '-' || dateStr[4] == '/') && 
-	   (dateStr[7] == '-' || dateStr[7] == '/') {
-		return Token{Type: TokenDate, Value: dateStr, Line: line, Column: column}
+	// The account name ends at a tab or two spaces
+	end := len(l.text)
+	for i := l.pos; i < len(l.text); i++ {
+		if l.text[i] == '\t' || (l.text[i] == ' ' && i+1 < len(l.text) && l.text[i+1] == ' ') {
+			end = i
+			break
+		}
 	}
-
-	// Not a valid date, treat as string
-	return Token{Type: TokenString, Value: dateStr, Line: line, Column: column}
+	l.emitUntil(TokenAccount, end)
+	l.skipSpaces()
+
+	l.amount(true)
+	l.annotations()
+	l.skipSpaces()
+	if l.peek() == '@' {
+		if strings.HasPrefix(l.text[l.pos:], "@@") {
+			l.emitUntil(TokenDoubleAt, l.pos+2)
+		} else {
+			l.emitUntil(TokenAt, l.pos+1)
+		}
+		l.skipSpaces()
+		l.amount(false)
+	}
+	l.skipSpaces()
+	if l.peek() == '=' {
+		operator := "="
+		if strings.HasPrefix(l.text[l.pos:], "==") {
+			operator = "=="
+		}
+		if strings.HasPrefix(l.text[l.pos+len(operator):], "*") {
+			operator += "*"
+		}
+		if strings.HasPrefix(operator, "==") {
+			l.emitUntil(TokenDoubleEqual, l.pos+len(operator))
+		} else {
+			l.emitUntil(TokenEqual, l.pos+len(operator))
+		}
+		l.skipSpaces()
+		l.amount(false)
+	}
+	l.skipSpaces()
+	l.rest()
 }
 
-// readAmount reads a numeric amount
-func (l *Lexer) readAmount() Token {
-	line := l.line
-	column := l.column
-	var amount strings.Builder
-
-	// Handle negative sign
-	if l.current == '-' {
-		amount.WriteRune(l.current)
-		l.advance()
+// amount tokenizes the amount at the current position, if there is one. An
+// amount that doesn't scan is still a token up to the note, so the parser can
+// report why. Expressions in parentheses are only allowed where expression
+// says so.
+func (l *lineLexer) amount(expression bool) {
+	switch c := l.peek(); {
+	case c == 0 || c == ';' || c == '=' || c == '@':
+		return
+	case c == '(' && expression:
+		if end := closingParen(l.text[l.pos:]); end > 0 {
+			l.emitUntil(TokenExpression, l.pos+end+1)
+			return
+		}
 	}
 
-	// Read digits before decimal
-	for unicode.IsDigit(l.current) || l.current == ',' {
-		if l.current != ',' { // Skip thousand separators
-			amount.WriteRune(l.current)
+	_, n, err := domain.ScanAmount(l.text[l.pos:])
+	if err != nil {
+		end := strings.IndexByte(l.text[l.pos:], ';')
+		if end < 0 {
+			end = len(l.text) - l.pos
 		}
-		l.advance()
+		l.emit(TokenAmount, strings.TrimSpace(l.text[l.pos:l.pos+end]), l.pos)
+		l.pos += end
+		return
 	}
+	l.emit(TokenAmount, strings.TrimSpace(l.text[l.pos:l.pos+n]), l.pos)
+	l.pos += n
+}
 
-	// Handle decimal point
-	if l.current == '.' {
-		amount.WriteRune(l.current)
-		l.advance()
-		
-		// Read digits after decimal
-		for unicode.IsDigit(l.current) {
-			amount.WriteRune(l.current)
-			l.advance()
+// annotations tokenizes the lot annotations after an amount, in any order
+func (l *lineLexer) annotations() {
+	for {
+		l.skipSpaces()
+		var open, close string
+		var kind TokenType
+		switch {
+		case strings.HasPrefix(l.text[l.pos:], "{{"):
+			open, close, kind = "{{", "}}", TokenLotTotalCost
+		case l.peek() == '{':
+			open, close, kind = "{", "}", TokenLotCost
+		case l.peek() == '[':
+			open, close, kind = "[", "]", TokenLotDate
+		case l.peek() == '(':
+			open, close, kind = "(", ")", TokenLotNote
+		default:
+			return
 		}
-	}
 
-	return Token{Type: TokenAmount, Value: amount.String(), Line: line, Column: column}
+		end := strings.Index(l.text[l.pos+len(open):], close)
+		if end < 0 {
+			// Left for the parser to report
+			return
+		}
+		body := l.text[l.pos+len(open) : l.pos+len(open)+end]
+		l.emit(kind, strings.TrimSpace(body), l.pos)
+		l.pos += len(open) + end + len(close)
+	}
 }
 
-// readComment reads a comment until end of line
-func (l *Lexer) readComment() Token {
-	line := l.line
-	column := l.column
-	var comment strings.Builder
+// directive tokenizes a directive's keyword and its arguments
+func (l *lineLexer) directive() {
+	end := strings.IndexAny(l.text, " \t")
+	if end < 0 {
+		end = len(l.text)
+	}
+	if l.text[0] == '=' || l.text[0] == '~' {
+		end = 1
+	}
+	l.emitUntil(TokenDirective, end)
+	l.skipSpaces()
+	if l.pos < len(l.text) {
+		l.emit(TokenText, strings.TrimRight(l.text[l.pos:], " \t"), l.pos)
+		l.pos = len(l.text)
+	}
+}
 
-	// Skip the semicolon
-	l.advance()
+// comment tokenizes a comment running to the end of the line
+func (l *lineLexer) comment() {
+	l.emit(TokenComment, strings.TrimSpace(l.text[l.pos+1:]), l.pos)
+	l.pos = len(l.text)
+}
 
-	// Read until newline
-	for l.current != '\n' && !l.atEOF {
-		comment.WriteRune(l.current)
-		l.advance()
+// rest tokenizes what's left of a line: a note, or text out of place
+func (l *lineLexer) rest() {
+	switch {
+	case l.pos >= len(l.text):
+	case l.peek() == ';':
+		l.comment()
+	default:
+		l.emit(TokenText, strings.TrimRight(l.text[l.pos:], " \t"), l.pos)
+		l.pos = len(l.text)
 	}
+}
 
-	return Token{Type: TokenComment, Value: strings.TrimSpace(comment.String()), Line: line, Column: column}
+// emit adds a token starting at byte offset start
+func (l *lineLexer) emit(kind TokenType, value string, start int) {
+	l.tokens = append(l.tokens, Token{Type: kind, Value: value, Line: l.line, Column: start + 1})
 }
 
-// readText reads account names, descriptions, and other text
-func (l *Lexer) readText() Token {
-	line := l.line
-	column := l.column
-	var text strings.Builder
-
-	// Read until we hit a delimiter
-	for !l.atEOF && l.current != '\n' && l.current != ';' && 
-	    l.current != '=' && l.current != '@' {
-		text.WriteRune(l.current)
-		l.advance()
-		
-		// Stop at double space (often separates account from amount)
-		if l.current == ' ' && l.peek == ' ' {
-			break
-		}
-	}
+// emitUntil adds a token of the text from the current position to end, and
+// moves past it
+func (l *lineLexer) emitUntil(kind TokenType, end int) {
+	l.emit(kind, l.text[l.pos:end], l.pos)
+	l.pos = end
+}
 
-	value := strings.TrimSpace(text.String())
-	
-	// Determine token type based on content
-	if strings.Contains(value, ":") {
-		return Token{Type: TokenAccount, Value: value, Line: line, Column: column}
+// peek returns the byte at the current position, or 0 at the end of the line
+func (l *lineLexer) peek() byte {
+	if l.pos >= len(l.text) {
+		return 0
 	}
+	return l.text[l.pos]
+}
 
-	return Token{Type: TokenString, Value: value, Line: line, Column: column}
+// skipSpaces moves past spaces and tabs
+func (l *lineLexer) skipSpaces() {
+	for l.pos < len(l.text) && (l.text[l.pos] == ' ' || l.text[l.pos] == '\t') {
+		l.pos++
+	}
 }
 
-// PeekToken returns the next token without consuming it
-func (l *Lexer) PeekToken() Token {
-	// This is a simplified implementation
-	// A real implementation would maintain a buffer
-	panic("PeekToken not implemented")
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
 }
 
 // TokenTypeString returns a string representation of the token type
@@ -282,10 +361,16 @@ func TokenTypeString(t TokenType) string {
 		return "EOF"
 	case TokenNewline:
 		return "Newline"
-	case TokenWhitespace:
-		return "Whitespace"
+	case TokenIndent:
+		return "Indent"
+	case TokenComment:
+		return "Comment"
 	case TokenDate:
 		return "Date"
+	case TokenEqual:
+		return "Equal"
+	case TokenDoubleEqual:
+		return "DoubleEqual"
 	case TokenStatus:
 		return "Status"
 	case TokenCode:
@@ -296,11 +381,25 @@ func TokenTypeString(t TokenType) string {
 		return "Account"
 	case TokenAmount:
 		return "Amount"
-	case TokenCommodity:
-		return "Commodity"
-	case TokenComment:
-		return "Comment"
+	case TokenExpression:
+		return "Expression"
+	case TokenLotCost:
+		return "LotCost"
+	case TokenLotTotalCost:
+		return "LotTotalCost"
+	case TokenLotDate:
+		return "LotDate"
+	case TokenLotNote:
+		return "LotNote"
+	case TokenAt:
+		return "At"
+	case TokenDoubleAt:
+		return "DoubleAt"
+	case TokenDirective:
+		return "Directive"
+	case TokenText:
+		return "Text"
 	default:
 		return fmt.Sprintf("Unknown(%d)", t)
 	}
-}
\ No newline at end of file
+}
@@ -0,0 +1,112 @@
+package parser
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// describeTokens returns a line's tokens as "Type(value)" strings, leaving
+// out the indent and the newline ending the line
+func describeTokens(tokens []Token) string {
+	var described []string
+	for _, token := range tokens {
+		if token.Type == TokenIndent || token.Type == TokenNewline {
+			continue
+		}
+		described = append(described, fmt.Sprintf("%s(%s)", TokenTypeString(token.Type), token.Value))
+	}
+	return strings.Join(described, " ")
+}
+
+func TestLexLine(t *testing.T) {
+	tests := []struct {
+		text     string
+		expected string
+	}{
+		// Headers
+		{"2012/01/05=2012/01/07 * (1042) Grocery  ; weekly shop",
+			"Date(2012/01/05) Equal(=) Date(2012/01/07) Status(*) Code(1042) Description(Grocery) Comment(weekly shop)"},
+		{"2012-01-05 ! Grocery\t; weekly shop", "Date(2012-01-05) Status(!) Description(Grocery) Comment(weekly shop)"},
+		{"1/5 Grocery", "Date(1/5) Description(Grocery)"},
+		// A ; after a single space is part of the description
+		{"2012/01/05 Grocery ; Deli", "Date(2012/01/05) Description(Grocery ; Deli)"},
+		{"2012/01/05 Pizza;Hut", "Date(2012/01/05) Description(Pizza;Hut)"},
+		{"2012/01/05 (1042)", "Date(2012/01/05) Code(1042)"},
+
+		// Postings
+		{"    Assets:Cash", "Account(Assets:Cash)"},
+		{"    Assets:Cash  $10.00", "Account(Assets:Cash) Amount($10.00)"},
+		{"\tAssets:Petty Cash\t$10.00", "Account(Assets:Petty Cash) Amount($10.00)"},
+		{"    * Assets:Cash  $10.00", "Status(*) Account(Assets:Cash) Amount($10.00)"},
+		{"    ! Assets:Cash  $10.00", "Status(!) Account(Assets:Cash) Amount($10.00)"},
+		{"    ! (Budget:Food)  $-10.00", "Status(!) Account((Budget:Food)) Amount($-10.00)"},
+		{"    *Assets:Cash  $10.00", "Account(*Assets:Cash) Amount($10.00)"},
+		{"    Assets:Cash  $10.00  ; change", "Account(Assets:Cash) Amount($10.00) Comment(change)"},
+		{"    Assets:Cash  $10.00\t; change", "Account(Assets:Cash) Amount($10.00) Comment(change)"},
+		{"    Assets:Cash  ; no amount", "Account(Assets:Cash) Comment(no amount)"},
+		{"    ; a note on the transaction", "Comment(a note on the transaction)"},
+
+		// Lots and prices
+		{"    Assets:Brokerage  10 AAPL {$100.00} [2012/01/01] (gift) @ $150.00",
+			"Account(Assets:Brokerage) Amount(10 AAPL) LotCost($100.00) LotDate(2012/01/01) LotNote(gift) At(@) Amount($150.00)"},
+		{"    Assets:Brokerage  10 AAPL (gift) {{$1000.00}}",
+			"Account(Assets:Brokerage) Amount(10 AAPL) LotNote(gift) LotTotalCost($1000.00)"},
+		{"    Assets:Brokerage  10 AAPL @ $150.00", "Account(Assets:Brokerage) Amount(10 AAPL) At(@) Amount($150.00)"},
+		{"    Assets:Brokerage  10 AAPL@$150.00", "Account(Assets:Brokerage) Amount(10 AAPL) At(@) Amount($150.00)"},
+		{"    Assets:Brokerage  10 AAPL @@ $1500.00", "Account(Assets:Brokerage) Amount(10 AAPL) DoubleAt(@@) Amount($1500.00)"},
+		{"    Assets:Brokerage  10 AAPL@@$1500.00", "Account(Assets:Brokerage) Amount(10 AAPL) DoubleAt(@@) Amount($1500.00)"},
+
+		// Balance assertions and assignments
+		{"    Assets:Cash  $-4.00 == $7.00", "Account(Assets:Cash) Amount($-4.00) DoubleEqual(==) Amount($7.00)"},
+		{"    Assets:Cash  = $7.00", "Account(Assets:Cash) Equal(=) Amount($7.00)"},
+		{"    Assets:Cash  $1 =* $7.00", "Account(Assets:Cash) Amount($1) Equal(=*) Amount($7.00)"},
+
+		// Expressions
+		{"    Expenses:Food  ($10.00 * 2)  ; dinner", "Account(Expenses:Food) Expression(($10.00 * 2)) Comment(dinner)"},
+		{"    Expenses:Food  (($10.00 + $2.00) / 2)", "Account(Expenses:Food) Expression((($10.00 + $2.00) / 2))"},
+		// Expressions aren't allowed as prices
+		{"    Assets:Brokerage  10 AAPL @ ($150.00)", "Account(Assets:Brokerage) Amount(10 AAPL) At(@) Amount(($150.00))"},
+
+		// Directives and comments
+		{"account Assets:Cash", "Directive(account) Text(Assets:Cash)"},
+		{"~ Monthly", "Directive(~) Text(Monthly)"},
+		{"= expr account =~ /Food/", "Directive(=) Text(expr account =~ /Food/)"},
+		{"; a comment", "Comment(a comment)"},
+		{"# a comment", "Comment(a comment)"},
+		{"", ""},
+	}
+
+	for _, test := range tests {
+		if got := describeTokens(lexLine(test.text, 1)); got != test.expected {
+			t.Errorf("For %q expected\n%s\ngot\n%s", test.text, test.expected, got)
+		}
+	}
+}
+
+func TestLexLineColumns(t *testing.T) {
+	tokens := lexLine("    Assets:Cash  $-4.00 == $7.00  ; checked", 3)
+
+	expected := []struct {
+		kind   TokenType
+		column int
+	}{
+		{TokenIndent, 1},
+		{TokenAccount, 5},
+		{TokenAmount, 18},
+		{TokenDoubleEqual, 25},
+		{TokenAmount, 28},
+		{TokenComment, 35},
+		{TokenNewline, 44},
+	}
+	if len(tokens) != len(expected) {
+		t.Fatalf("Expected %d tokens, got %s", len(expected), describeTokens(tokens))
+	}
+	for i, want := range expected {
+		token := tokens[i]
+		if token.Type != want.kind || token.Column != want.column || token.Line != 3 {
+			t.Errorf("Expected token %d to be %s at line 3, column %d, got %s at line %d, column %d",
+				i, TokenTypeString(want.kind), want.column, TokenTypeString(token.Type), token.Line, token.Column)
+		}
+	}
+}
@@ -0,0 +1,76 @@
+package parser
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ParseError is an error in a journal, with the position and text of the
+// line it was found on
+type ParseError struct {
+	File   string // Empty when reading a stream
+	Line   int
+	Column int    // 1-based column of the offending text
+	Text   string // The offending line
+	Err    error
+}
+
+// Error reports the position and message, then the offending line with a
+// caret under the column
+func (e *ParseError) Error() string {
+	position := fmt.Sprintf("line %d, column %d", e.Line, e.Column)
+	if e.File != "" {
+		position = e.File + ", " + position
+	}
+	if e.Text == "" {
+		return fmt.Sprintf("%s: %v", position, e.Err)
+	}
+
+	// Keep the line's tabs so the caret lines up
+	var indent strings.Builder
+	for i := 0; i < e.Column-1 && i < len(e.Text); i++ {
+		if e.Text[i] == '\t' {
+			indent.WriteByte('\t')
+		} else {
+			indent.WriteByte(' ')
+		}
+	}
+	return fmt.Sprintf("%s: %v\n  %s\n  %s^", position, e.Err, e.Text, indent.String())
+}
+
+// Unwrap returns the underlying error
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
+// ParseErrors is every error found in a journal parsed with error recovery
+type ParseErrors []*ParseError
+
+// Error reports each error in the order they were found
+func (e ParseErrors) Error() string {
+	messages := make([]string, len(e))
+	for i, err := range e {
+		messages[i] = err.Error()
+	}
+	return strings.Join(messages, "\n")
+}
+
+// errorAt returns err as a parse error on the given line of the current file,
+// at the given column. Errors that already have a position are kept as they
+// are.
+func (p *Parser) errorAt(line int, text string, column int, err error) *ParseError {
+	if parseErr, ok := err.(*ParseError); ok {
+		return parseErr
+	}
+	return &ParseError{File: p.fileName, Line: line, Column: column, Text: text, Err: err}
+}
+
+// fail records a parse error when recovering from errors, so parsing goes on
+// with the next entry, and otherwise returns it
+func (p *Parser) fail(err *ParseError) error {
+	if !p.recovery {
+		return err
+	}
+	p.errors = append(p.errors, err)
+	return nil
+}
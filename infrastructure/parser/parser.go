@@ -1,130 +1,276 @@
 package parser
 
 import (
-	"bufio"
 	"fmt"
 	"io"
+	"os"
+	"path/filepath"
 	"strconv"
 	"strings"
 	"time"
 
 	"github.com/hirosato/gledger/domain"
+	"github.com/hirosato/gledger/domain/expr"
 )
 
+// defaultCommoditySymbol is the commodity given to amounts written without one
+const defaultCommoditySymbol = "$"
+
 // Parser parses ledger journal files
 type Parser struct {
-	scanner      *bufio.Scanner
-	currentLine  string
-	lineNumber   int
+	lexer        *Lexer
+	fileName     string
+	lineNumber   int   // line of the last token read
+	last         Token // the last token read
 	transactions []domain.Transaction
+	directives   []domain.Directive
 	accounts     map[string]bool
+	aliases      map[string]string
+	applyStack   []string
+	bucket       string
+	commodities  map[string]*domain.Commodity
+	styled       map[string]bool // commodity style learned from a written symbol
+	fixedStyle   map[string]bool // commodity style set by a format directive
+	priced       map[string]bool // commodity only seen in prices and costs so far
+	balances     map[string]*domain.Balance // running account balances for assertions
+	year         int                        // year of dates written without one, from a year directive
+	includeStack []string                   // absolute paths of the files being read, outermost first
+	recovery     bool                       // collect errors and go on instead of stopping at the first
+	errors       []*ParseError
 }
 
 // NewParser creates a new parser
 func NewParser() *Parser {
 	return &Parser{
-		accounts: make(map[string]bool),
+		accounts:    make(map[string]bool),
+		aliases:     make(map[string]string),
+		commodities: make(map[string]*domain.Commodity),
+		styled:      make(map[string]bool),
+		fixedStyle:  make(map[string]bool),
+		balances:    make(map[string]*domain.Balance),
 	}
 }
 
+// SetRecovery sets whether the parser recovers from errors. A recovering
+// parser skips the entries it can't parse and reports every error it found
+// as ParseErrors once the whole journal is read.
+func (p *Parser) SetRecovery(recovery bool) {
+	p.recovery = recovery
+}
+
 // Parse parses a ledger journal from the given reader
 func (p *Parser) Parse(reader io.Reader) error {
-	p.scanner = bufio.NewScanner(reader)
-	p.lineNumber = 0
-	p.transactions = []domain.Transaction{}
+	p.reset()
+	fileName := ""
+	if named, ok := reader.(interface{ Name() string }); ok {
+		fileName = named.Name()
+	}
+	if err := p.parseReader(reader, fileName); err != nil {
+		return err
+	}
+	return p.collectedErrors()
+}
 
-	for p.advance() {
-		// Skip empty lines
-		if strings.TrimSpace(p.currentLine) == "" {
+// ParseFile parses the ledger journal in the named file. Included files are
+// found relative to it.
+func (p *Parser) ParseFile(path string) error {
+	return p.ParseFiles([]string{path})
+}
+
+// ParseFiles parses the named files in order as a single journal, as if they
+// were one file. The path "-" reads standard input.
+func (p *Parser) ParseFiles(paths []string) error {
+	p.reset()
+	for _, path := range paths {
+		if path == "-" {
+			if err := p.parseReader(os.Stdin, ""); err != nil {
+				return err
+			}
 			continue
 		}
 
-		// Skip comments
-		if strings.HasPrefix(strings.TrimSpace(p.currentLine), ";") {
-			continue
+		file, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		err = p.parseReader(file, path)
+		file.Close()
+		if err != nil {
+			return err
 		}
+	}
+	return p.collectedErrors()
+}
+
+// collectedErrors returns the errors a recovering parser found, if any
+func (p *Parser) collectedErrors() error {
+	if len(p.errors) == 0 {
+		return nil
+	}
+	return ParseErrors(p.errors)
+}
+
+// reset clears the parser's state for a new journal
+func (p *Parser) reset() {
+	p.transactions = []domain.Transaction{}
+	p.directives = []domain.Directive{}
+	p.accounts = make(map[string]bool)
+	p.aliases = make(map[string]string)
+	p.applyStack = nil
+	p.bucket = ""
+	p.commodities = make(map[string]*domain.Commodity)
+	p.styled = make(map[string]bool)
+	p.fixedStyle = make(map[string]bool)
+	p.priced = make(map[string]bool)
+	p.balances = make(map[string]*domain.Balance)
+	p.year = 0
+	p.errors = nil
+}
 
-		// Check if this is a transaction line
-		if p.isTransactionLine() {
+// parseReader parses one file of the journal, named fileName if it has a name
+func (p *Parser) parseReader(reader io.Reader, fileName string) error {
+	p.lexer = NewLexer(reader)
+	p.fileName = fileName
+	p.lineNumber = 0
+	p.last = Token{Type: TokenNewline}
+	p.includeStack = nil
+	if p.fileName != "" {
+		if path, err := filepath.Abs(p.fileName); err == nil {
+			p.includeStack = []string{path}
+		}
+	}
+
+	return p.parseLines()
+}
+
+// parseLines parses the entries of the current file, one per iteration:
+// transactions, directives, and the comments and blank lines between them
+func (p *Parser) parseLines() error {
+	for {
+		token := p.peek()
+		switch token.Type {
+		case TokenEOF:
+			return p.lexer.Err()
+
+		case TokenDate:
 			transaction, err := p.parseTransaction()
 			if err != nil {
-				return fmt.Errorf("line %d: %w", p.lineNumber, err)
+				if err := p.fail(p.errorAt(token.Line, p.lexer.LineText(token.Line), 1, err)); err != nil {
+					return err
+				}
+				p.finishLine()
+				continue
 			}
 			p.transactions = append(p.transactions, *transaction)
+
+		case TokenDirective:
+			if err := p.parseDirective(); err != nil {
+				if err := p.fail(p.errorAt(token.Line, p.lexer.LineText(token.Line), 1, err)); err != nil {
+					return err
+				}
+				p.finishLine()
+			}
+
+		default:
+			// Blank lines, comments, and indented lines outside of an entry
+			p.next()
+			p.finishLine()
 		}
 	}
+}
 
-	return nil
+// source returns the position of the current line
+func (p *Parser) source() domain.SourcePosition {
+	return domain.SourcePosition{File: p.fileName, Line: p.lineNumber}
 }
 
-// advance reads the next line
-func (p *Parser) advance() bool {
-	if p.scanner.Scan() {
-		p.currentLine = p.scanner.Text()
-		p.lineNumber++
-		return true
+// next consumes the next token
+func (p *Parser) next() Token {
+	token := p.lexer.NextToken()
+	if token.Type != TokenEOF {
+		p.lineNumber = token.Line
 	}
-	return false
+	p.last = token
+	return token
 }
 
-// isTransactionLine checks if the current line starts a transaction
-func (p *Parser) isTransactionLine() bool {
-	line := p.currentLine
-	
-	// Transaction lines start with a date (YYYY-MM-DD or YYYY/MM/DD)
-	if len(line) < 10 {
-		return false
+// peek returns the next token without consuming it
+func (p *Parser) peek() Token {
+	return p.lexer.PeekToken()
+}
+
+// accept consumes the next token if it has the given type
+func (p *Parser) accept(kind TokenType) (Token, bool) {
+	if p.peek().Type != kind {
+		return Token{}, false
+	}
+	return p.next(), true
+}
+
+// endLine consumes the end of the current line, which must come next
+func (p *Parser) endLine() error {
+	token := p.next()
+	if token.Type != TokenNewline && token.Type != TokenEOF {
+		return p.errorAtToken(token, fmt.Errorf("unexpected text: %s", token.Value))
 	}
+	return nil
+}
 
-	// Check for date pattern
-	datePart := line[:10]
-	if (datePart[4] == '-' || datePart[4] == '/') && 
-	   (datePart[7] == '-' || datePart[7] == '/') {
-		// Try to parse the date
-		_, err := p.parseDate(datePart)
-		return err == nil
+// finishLine skips the rest of the current line, if it isn't finished yet
+func (p *Parser) finishLine() {
+	for p.last.Type != TokenNewline && p.last.Type != TokenEOF {
+		p.next()
 	}
+}
 
-	return false
+// errorAtToken returns err as a parse error at a token
+func (p *Parser) errorAtToken(token Token, err error) *ParseError {
+	return p.errorAt(token.Line, p.lexer.LineText(token.Line), token.Column, err)
 }
 
-// parseTransaction parses a complete transaction
+// parseTransaction parses a transaction: its header line and the indented
+// postings and notes that follow
 func (p *Parser) parseTransaction() (*domain.Transaction, error) {
 	// Parse the transaction header line
-	date, status, description, err := p.parseTransactionHeader()
+	transaction, err := p.parseTransactionHeader()
 	if err != nil {
 		return nil, err
 	}
 
-	transaction := &domain.Transaction{
-		Date:     date,
-		Status:   status,
-		Payee:    description,
-		Postings: []*domain.Posting{},
-	}
-
-	// Parse postings (indented lines following the transaction)
-	for p.advance() {
-		// If line is not indented, we've reached the end of this transaction
-		if !strings.HasPrefix(p.currentLine, " ") && !strings.HasPrefix(p.currentLine, "\t") {
-			// Back up one line for the next iteration
-			p.lineNumber--
-			break
-		}
-
-		// Skip empty lines and comments within transaction
-		trimmed := strings.TrimSpace(p.currentLine)
-		if trimmed == "" || strings.HasPrefix(trimmed, ";") {
+	for p.peek().Type == TokenIndent {
+		p.next()
+
+		// Comments belong to the last posting, or to the transaction before any posting
+		if comment, ok := p.accept(TokenComment); ok {
+			if len(transaction.Postings) == 0 {
+				transaction.Note = appendNote(transaction.Note, comment.Value)
+				parseTags(comment.Value, transaction.Metadata)
+			} else {
+				posting := transaction.Postings[len(transaction.Postings)-1]
+				posting.Note = appendNote(posting.Note, comment.Value)
+				parseTags(comment.Value, posting.Metadata)
+			}
+			if err := p.endLine(); err != nil {
+				return nil, err
+			}
 			continue
 		}
 
-		// Parse the posting
-		posting, err := p.parsePosting()
+		posting, err := p.parsePosting(false)
 		if err != nil {
-			return nil, fmt.Errorf("posting error: %w", err)
+			return nil, err
 		}
-		
-		transaction.Postings = append(transaction.Postings, posting)
+		transaction.AddPosting(posting)
+	}
+
+	// Postings with a balance assignment but no amount get their amount now
+	p.applyBalanceAssignments(transaction)
+
+	// Whatever doesn't balance goes to the bucket account, if one is set
+	if p.bucket != "" && !p.hasElidedAmount(transaction) && !transaction.GetResidual().IsZero() {
+		posting := p.newPosting(p.bucket)
+		posting.Source = transaction.Source
+		transaction.AddPosting(posting)
 	}
 
 	// Validate transaction has at least 2 postings
@@ -137,199 +283,406 @@ func (p *Parser) parseTransaction() (*domain.Transaction, error) {
 		return nil, err
 	}
 
+	// Check that the transaction balances
+	if err := p.checkBalance(transaction); err != nil {
+		return nil, err
+	}
+
+	// Check balance assertions against the running account balances
+	if err := p.checkBalanceAssertions(transaction); err != nil {
+		return nil, err
+	}
+
 	return transaction, nil
 }
 
-// parseTransactionHeader parses the first line of a transaction
-func (p *Parser) parseTransactionHeader() (time.Time, domain.TransactionStatus, string, error) {
-	line := p.currentLine
-	
-	// Extract date (first 10 characters)
-	dateStr := line[:10]
-	date, err := p.parseDate(dateStr)
+// hasElidedAmount checks if any posting is waiting for its amount to be calculated
+func (p *Parser) hasElidedAmount(transaction *domain.Transaction) bool {
+	for _, posting := range transaction.Postings {
+		if posting.Amount == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// checkBalance verifies that the postings which must balance sum to zero,
+// inferring a price first when exactly two commodities are involved
+func (p *Parser) checkBalance(transaction *domain.Transaction) error {
+	if transaction.IsBalanced() {
+		return nil
+	}
+	if transaction.InferPrices() && transaction.IsBalanced() {
+		return nil
+	}
+
+	residual := transaction.GetResidual()
+	err := fmt.Errorf("transaction does not balance (unbalanced remainder is %s)", residual.String())
+
+	// Point at the amount of the last posting written with one
+	for i := len(transaction.Postings) - 1; i >= 0; i-- {
+		posting := transaction.Postings[i]
+		if posting.Source.Line > transaction.Source.Line && posting.Amount != nil {
+			return p.errorAtPosting(posting, err, TokenAmount, TokenExpression)
+		}
+	}
+	return err
+}
+
+// parseTransactionHeader parses "DATE[=AUX DATE] [STATUS] [(CODE)] PAYEE [; NOTE]"
+func (p *Parser) parseTransactionHeader() (*domain.Transaction, error) {
+	dateToken := p.next()
+	date, err := p.parseDate(dateToken.Value)
 	if err != nil {
-		return time.Time{}, 0, "", err
+		return nil, p.errorAtToken(dateToken, err)
 	}
+	transaction := domain.NewTransaction(date)
+	transaction.Source = p.source()
 
-	// Parse rest of line
-	rest := strings.TrimSpace(line[10:])
-	
-	// Check for status marker
-	status := domain.TransactionStatusPending
-	if strings.HasPrefix(rest, "*") {
-		status = domain.TransactionStatusCleared
-		rest = strings.TrimSpace(rest[1:])
-	} else if strings.HasPrefix(rest, "!") {
-		status = domain.TransactionStatusPending
-		rest = strings.TrimSpace(rest[1:])
+	// The auxiliary date, such as when a payment cleared
+	if _, ok := p.accept(TokenEqual); ok {
+		auxToken := p.next()
+		auxDate, err := p.parseDate(auxToken.Value)
+		if err != nil {
+			return nil, p.errorAtToken(auxToken, fmt.Errorf("invalid auxiliary date: %w", err))
+		}
+		transaction.AuxDate = &auxDate
 	}
 
-	// The rest is the description
-	description := rest
+	if status, ok := p.accept(TokenStatus); ok {
+		if status.Value == "*" {
+			transaction.Status = domain.TransactionStatusCleared
+		} else {
+			transaction.Status = domain.TransactionStatusPending
+		}
+	}
+	if code, ok := p.accept(TokenCode); ok {
+		transaction.Code = code.Value
+	}
+	if payee, ok := p.accept(TokenDescription); ok {
+		transaction.Payee = payee.Value
+	}
+	if note, ok := p.accept(TokenComment); ok {
+		transaction.Note = note.Value
+		parseTags(transaction.Note, transaction.Metadata)
+	}
 
-	return date, status, description, nil
+	return transaction, p.endLine()
 }
 
-// parseDate parses a date string in YYYY-MM-DD or YYYY/MM/DD format
+// parseDate parses a date such as 2012/01/31, 2012-1-31 or 2012.01.31. Dates
+// without a year, such as 01/31, are in the year set by a year directive, or
+// else the current year.
 func (p *Parser) parseDate(dateStr string) (time.Time, error) {
-	// Normalize separators
-	normalized := strings.ReplaceAll(dateStr, "/", "-")
-	
-	// Parse as YYYY-MM-DD
-	date, err := time.Parse("2006-01-02", normalized)
+	fields := strings.FieldsFunc(dateStr, func(r rune) bool {
+		return r == '/' || r == '-' || r == '.'
+	})
+	if len(fields) == 2 {
+		year := p.year
+		if year == 0 {
+			year = time.Now().Year()
+		}
+		fields = append([]string{strconv.Itoa(year)}, fields...)
+	}
+	if len(fields) != 3 {
+		return time.Time{}, fmt.Errorf("invalid date format: %s", dateStr)
+	}
+
+	date, err := time.Parse("2006/1/2", strings.Join(fields, "/"))
 	if err != nil {
 		return time.Time{}, fmt.Errorf("invalid date format: %s", dateStr)
 	}
-	
+
 	return date, nil
 }
 
-// parsePosting parses a posting line
-func (p *Parser) parsePosting() (*domain.Posting, error) {
-	line := strings.TrimSpace(p.currentLine)
-	
-	// Find the account name (everything before double space that indicates amount)
-	// If there's no double space, the whole line is the account name
-	accountName := line
-	amountStr := ""
-	
-	// Look for double space or tab separator
-	idx := -1
-	for i := 0; i < len(line)-1; i++ {
-		if (line[i] == ' ' && line[i+1] == ' ') || line[i] == '\t' {
-			idx = i
-			break
+// parsePosting parses a posting line after its indentation:
+// "[STATUS] ACCOUNT  [AMOUNT] [LOT] [@ PRICE] [= BALANCE] [; NOTE]". The
+// postings of automated transactions are templates, whose expressions are
+// evaluated against each matched posting and whose bare numbers are
+// multipliers.
+func (p *Parser) parsePosting(template bool) (*domain.Posting, error) {
+	postingStatus := domain.PostingStatusNone
+	if status, ok := p.accept(TokenStatus); ok {
+		if status.Value == "*" {
+			postingStatus = domain.PostingStatusCleared
+		} else {
+			postingStatus = domain.PostingStatusPending
 		}
 	}
-	
-	if idx > 0 {
-		accountName = strings.TrimSpace(line[:idx])
-		amountStr = strings.TrimSpace(line[idx:])
-	} else {
-		accountName = strings.TrimSpace(line)
-		amountStr = ""
+
+	accountToken, ok := p.accept(TokenAccount)
+	if !ok {
+		return nil, p.postingError(p.peek(), fmt.Errorf("expected an account"))
 	}
-	
-	// Register the account
-	p.accounts[accountName] = true
-	
-	// Create account object
-	account := domain.NewAccount(accountName)
-	account.FullName = accountName
-	
-	// Parse amount if present
-	var amount *domain.Amount
-	var expressionAmount string
-	if amountStr != "" {
-		parsedAmount, _, err := p.parseAmountWithPrice(amountStr)
-		if err == nil {
-			amount = parsedAmount
-			// Check if this was an expression amount that we couldn't fully evaluate
-			if strings.HasPrefix(strings.TrimSpace(amountStr), "(") && strings.HasSuffix(strings.TrimSpace(amountStr), ")") {
-				expressionAmount = amountStr
+	accountName := accountToken.Value
+
+	// (Account) is a virtual posting, [Account] a balanced virtual posting
+	postingType := domain.PostingTypeNormal
+	if len(accountName) > 2 && accountName[0] == '(' && accountName[len(accountName)-1] == ')' {
+		postingType = domain.PostingTypeVirtual
+		accountName = accountName[1 : len(accountName)-1]
+	} else if len(accountName) > 2 && accountName[0] == '[' && accountName[len(accountName)-1] == ']' {
+		postingType = domain.PostingTypeBracket
+		accountName = accountName[1 : len(accountName)-1]
+	}
+
+	posting := p.newPosting(accountName)
+	posting.Type = postingType
+	posting.Status = postingStatus
+	posting.Source = p.source()
+
+	switch token := p.peek(); token.Type {
+	case TokenAmount:
+		p.next()
+		// Template amounts written without a commodity are multipliers. They
+		// keep their empty commodity and don't style the default one.
+		if raw, err := domain.ParseAmount(token.Value); template && err == nil && raw.Commodity.Symbol == "" {
+			posting.Amount = raw
+			break
+		}
+		amount, err := p.parseAmount(token.Value)
+		if err != nil {
+			return nil, p.postingError(token, err)
+		}
+		posting.Amount = amount
+	case TokenExpression:
+		p.next()
+		if template {
+			// Expression amounts are evaluated against each matched posting
+			if _, err := expr.Parse(token.Value); err != nil {
+				return nil, p.postingError(token, err)
+			}
+		} else {
+			amount, _, err := p.evaluateAmountExpression(token.Value)
+			if err != nil {
+				return nil, p.postingError(token, err)
 			}
+			posting.Amount = amount
 		}
+		// Keep the expression an amount was computed from for printing
+		posting.ExpressionAmount = token.Value
 	}
 
-	posting := domain.NewPosting(account)
-	posting.Amount = amount
-	posting.ExpressionAmount = expressionAmount
+	cost, err := p.parseLotAnnotations()
+	if err != nil {
+		return nil, err
+	}
+	if cost != nil {
+		// The lot travels with the amount so its identity survives into reports
+		if posting.Amount != nil {
+			posting.Amount.Lot = cost
+		}
+		posting.SetCost(cost)
+	}
 
-	// Set price if present
-	if amountStr != "" {
-		_, parsedPrice, err := p.parseAmountWithPrice(amountStr)
-		if err == nil && parsedPrice != nil {
-			posting.SetPrice(parsedPrice)
+	if at := p.peek(); at.Type == TokenAt || at.Type == TokenDoubleAt {
+		p.next()
+		token, ok := p.accept(TokenAmount)
+		if !ok {
+			return nil, p.postingError(at, fmt.Errorf("%s requires a price", at.Value))
+		}
+		price, err := p.parsePrice(token.Value)
+		if err != nil {
+			return nil, p.postingError(token, err)
 		}
+		posting.SetPrice(&domain.PriceSpec{
+			Amount:  price,
+			IsTotal: at.Type == TokenDoubleAt,
+		})
 	}
 
-	return posting, nil
+	// A balance assertion or assignment may follow the amount, or stand alone
+	if operator := p.peek(); operator.Type == TokenEqual || operator.Type == TokenDoubleEqual {
+		p.next()
+		token, ok := p.accept(TokenAmount)
+		if !ok {
+			return nil, p.postingError(operator, fmt.Errorf("invalid balance assertion: %s requires an amount", operator.Value))
+		}
+		assertion, err := p.parseBalanceAssertion(operator.Value, token.Value)
+		if err != nil {
+			return nil, p.postingError(token, err)
+		}
+		posting.SetBalanceAssertion(assertion)
+	}
+
+	// A note may end the line
+	if note, ok := p.accept(TokenComment); ok {
+		posting.Note = note.Value
+		parseTags(posting.Note, posting.Metadata)
+	}
+
+	if token := p.peek(); token.Type == TokenText {
+		p.next()
+		if posting.Amount != nil || posting.ExpressionAmount != "" {
+			return nil, p.postingError(token, fmt.Errorf("unexpected text after amount: %s", token.Value))
+		}
+		return nil, p.postingError(token, fmt.Errorf("unexpected text: %s", token.Value))
+	}
+	return posting, p.endLine()
+}
+
+// errorAtPosting returns err as a parse error on the line of a posting of the
+// transaction being parsed, at its first token of one of the given types or
+// else at its account
+func (p *Parser) errorAtPosting(posting *domain.Posting, err error, types ...TokenType) *ParseError {
+	line := posting.Source.Line
+	text := p.lexer.LineText(line)
+	column := 1
+	for _, token := range lexLine(text, line) {
+		for _, kind := range types {
+			if token.Type == kind {
+				return p.errorAt(line, text, token.Column, err)
+			}
+		}
+		if token.Type == TokenAccount {
+			column = token.Column
+		}
+	}
+	return p.errorAt(line, text, column, err)
+}
+
+// postingError returns err as a parse error at a token of a posting
+func (p *Parser) postingError(token Token, err error) *ParseError {
+	return p.errorAtToken(token, fmt.Errorf("posting error: %w", err))
+}
+
+// newPosting creates a posting for the named account after applying
+// the active account prefix and aliases
+func (p *Parser) newPosting(accountName string) *domain.Posting {
+	accountName = p.resolveAccount(accountName)
+
+	// Register the account
+	p.accounts[accountName] = true
+
+	// Create account object
+	account := domain.NewAccount(accountName)
+	account.FullName = accountName
+
+	return domain.NewPosting(account)
 }
 
 // parseAmount parses an amount string like "10.00 GBP" or "$25.50"
 func (p *Parser) parseAmount(amountStr string) (*domain.Amount, error) {
 	amountStr = strings.TrimSpace(amountStr)
 	
-	// Check if this is an expression amount (enclosed in parentheses)
-	if strings.HasPrefix(amountStr, "(") && strings.HasSuffix(amountStr, ")") {
-		// For now, treat expression amounts as zero amount to avoid parsing errors
-		// This allows the journal to be parsed but the expression won't be evaluated
-		// TODO: Implement full expression evaluation
-		return domain.NewAmountFromFloat(0.0, domain.NewCommodity("$")), nil
+	// Expression amounts are enclosed in parentheses
+	if strings.HasPrefix(amountStr, "(") {
+		amount, rest, err := p.evaluateAmountExpression(amountStr)
+		if err == nil && rest != "" {
+			err = fmt.Errorf("invalid amount: %s", amountStr)
+		}
+		return amount, err
 	}
 	
-	// Handle currency symbols at the beginning
-	commoditySymbol := ""
-	valueStr := amountStr
-	
-	// Handle comma as decimal separator (for European format)
-	valueStr = strings.ReplaceAll(valueStr, ",", ".")
-	
-	if strings.HasPrefix(amountStr, "$") {
-		commoditySymbol = "$"
-		valueStr = strings.TrimSpace(amountStr[1:])
-		valueStr = strings.ReplaceAll(valueStr, ",", ".")
-	} else if strings.HasPrefix(amountStr, "£") {
-		commoditySymbol = "£"
-		valueStr = strings.TrimSpace(amountStr[1:])
-		valueStr = strings.ReplaceAll(valueStr, ",", ".")
-	} else if strings.HasPrefix(amountStr, "€") {
-		commoditySymbol = "€"
-		valueStr = strings.TrimSpace(amountStr[1:])
-		valueStr = strings.ReplaceAll(valueStr, ",", ".")
-	} else {
-		// Look for commodity at the end
-		parts := strings.Fields(amountStr)
-		if len(parts) == 2 {
-			valueStr = parts[0]
-			valueStr = strings.ReplaceAll(valueStr, ",", ".")
-			commoditySymbol = parts[1]
-		} else if len(parts) == 1 {
-			// Just a number, no commodity
-			valueStr = parts[0]
-			valueStr = strings.ReplaceAll(valueStr, ",", ".")
-			commoditySymbol = "$" // Default commodity
-		}
-	}
-
-	// Parse the numeric value
-	value, err := strconv.ParseFloat(valueStr, 64)
+	amount, err := domain.ParseAmount(amountStr)
+	if err != nil {
+		return nil, err
+	}
+
+	// Share one commodity per symbol so styles and precision are remembered
+	amount.Commodity = p.commodity(amount.Commodity)
+	return amount, nil
+}
+
+// parsePrice parses an @ price or {} lot cost. As in ledger, these share the
+// commodity of their symbol without changing its display style, so a
+// precise price doesn't narrow the tolerance transactions balance within.
+func (p *Parser) parsePrice(text string) (*domain.Amount, error) {
+	text = strings.TrimSpace(text)
+	if strings.HasPrefix(text, "(") {
+		return p.parseAmount(text)
+	}
+
+	amount, err := domain.ParseAmount(text)
 	if err != nil {
-		return nil, fmt.Errorf("invalid amount: %s", amountStr)
+		return nil, err
 	}
 
-	// Create commodity
-	commodity := domain.NewCommodity(commoditySymbol)
+	symbol := amount.Commodity.Symbol
+	if symbol == "" {
+		symbol = defaultCommoditySymbol
+	}
+	if commodity, exists := p.commodities[symbol]; exists {
+		amount.Commodity = commodity
+		return amount, nil
+	}
+	amount.Commodity = p.commodity(amount.Commodity)
+	p.priced[symbol] = true
+	return amount, nil
+}
 
-	// Set precision based on the input format
-	if strings.Contains(valueStr, ".") {
-		// Has decimal point, set precision to number of decimal places
-		decimalPart := strings.Split(valueStr, ".")[1]
-		commodity.Precision = len(decimalPart)
-	} else {
-		// Integer amount, set precision to 0 for cleaner display
-		commodity.Precision = 0
+// commodity returns the shared commodity for an amount's parsed commodity.
+// The display style comes from the first amount written with a symbol, and
+// the precision widens to the most precise amount seen.
+func (p *Parser) commodity(parsed *domain.Commodity) *domain.Commodity {
+	symbol := parsed.Symbol
+	if symbol == "" {
+		symbol = defaultCommoditySymbol
 	}
-	
-	// Create amount
-	return domain.NewAmountFromFloat(value, commodity), nil
+
+	commodity, exists := p.commodities[symbol]
+	if !exists {
+		commodity = domain.NewCommodity(symbol)
+		commodity.AdoptStyle(parsed)
+		if parsed.Symbol == "" {
+			// Bare numbers are written like the default commodity
+			commodity.Prefix = true
+			commodity.Spaced = false
+		}
+		p.commodities[symbol] = commodity
+		p.styled[symbol] = parsed.Symbol != ""
+		return commodity
+	}
+
+	if p.fixedStyle[symbol] {
+		return commodity
+	}
+
+	if p.priced[symbol] {
+		// A commodity seen only in prices takes its whole style from the
+		// first amount written with it
+		delete(p.priced, symbol)
+		prefix, spaced := commodity.Prefix, commodity.Spaced
+		commodity.AdoptStyle(parsed)
+		if parsed.Symbol == "" {
+			commodity.Prefix, commodity.Spaced = prefix, spaced
+		}
+		p.styled[symbol] = parsed.Symbol != ""
+		return commodity
+	}
+
+	if !p.styled[symbol] && parsed.Symbol != "" {
+		precision := commodity.Precision
+		commodity.AdoptStyle(parsed)
+		if precision > commodity.Precision {
+			commodity.Precision = precision
+		}
+		p.styled[symbol] = true
+	}
+	if parsed.Precision > commodity.Precision {
+		commodity.Precision = parsed.Precision
+	}
+	// A separator that is the established decimal mark was written in
+	// another style and can't be adopted
+	if commodity.ThousandsSeparator == 0 && parsed.ThousandsSeparator != 0 &&
+		parsed.ThousandsSeparator != commodity.DecimalMark {
+		commodity.ThousandsSeparator = parsed.ThousandsSeparator
+	}
+	return commodity
 }
 
 // applyAmountElision fills in missing amounts in postings
 func (p *Parser) applyAmountElision(transaction *domain.Transaction) error {
-	// Count postings without amounts (but don't count expression amounts)
+	// Count postings without amounts
 	var missingIndex = -1
 	missingCount := 0
-	hasExpressionAmount := false
 	
 	for i, posting := range transaction.Postings {
-		if posting.Amount == nil && posting.ExpressionAmount == "" {
+		if posting.Amount == nil {
 			missingIndex = i
 			missingCount++
 		}
-		if posting.ExpressionAmount != "" {
-			hasExpressionAmount = true
-		}
 	}
 
 	// Can only elide one amount
@@ -337,53 +690,44 @@ func (p *Parser) applyAmountElision(transaction *domain.Transaction) error {
 		return fmt.Errorf("only one posting can have an elided amount")
 	}
 
-	// If we have expression amounts, don't try to calculate elided amounts
-	// Just leave them empty to be consistent with ledger-cli behavior
-	if hasExpressionAmount && missingCount == 1 {
-		return nil
-	}
-
 	// If one amount is missing, calculate it to balance the transaction
-	if missingCount == 1 && !hasExpressionAmount {
-		// Sum all known amounts by commodity
-		// When there are price specs, we need to use market values
-		sums := make(map[string]float64)
-		commodities := make(map[string]*domain.Commodity)
-		
-		for i, posting := range transaction.Postings {
-			if i != missingIndex {
-				// Use market value if price spec exists, otherwise use amount
-				var valueToSum *domain.Amount
-				if posting.HasPrice() && posting.Amount != nil {
-					valueToSum = posting.GetMarketValue()
-				} else if posting.Amount != nil {
-					valueToSum = posting.Amount
-				}
-				
-				if valueToSum != nil {
-					commoditySymbol := valueToSum.Commodity.Symbol
-					if commoditySymbol == "" {
-						commoditySymbol = "$" // Default commodity
-					}
-					sums[commoditySymbol] += valueToSum.ToFloat64()
-					commodities[commoditySymbol] = valueToSum.Commodity
-				}
+	if missingCount == 1 {
+		// Sum the other postings, converted through their prices and costs
+		residual := transaction.GetResidual()
+
+		var firstCommodity *domain.Commodity
+		for _, posting := range transaction.Postings {
+			if posting.Amount != nil {
+				firstCommodity = posting.GetBalancingAmount().Commodity
+				break
 			}
 		}
 
-		// The elided amount should be the negative of the sum
-		// For simplicity, assume single commodity for now
-		if len(sums) == 1 {
-			for commoditySymbol, sum := range sums {
-				commodity := commodities[commoditySymbol]
-				if commodity == nil {
-					commodity = domain.NewCommodity(commoditySymbol)
-				}
-				transaction.Postings[missingIndex].Amount = domain.NewAmountFromFloat(-sum, commodity)
+		amounts := residual.GetAmounts()
+		if len(amounts) == 0 {
+			if firstCommodity != nil {
+				// Everything else already balances
+				transaction.Postings[missingIndex].Amount = domain.ZeroAmount(firstCommodity)
 			}
-		} else if len(sums) > 1 {
-			return fmt.Errorf("cannot elide amount with multiple commodities")
+			return nil
 		}
+
+		// Like ledger, an elided posting takes one amount per commodity left
+		// over; the extra postings follow the elided one
+		elided := transaction.Postings[missingIndex]
+		elided.Amount = amounts[0].Negate()
+
+		extra := make([]*domain.Posting, 0, len(amounts)-1)
+		for _, amount := range amounts[1:] {
+			posting := elided.Copy()
+			posting.Amount = amount.Negate()
+			posting.Transaction = elided.Transaction
+			extra = append(extra, posting)
+		}
+
+		postings := append([]*domain.Posting{}, transaction.Postings[:missingIndex+1]...)
+		postings = append(postings, extra...)
+		transaction.Postings = append(postings, transaction.Postings[missingIndex+1:]...)
 	}
 
 	return nil
@@ -394,6 +738,11 @@ func (p *Parser) GetTransactions() []domain.Transaction {
 	return p.transactions
 }
 
+// GetDirectives returns all parsed directives
+func (p *Parser) GetDirectives() []domain.Directive {
+	return p.directives
+}
+
 // GetAccounts returns all account names found during parsing
 func (p *Parser) GetAccounts() []string {
 	accounts := make([]string, 0, len(p.accounts))
@@ -402,55 +751,102 @@ func (p *Parser) GetAccounts() []string {
 	}
 	return accounts
 }
-// parseAmountWithPrice parses an amount string with optional price specification
-// Examples: "10.00 GBP", "1 AAA @ 10.00 GBP", "12.00 EUR @@ 10.00 GBP"
-func (p *Parser) parseAmountWithPrice(amountStr string) (*domain.Amount, *domain.PriceSpec, error) {
-	amountStr = strings.TrimSpace(amountStr)
-	
-	// Look for @@ first (total price)
-	if idx := strings.Index(amountStr, "@@"); idx > 0 {
-		amountPart := strings.TrimSpace(amountStr[:idx])
-		pricePart := strings.TrimSpace(amountStr[idx+2:])
-		
-		amount, err := p.parseAmount(amountPart)
-		if err != nil {
-			return nil, nil, err
-		}
-		
-		price, err := p.parseAmount(pricePart)
-		if err != nil {
-			return nil, nil, err
+// evaluateAmountExpression evaluates the parenthesized value expression at
+// the start of text, such as "($10 * 2)", and returns the amount with the
+// remaining text, trimmed
+func (p *Parser) evaluateAmountExpression(text string) (*domain.Amount, string, error) {
+	end := closingParen(text)
+	if end < 0 {
+		return nil, "", fmt.Errorf("missing ')' in amount expression: %s", text)
+	}
+
+	e, err := expr.Parse(text[1:end])
+	if err != nil {
+		return nil, "", err
+	}
+	value, err := e.Eval(&expr.Context{})
+	if err != nil {
+		return nil, "", fmt.Errorf("cannot evaluate %s: %w", text[:end+1], err)
+	}
+	amount, err := value.Amount()
+	if err != nil {
+		return nil, "", fmt.Errorf("cannot evaluate %s: %w", text[:end+1], err)
+	}
+
+	amount.Commodity = p.commodity(amount.Commodity)
+	return amount, strings.TrimSpace(text[end+1:]), nil
+}
+
+// closingParen returns the index of the parenthesis closing the one text
+// starts with, skipping quoted strings, or -1
+func closingParen(text string) int {
+	depth := 0
+	var quote byte
+	for i := 0; i < len(text); i++ {
+		c := text[i]
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"':
+			quote = c
+		case c == '(':
+			depth++
+		case c == ')':
+			depth--
+			if depth == 0 {
+				return i
+			}
 		}
-		
-		priceSpec := &domain.PriceSpec{
-			Amount:  price,
-			IsTotal: true,
-		}
-		
-		return amount, priceSpec, nil
-	} else if idx := strings.Index(amountStr, "@"); idx > 0 {
-		amountPart := strings.TrimSpace(amountStr[:idx])
-		pricePart := strings.TrimSpace(amountStr[idx+1:])
-		
-		amount, err := p.parseAmount(amountPart)
-		if err != nil {
-			return nil, nil, err
+	}
+	return -1
+}
+
+// parseLotAnnotations parses the lot annotations after an amount, in any
+// order: {cost}, {{total cost}}, {=fixed price}, [lot date] and (lot note).
+// It returns them as a cost basis, or nil if there are none.
+func (p *Parser) parseLotAnnotations() (*domain.CostBasis, error) {
+	var cost *domain.CostBasis
+	lot := func() *domain.CostBasis {
+		if cost == nil {
+			cost = &domain.CostBasis{}
 		}
-		
-		price, err := p.parseAmount(pricePart)
-		if err != nil {
-			return nil, nil, err
+		return cost
+	}
+
+	for {
+		token := p.peek()
+		switch token.Type {
+		case TokenLotTotalCost:
+			total, err := p.parsePrice(token.Value)
+			if err != nil {
+				return nil, p.postingError(token, fmt.Errorf("invalid lot cost: %w", err))
+			}
+			lot().Amount = total
+		case TokenLotCost:
+			body := token.Value
+			fixed := strings.HasPrefix(body, "=")
+			if fixed {
+				body = strings.TrimSpace(body[1:])
+			}
+			perUnit, err := p.parsePrice(body)
+			if err != nil {
+				return nil, p.postingError(token, fmt.Errorf("invalid lot cost: %w", err))
+			}
+			lot().PerUnitAmount = perUnit
+			cost.IsFixed = fixed
+		case TokenLotDate:
+			date, err := p.parseDate(token.Value)
+			if err != nil {
+				return nil, p.postingError(token, fmt.Errorf("invalid lot date: %w", err))
+			}
+			lot().Date = &date
+		case TokenLotNote:
+			lot().Label = token.Value
+		default:
+			return cost, nil
 		}
-		
-		priceSpec := &domain.PriceSpec{
-			Amount:  price,
-			IsTotal: false,
-		}
-		
-		return amount, priceSpec, nil
-	} else {
-		// No price specification, just parse the amount
-		amount, err := p.parseAmount(amountStr)
-		return amount, nil, err
+		p.next()
 	}
 }
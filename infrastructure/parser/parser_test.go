@@ -1,8 +1,15 @@
 package parser
 
 import (
+	"errors"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
 	"strings"
 	"testing"
+
+	"github.com/hirosato/gledger/domain"
 )
 
 func TestParseDate(t *testing.T) {
@@ -15,6 +22,7 @@ func TestParseDate(t *testing.T) {
 		{"2011-01-01", "2011-01-01"},
 		{"2011/01/01", "2011-01-01"},
 		{"2012-12-31", "2012-12-31"},
+		{"2012.1.5", "2012-01-05"},
 	}
 	
 	for _, test := range tests {
@@ -118,4 +126,925 @@ func TestParseSimpleTransaction(t *testing.T) {
 		t.Errorf("Expected second posting amount -10.00, got %f", 
 			posting2.Amount.ToFloat64())
 	}
-}
\ No newline at end of file
+}
+func TestParseDirectives(t *testing.T) {
+	p := NewParser()
+
+	other := filepath.Join(t.TempDir(), "other.ledger")
+	if err := os.WriteFile(other, []byte("; nothing yet\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	input := `account Assets:Checking ; main account
+    alias checking
+commodity $
+    format $1,000.00
+    note US dollar
+P 2012/03/16 06:47:12 CAD $2.50
+alias food=Expenses:Food
+apply account Personal
+bucket Assets:Cash
+end apply account
+include ` + filepath.Join(filepath.Dir(other), "*.ledger") + `
+assert true
+
+2012-03-17 * Dinner
+    food                    $40
+    checking
+`
+
+	if err := p.Parse(strings.NewReader(input)); err != nil {
+		t.Fatalf("Failed to parse directives: %v", err)
+	}
+
+	directives := p.GetDirectives()
+	expectedTypes := []domain.DirectiveType{
+		domain.DirectiveTypeAlias,
+		domain.DirectiveTypeAccount,
+		domain.DirectiveTypeCommodity,
+		domain.DirectiveTypePrice,
+		domain.DirectiveTypeAlias,
+		domain.DirectiveTypeApply,
+		domain.DirectiveBucket,
+		domain.DirectiveTypeInclude,
+		domain.DirectiveTypeAssert,
+	}
+	if len(directives) != len(expectedTypes) {
+		t.Fatalf("Expected %d directives, got %d", len(expectedTypes), len(directives))
+	}
+	for i, expected := range expectedTypes {
+		if directives[i].Type() != expected {
+			t.Errorf("Directive %d: expected type %v, got %v", i, expected, directives[i].Type())
+		}
+	}
+
+	commodity := directives[2].(*domain.CommodityDirective)
+	if commodity.Precision != 2 || commodity.Note != "US dollar" {
+		t.Errorf("Unexpected commodity directive: %+v", commodity)
+	}
+
+	bucket := directives[6].(*domain.BucketDirective)
+	if bucket.Account != "Personal:Assets:Cash" {
+		t.Errorf("Expected bucket 'Personal:Assets:Cash', got '%s'", bucket.Account)
+	}
+
+	transactions := p.GetTransactions()
+	if len(transactions) != 1 {
+		t.Fatalf("Expected 1 transaction, got %d", len(transactions))
+	}
+	postings := transactions[0].Postings
+	if postings[0].Account.FullName != "Expenses:Food" {
+		t.Errorf("Expected alias to resolve to 'Expenses:Food', got '%s'", postings[0].Account.FullName)
+	}
+	if postings[1].Account.FullName != "Assets:Checking" {
+		t.Errorf("Expected alias to resolve to 'Assets:Checking', got '%s'", postings[1].Account.FullName)
+	}
+}
+
+func TestParseBucketBalancesSinglePosting(t *testing.T) {
+	p := NewParser()
+
+	input := `bucket Assets:Cash
+
+2012-03-17 * Dinner
+    Expenses:Food           10.00 USD
+2012-03-18 * Lunch
+    Expenses:Food            5.00 USD
+`
+
+	if err := p.Parse(strings.NewReader(input)); err != nil {
+		t.Fatalf("Failed to parse: %v", err)
+	}
+
+	transactions := p.GetTransactions()
+	if len(transactions) != 2 {
+		t.Fatalf("Expected 2 transactions, got %d", len(transactions))
+	}
+	for _, tx := range transactions {
+		if len(tx.Postings) != 2 || tx.Postings[1].Account.FullName != "Assets:Cash" {
+			t.Errorf("Expected transaction '%s' to be balanced against the bucket", tx.Payee)
+		}
+	}
+}
+
+func TestParseAmountsExactly(t *testing.T) {
+	p := NewParser()
+
+	input := `2011-01-01 * Exchange
+    Assets:Wallet                0.1 BTC
+    Assets:Wallet                0.2 BTC
+    Assets:Exchange`
+
+	if err := p.Parse(strings.NewReader(input)); err != nil {
+		t.Fatalf("Failed to parse transaction: %v", err)
+	}
+
+	elided := p.GetTransactions()[0].Postings[2].Amount
+	expected := big.NewRat(-3, 10)
+	if elided.Number.Cmp(expected) != 0 {
+		t.Errorf("Expected elided amount %s, got %s", expected.RatString(), elided.Number.RatString())
+	}
+	if elided.String() != "-0.3" {
+		t.Errorf("Expected elided amount to format as '-0.3', got '%s'", elided.String())
+	}
+
+	amount, err := p.parseAmount("0.123456789012345678 ETH")
+	if err != nil {
+		t.Fatalf("Failed to parse amount: %v", err)
+	}
+	if amount.String() != "0.123456789012345678" {
+		t.Errorf("Expected full precision to be kept, got '%s'", amount.String())
+	}
+}
+
+func TestParseMultiCommodityElision(t *testing.T) {
+	p := NewParser()
+
+	input := `2012-01-01 * Brokerage import
+    Assets:Brokerage              10 AAPL @ $50.00
+    Assets:Brokerage             -20 EUR @@ $30.00
+    Assets:Brokerage               5 GBP
+    Assets:Cash
+    Expenses:Fees                $1.00`
+
+	if err := p.Parse(strings.NewReader(input)); err != nil {
+		t.Fatalf("Failed to parse transaction: %v", err)
+	}
+
+	postings := p.GetTransactions()[0].Postings
+	if len(postings) != 6 {
+		t.Fatalf("Expected 6 postings after elision, got %d", len(postings))
+	}
+
+	expected := []string{"$-471.00", "-5 GBP"}
+	for i, want := range expected {
+		posting := postings[3+i]
+		if posting.Account.FullName != "Assets:Cash" {
+			t.Errorf("Expected elided posting %d to use Assets:Cash, got '%s'", i, posting.Account.FullName)
+		}
+		if got := posting.Amount.Format(true); got != want {
+			t.Errorf("Expected elided posting %d to be %s, got %s", i, want, got)
+		}
+	}
+	if postings[5].Account.FullName != "Expenses:Fees" {
+		t.Errorf("Expected postings after the elided one to keep their order")
+	}
+}
+
+func TestParseAssertionErrorPosition(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`2012-01-01 * Opening
+    Assets:Cash                  $10.00
+    Equity:Opening
+
+2012-01-02 * Groceries
+    Expenses:Food                 $4.00
+    Assets:Cash                  $-4.00 == $7.00`, "line 7, column 41"},
+		{`2012-01-01 * Opening
+    Assets:Cash                  $10.00
+    Equity:Opening
+
+2012-01-02 * Check
+    Assets:Cash                  $0 = $5.00
+    Equity:Opening`, "line 6, column 37"},
+	}
+
+	for _, test := range tests {
+		err := NewParser().Parse(strings.NewReader(test.input))
+		if err == nil {
+			t.Fatalf("Expected an error for a failed assertion")
+		}
+		if !strings.Contains(err.Error(), test.expected) {
+			t.Errorf("Expected error to point at %s, got '%s'", test.expected, err.Error())
+		}
+	}
+}
+
+func TestParseRejectsUnbalancedTransaction(t *testing.T) {
+	p := NewParser()
+
+	input := `2012-01-01 * Opening
+    Assets:Cash                  $10.00
+    Equity:Opening              $-10.00
+
+2012-01-02 * Groceries
+    Expenses:Food                $10.00
+    Assets:Cash                  $-9.00`
+
+	err := p.Parse(strings.NewReader(input))
+	if err == nil {
+		t.Fatalf("Expected an error for an unbalanced transaction")
+	}
+	// The error points at the amount of the last posting
+	if !strings.Contains(err.Error(), "line 7, column 34") {
+		t.Errorf("Expected error to point at line 7, column 34, got '%s'", err.Error())
+	}
+	if !strings.Contains(err.Error(), "$1.00") {
+		t.Errorf("Expected error to report the $1.00 remainder, got '%s'", err.Error())
+	}
+}
+
+func TestParseBalancesWithPrecisePrices(t *testing.T) {
+	// Prices and costs don't widen the display precision the residual is
+	// rounded at, so these balance within half a cent as in ledger
+	tests := []string{
+		`2012-01-01 * Buy
+    Assets:Brokerage              7 AAPL @ $150.1234
+    Assets:Cash                 $-1050.86`,
+		`2012-01-01 * Buy
+    Assets:Brokerage              1 AAPL @ $3.333
+    Assets:Cash                    $-3.33`,
+		`2012-01-01 * Buy
+    Assets:Brokerage              1 AAPL {$3.333}
+    Assets:Cash                    $-3.33`,
+		`2012-01-01 * Opening
+    Assets:Cash                    $10.00
+    Equity:Opening
+
+2012-01-02 * Buy
+    Assets:Brokerage              1 AAPL @@ $3.333
+    Assets:Cash                    $-3.33`,
+	}
+
+	for _, input := range tests {
+		p := NewParser()
+		if err := p.Parse(strings.NewReader(input)); err != nil {
+			t.Errorf("Expected\n%s\nto balance, got %v", input, err)
+			continue
+		}
+		transactions := p.GetTransactions()
+		cash := transactions[len(transactions)-1].Postings[1].Amount
+		if cash.Commodity.Precision != 2 {
+			t.Errorf("Expected $ to keep a precision of 2, got %d", cash.Commodity.Precision)
+		}
+	}
+}
+
+func TestParseKeepsDecimalMarkStyle(t *testing.T) {
+	p := NewParser()
+
+	// The later amount's "." separator is the established decimal mark
+	input := `2012-01-01 * Opening
+    Assets:Euros                    10EUR
+    Equity:Opening
+
+2012-01-02 * Deposit
+    Assets:Euros             1.000,50 EUR
+    Equity:Opening`
+
+	if err := p.Parse(strings.NewReader(input)); err != nil {
+		t.Fatalf("Failed to parse transactions: %v", err)
+	}
+
+	expected := []string{"10.00EUR", "1000.50EUR"}
+	for i, tx := range p.GetTransactions() {
+		if got := tx.Postings[0].Amount.Format(true); got != expected[i] {
+			t.Errorf("Expected %s, got %s", expected[i], got)
+		}
+	}
+	total := new(big.Rat)
+	for _, tx := range p.GetTransactions() {
+		total.Add(total, tx.Postings[0].Amount.Number)
+	}
+	if got := domain.NewAmount(total, p.GetTransactions()[0].Postings[0].Amount.Commodity).Format(true); got != "1010.50EUR" {
+		t.Errorf("Expected 1010.50EUR, got %s", got)
+	}
+}
+
+func TestParseInfersPriceBetweenTwoCommodities(t *testing.T) {
+	p := NewParser()
+
+	input := `2012-01-01 * Exchange
+    Assets:Euros                 100 EUR
+    Assets:Cash                 $-125.00`
+
+	if err := p.Parse(strings.NewReader(input)); err != nil {
+		t.Fatalf("Failed to parse transaction: %v", err)
+	}
+
+	posting := p.GetTransactions()[0].Postings[0]
+	if !posting.HasPrice() || !posting.Price.Inferred {
+		t.Fatalf("Expected an inferred price on the EUR posting")
+	}
+	if got := posting.Price.Amount.Format(true); got != "$125.00" {
+		t.Errorf("Expected inferred total price $125.00, got %s", got)
+	}
+}
+
+func TestParseVirtualPostings(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		wantErr bool
+	}{
+		{
+			name: "virtual postings need not balance",
+			input: `2012-01-01 * Budget
+    Expenses:Food                $10.00
+    Assets:Cash
+    (Budget:Food)               $-10.00`,
+			wantErr: false,
+		},
+		{
+			name: "bracketed postings must balance",
+			input: `2012-01-01 * Budget
+    Expenses:Food                $10.00
+    Assets:Cash                 $-10.00
+    [Budget:Food]               $-10.00`,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := NewParser()
+			err := p.Parse(strings.NewReader(tt.input))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Expected error %v, got %v", tt.wantErr, err)
+			}
+			if err != nil {
+				return
+			}
+
+			posting := p.GetTransactions()[0].Postings[2]
+			if posting.Type != domain.PostingTypeVirtual {
+				t.Errorf("Expected a virtual posting, got type %v", posting.Type)
+			}
+			if posting.Account.FullName != "Budget:Food" {
+				t.Errorf("Expected account 'Budget:Food', got '%s'", posting.Account.FullName)
+			}
+			if posting.DisplayAccountName() != "(Budget:Food)" {
+				t.Errorf("Expected display name '(Budget:Food)', got '%s'", posting.DisplayAccountName())
+			}
+		})
+	}
+}
+
+func TestParseLotAnnotations(t *testing.T) {
+	tests := []struct {
+		name      string
+		amount    string
+		unitCost  string
+		totalCost string
+		date      string
+		label     string
+		fixed     bool
+		price     string
+	}{
+		{name: "per-unit cost", amount: "10 AAPL {$50.00}", unitCost: "$50.00"},
+		{name: "total cost", amount: "10 AAPL {{$500.00}}", totalCost: "$500.00"},
+		{name: "fixed price", amount: "10 AAPL {=$50.00}", unitCost: "$50.00", fixed: true},
+		{name: "date and note", amount: "10 AAPL {$50.00} [2012/01/01] (first buy)", unitCost: "$50.00", date: "2012-01-01", label: "first buy"},
+		{name: "cost and price", amount: "10 AAPL {$50.00} @ $50.00", unitCost: "$50.00", price: "$50.00"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := NewParser()
+			input := "2012-01-01 * Buy\n    Assets:Brokerage    " + tt.amount + "\n    Assets:Cash\n"
+			if err := p.Parse(strings.NewReader(input)); err != nil {
+				t.Fatalf("Failed to parse transaction: %v", err)
+			}
+
+			postings := p.GetTransactions()[0].Postings
+			posting := postings[0]
+			if !posting.HasCost() {
+				t.Fatalf("Expected a cost basis on the posting")
+			}
+			if posting.Amount.Lot != posting.Cost {
+				t.Errorf("Expected the lot to be kept on the amount")
+			}
+			if got := postings[1].Amount.Format(true); got != "$-500.00" {
+				t.Errorf("Expected the lot cost to balance the transaction, got %s", got)
+			}
+
+			cost := posting.Cost
+			if tt.unitCost != "" && (cost.PerUnitAmount == nil || cost.PerUnitAmount.Format(true) != tt.unitCost) {
+				t.Errorf("Expected per-unit cost %s, got %v", tt.unitCost, cost.PerUnitAmount)
+			}
+			if tt.totalCost != "" && (cost.Amount == nil || cost.Amount.Format(true) != tt.totalCost) {
+				t.Errorf("Expected total cost %s, got %v", tt.totalCost, cost.Amount)
+			}
+			if got := cost.UnitCost(posting.Amount).Format(true); got != "$50.00" {
+				t.Errorf("Expected unit cost $50.00, got %s", got)
+			}
+			if cost.IsFixed != tt.fixed {
+				t.Errorf("Expected fixed %v, got %v", tt.fixed, cost.IsFixed)
+			}
+			if tt.date != "" && (cost.Date == nil || cost.Date.Format("2006-01-02") != tt.date) {
+				t.Errorf("Expected lot date %s, got %v", tt.date, cost.Date)
+			}
+			if cost.Label != tt.label {
+				t.Errorf("Expected lot note '%s', got '%s'", tt.label, cost.Label)
+			}
+			if tt.price != "" && (!posting.HasPrice() || posting.Price.Amount.Format(true) != tt.price) {
+				t.Errorf("Expected price %s, got %v", tt.price, posting.Price)
+			}
+		})
+	}
+}
+
+func TestParseBalanceAssertions(t *testing.T) {
+	opening := `2012-01-01 * Opening
+    Assets:Checking              $100.00
+    Equity:Opening
+
+`
+
+	tests := []struct {
+		name    string
+		input   string
+		wantErr string
+		amount  string
+	}{
+		{
+			name: "assertion holds",
+			input: `2012-01-02 * Groceries
+    Expenses:Food                $10.00
+    Assets:Checking             $-10.00 = $90.00`,
+			amount: "$-10.00",
+		},
+		{
+			name: "assertion fails",
+			input: `2012-01-02 * Groceries
+    Expenses:Food                $10.00
+    Assets:Checking             $-10.00 == $80.00`,
+			wantErr: "expected $80.00, got $90.00",
+		},
+		{
+			name: "assignment computes the amount",
+			input: `2012-01-02 * Reconcile
+    Assets:Checking              = $75.00
+    Expenses:Fees`,
+			amount: "$-25.00",
+		},
+		{
+			name: "inclusive assertion counts sub-accounts",
+			input: `2012-01-02 * Savings
+    Assets:Checking:Savings      $50.00
+    Equity:Opening
+    Assets:Checking               $0.00 =* $150.00`,
+			amount: "$0.00",
+		},
+		{
+			name: "bare zero empties the account",
+			input: `2012-01-02 * Close
+    Assets:Checking              = 0
+    Equity:Opening`,
+			amount: "$-100.00",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := NewParser()
+			err := p.Parse(strings.NewReader(opening + tt.input))
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Expected error containing '%s', got %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Failed to parse transactions: %v", err)
+			}
+
+			for _, posting := range p.GetTransactions()[1].Postings {
+				if posting.Account.FullName == "Assets:Checking" {
+					if got := posting.Amount.Format(true); got != tt.amount {
+						t.Errorf("Expected Assets:Checking amount %s, got %s", tt.amount, got)
+					}
+					if !posting.HasBalanceAssertion() {
+						t.Errorf("Expected the balance assertion to be kept on the posting")
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestParsePeriodicTransaction(t *testing.T) {
+	p := NewParser()
+
+	input := `~ Monthly  ; budget
+    Expenses:Food                $500.00
+    Assets:Checking
+
+2012-01-05 * Groceries
+    Expenses:Food                $450.00
+    Assets:Checking`
+
+	if err := p.Parse(strings.NewReader(input)); err != nil {
+		t.Fatalf("Failed to parse journal: %v", err)
+	}
+	if len(p.GetTransactions()) != 1 {
+		t.Errorf("Expected periodic transaction not to be a transaction, got %d transactions", len(p.GetTransactions()))
+	}
+
+	directives := p.GetDirectives()
+	if len(directives) != 1 {
+		t.Fatalf("Expected 1 directive, got %d", len(directives))
+	}
+	periodic, ok := directives[0].(*domain.PeriodicTransaction)
+	if !ok {
+		t.Fatalf("Expected a periodic transaction, got %T", directives[0])
+	}
+	if periodic.PeriodText != "Monthly" {
+		t.Errorf("Expected period text 'Monthly', got '%s'", periodic.PeriodText)
+	}
+	if periodic.Period.Interval.Unit != domain.IntervalMonth {
+		t.Errorf("Expected a monthly interval, got %v", periodic.Period.Interval)
+	}
+	if len(periodic.Postings) != 2 {
+		t.Fatalf("Expected 2 template postings, got %d", len(periodic.Postings))
+	}
+	if got := periodic.Postings[1].Amount.Format(true); got != "$-500.00" {
+		t.Errorf("Expected elided template amount $-500.00, got %s", got)
+	}
+}
+
+func TestParseCodeNotesAndTags(t *testing.T) {
+	p := NewParser()
+
+	input := `2012-03-01 * (101) Grocery Store  ; :shopping:food:
+    ; Location: downtown
+    Expenses:Food                   $20.00  ; Receipt:: 42
+    Assets:Cash
+    ; paid in cash`
+
+	if err := p.Parse(strings.NewReader(input)); err != nil {
+		t.Fatalf("Failed to parse journal: %v", err)
+	}
+	tx := p.GetTransactions()[0]
+
+	if tx.Code != "101" {
+		t.Errorf("Expected code '101', got '%s'", tx.Code)
+	}
+	if tx.Payee != "Grocery Store" {
+		t.Errorf("Expected payee 'Grocery Store', got '%s'", tx.Payee)
+	}
+	for _, tag := range []string{"shopping", "food"} {
+		if _, ok := tx.Metadata[tag]; !ok {
+			t.Errorf("Expected transaction tag '%s', got %v", tag, tx.Metadata)
+		}
+	}
+	if got := tx.Metadata["Location"]; got != "downtown" {
+		t.Errorf("Expected transaction Location 'downtown', got '%s'", got)
+	}
+	if got := tx.Postings[0].Metadata["Receipt"]; got != "42" {
+		t.Errorf("Expected posting Receipt '42', got '%s'", got)
+	}
+	if got := tx.Postings[0].Amount.Format(true); got != "$20.00" {
+		t.Errorf("Expected amount $20.00, got %s", got)
+	}
+	if got := tx.Postings[1].Note; got != "paid in cash" {
+		t.Errorf("Expected posting note 'paid in cash', got '%s'", got)
+	}
+}
+
+func TestParsePostingStatus(t *testing.T) {
+	p := NewParser()
+
+	input := `2012-03-01 Grocery Store
+    * Expenses:Food                 $20.00
+    ! Expenses:Drinks                $5.00
+    Assets:Cash`
+
+	if err := p.Parse(strings.NewReader(input)); err != nil {
+		t.Fatalf("Failed to parse journal: %v", err)
+	}
+	tx := p.GetTransactions()[0]
+
+	expected := []domain.PostingStatus{domain.PostingStatusCleared, domain.PostingStatusPending, domain.PostingStatusNone}
+	for i, status := range expected {
+		if got := tx.Postings[i].Status; got != status {
+			t.Errorf("Expected posting %d status %d, got %d", i, status, got)
+		}
+	}
+	if got := tx.Postings[1].Account.FullName; got != "Expenses:Drinks" {
+		t.Errorf("Expected account 'Expenses:Drinks', got '%s'", got)
+	}
+}
+
+func TestParseTransactionHeaderGrammar(t *testing.T) {
+	p := NewParser()
+
+	input := `year 2013
+01/15=01/20 ! (#100) Payee;not a note  ; note
+    * Expenses:Food                 $5.00 = $5.00 ; posting note
+    Assets:Cash`
+
+	if err := p.Parse(strings.NewReader(input)); err != nil {
+		t.Fatalf("Failed to parse journal: %v", err)
+	}
+	tx := p.GetTransactions()[0]
+
+	if got := tx.Date.Format("2006-01-02"); got != "2013-01-15" {
+		t.Errorf("Expected date 2013-01-15, got %s", got)
+	}
+	if tx.AuxDate == nil || tx.AuxDate.Format("2006-01-02") != "2013-01-20" {
+		t.Errorf("Expected aux date 2013-01-20, got %v", tx.AuxDate)
+	}
+	if tx.Status != domain.TransactionStatusPending {
+		t.Errorf("Expected a pending transaction, got %v", tx.Status)
+	}
+	if tx.Code != "#100" {
+		t.Errorf("Expected code '#100', got '%s'", tx.Code)
+	}
+	// A note needs a tab or two spaces before its ';', as in ledger
+	if tx.Payee != "Payee;not a note" {
+		t.Errorf("Expected payee 'Payee;not a note', got '%s'", tx.Payee)
+	}
+	if tx.Note != "note" {
+		t.Errorf("Expected note 'note', got '%s'", tx.Note)
+	}
+
+	posting := tx.Postings[0]
+	if posting.Account.FullName != "Expenses:Food" {
+		t.Errorf("Expected account Expenses:Food, got %s", posting.Account.FullName)
+	}
+	if !posting.HasBalanceAssertion() || posting.BalanceAssertion.Amount.Format(true) != "$5.00" {
+		t.Errorf("Expected a balance assertion of $5.00, got %v", posting.BalanceAssertion)
+	}
+	if posting.Note != "posting note" {
+		t.Errorf("Expected posting note 'posting note', got '%s'", posting.Note)
+	}
+}
+
+func TestParseExpressionAmounts(t *testing.T) {
+	p := NewParser()
+
+	input := `2012-03-01 * Dinner
+    Expenses:Food                   ($10 * 2 + 5)
+    Assets:Cash`
+
+	if err := p.Parse(strings.NewReader(input)); err != nil {
+		t.Fatalf("Failed to parse journal: %v", err)
+	}
+	tx := p.GetTransactions()[0]
+
+	if got := tx.Postings[0].Amount.Format(true); got != "$25" {
+		t.Errorf("Expected evaluated amount $25, got %s", got)
+	}
+	if got := tx.Postings[0].ExpressionAmount; got != "($10 * 2 + 5)" {
+		t.Errorf("Expected the expression to be kept, got '%s'", got)
+	}
+	if got := tx.Postings[1].Amount.Format(true); got != "$-25" {
+		t.Errorf("Expected elided amount $-25, got %s", got)
+	}
+
+	for _, amount := range []string{"($10 / 0)", "($10 +", "('text')"} {
+		input := "2012-03-01 * Dinner\n    Expenses:Food  " + amount + "\n    Assets:Cash\n"
+		if err := NewParser().Parse(strings.NewReader(input)); err == nil {
+			t.Errorf("Expected error for expression amount %s", amount)
+		}
+	}
+}
+
+func TestParseIncludes(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"main.ledger": `alias food=Expenses:Food
+include books/*.ledger
+
+2012-03-01 * Rent
+    Expenses:Rent           $500
+    Assets:Checking
+`,
+		"books/2011.ledger": `2011-01-01 * Dinner
+    food                    $10
+    Assets:Checking
+`,
+		"books/2012.ledger": `include ../shared/opening.ledger
+`,
+		"shared/opening.ledger": `; opening balances
+
+2012-01-01 * Opening
+    Assets:Checking         $1000
+    Equity:Opening
+`,
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	p := NewParser()
+	if err := p.ParseFile(filepath.Join(dir, "main.ledger")); err != nil {
+		t.Fatalf("Failed to parse journal: %v", err)
+	}
+
+	tests := []struct {
+		payee   string
+		account string
+		file    string
+		line    int
+	}{
+		{"Dinner", "Expenses:Food", "books/2011.ledger", 1},
+		{"Opening", "Assets:Checking", "shared/opening.ledger", 3},
+		{"Rent", "Expenses:Rent", "main.ledger", 4},
+	}
+
+	transactions := p.GetTransactions()
+	if len(transactions) != len(tests) {
+		t.Fatalf("Expected %d transactions, got %d", len(tests), len(transactions))
+	}
+	for i, test := range tests {
+		tx := transactions[i]
+		if tx.Payee != test.payee {
+			t.Errorf("Expected transaction %d to be %s, got %s", i, test.payee, tx.Payee)
+		}
+		if got := tx.Postings[0].Account.FullName; got != test.account {
+			t.Errorf("Expected %s posting to %s, got %s", test.payee, test.account, got)
+		}
+		if rel, _ := filepath.Rel(dir, tx.Source.File); filepath.ToSlash(rel) != test.file || tx.Source.Line != test.line {
+			t.Errorf("Expected %s at %s line %d, got %s", test.payee, test.file, test.line, tx.Source)
+		}
+		if got := tx.Postings[1].Source.Line; got != test.line+2 {
+			t.Errorf("Expected %s second posting at line %d, got %d", test.payee, test.line+2, got)
+		}
+	}
+
+	// A file that includes itself, directly or not, is an error
+	cyclic := filepath.Join(dir, "shared", "cyclic.ledger")
+	if err := os.WriteFile(cyclic, []byte("include ../books/cycle.ledger\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "books", "cycle.ledger"), []byte("include ../shared/cyclic.ledger\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	err := NewParser().ParseFile(cyclic)
+	if err == nil || !strings.Contains(err.Error(), "include cycle") {
+		t.Errorf("Expected an include cycle error, got %v", err)
+	}
+
+	if err := NewParser().Parse(strings.NewReader("include missing.ledger\n")); err == nil {
+		t.Errorf("Expected an error including a missing file")
+	}
+
+	// A glob that matches nothing is an error at the path, not an empty include
+	main := filepath.Join(dir, "typo.ledger")
+	if err := os.WriteFile(main, []byte("; accounts\ninclude book/*.ledger\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	err = NewParser().ParseFile(main)
+	var parseError *ParseError
+	if !errors.As(err, &parseError) {
+		t.Fatalf("Expected a parse error for an include matching nothing, got %v", err)
+	}
+	if !strings.Contains(err.Error(), "cannot include book/*.ledger: no files match") ||
+		parseError.Line != 2 || parseError.Column != 9 {
+		t.Errorf("Expected a no files match error at line 2, column 9, got %v", err)
+	}
+}
+
+func TestParseFiles(t *testing.T) {
+	dir := t.TempDir()
+	first := filepath.Join(dir, "accounts.ledger")
+	second := filepath.Join(dir, "2012.ledger")
+	if err := os.WriteFile(first, []byte("alias food=Expenses:Food\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(second, []byte("2012-03-01 * Dinner\n    food  $10\n    Assets:Cash\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	p := NewParser()
+	if err := p.ParseFiles([]string{first, second}); err != nil {
+		t.Fatalf("Failed to parse journal: %v", err)
+	}
+	transactions := p.GetTransactions()
+	if len(transactions) != 1 {
+		t.Fatalf("Expected 1 transaction, got %d", len(transactions))
+	}
+	if got := transactions[0].Postings[0].Account.FullName; got != "Expenses:Food" {
+		t.Errorf("Expected the first file's alias to resolve to 'Expenses:Food', got '%s'", got)
+	}
+	if got := transactions[0].Source.File; got != second {
+		t.Errorf("Expected the transaction to come from %s, got %s", second, got)
+	}
+
+	if err := NewParser().ParseFiles([]string{first, filepath.Join(dir, "missing.ledger")}); err == nil {
+		t.Errorf("Expected an error for a missing file")
+	}
+}
+
+func TestParseReusedParser(t *testing.T) {
+	p := NewParser()
+	first := `account Assets:Old
+alias old=Expenses:Old
+
+2012-01-01 * Old
+    old  $10
+    Assets:Old
+`
+	if err := p.Parse(strings.NewReader(first)); err != nil {
+		t.Fatalf("Failed to parse the first journal: %v", err)
+	}
+
+	// Nothing of the first journal is left when parsing the second
+	second := `2012-02-01 * New
+    old  $20
+    Assets:New
+`
+	if err := p.Parse(strings.NewReader(second)); err != nil {
+		t.Fatalf("Failed to parse the second journal: %v", err)
+	}
+	accounts := p.GetAccounts()
+	sort.Strings(accounts)
+	if got := strings.Join(accounts, ", "); got != "Assets:New, old" {
+		t.Errorf("Expected accounts 'Assets:New, old', got '%s'", got)
+	}
+	if directives := p.GetDirectives(); len(directives) != 0 {
+		t.Errorf("Expected no directives, got %d", len(directives))
+	}
+	if transactions := p.GetTransactions(); len(transactions) != 1 {
+		t.Errorf("Expected 1 transaction, got %d", len(transactions))
+	}
+}
+
+func TestParseErrorPosition(t *testing.T) {
+	p := NewParser()
+
+	input := `2012-01-01 * Opening
+    Assets:Cash                  $10.00
+    Equity:Opening              $-10.00
+
+2012-01-02 * Groceries
+    Expenses:Food                $10.00 {abc}
+    Assets:Cash`
+
+	err := p.Parse(strings.NewReader(input))
+	var parseErr *ParseError
+	if !errors.As(err, &parseErr) {
+		t.Fatalf("Expected a ParseError, got %v", err)
+	}
+	if parseErr.Line != 6 {
+		t.Errorf("Expected line 6, got %d", parseErr.Line)
+	}
+	if parseErr.Column != 41 {
+		t.Errorf("Expected column 41, got %d", parseErr.Column)
+	}
+	if parseErr.Text != "    Expenses:Food                $10.00 {abc}" {
+		t.Errorf("Expected the offending line, got '%s'", parseErr.Text)
+	}
+
+	expected := "line 6, column 41: posting error: invalid lot cost: invalid amount: abc\n" +
+		"      Expenses:Food                $10.00 {abc}\n" +
+		"                                          ^"
+	if err.Error() != expected {
+		t.Errorf("Expected error\n%s\ngot\n%s", expected, err.Error())
+	}
+}
+
+func TestParseRecovery(t *testing.T) {
+	p := NewParser()
+	p.SetRecovery(true)
+
+	input := `2012-01-01 * Opening
+    Assets:Cash                  $10.00
+    Equity:Opening              $-10.00
+
+2012-01-02 * Groceries
+    Expenses:Food                $10.00 junk
+    Assets:Cash
+
+2012-01-03 * Lunch
+    Expenses:Food                 $5.00
+    Assets:Cash                  $-4.00
+
+P 2012-01-04 EUR abc
+
+2012-01-05 * Coffee
+    Expenses:Food                 $2.00
+    Assets:Cash`
+
+	err := p.Parse(strings.NewReader(input))
+	var parseErrs ParseErrors
+	if !errors.As(err, &parseErrs) {
+		t.Fatalf("Expected ParseErrors, got %v", err)
+	}
+
+	lines := []int{6, 11, 13}
+	if len(parseErrs) != len(lines) {
+		t.Fatalf("Expected %d errors, got %d: %v", len(lines), len(parseErrs), err)
+	}
+	for i, line := range lines {
+		if parseErrs[i].Line != line {
+			t.Errorf("Expected error %d at line %d, got %d", i, line, parseErrs[i].Line)
+		}
+	}
+
+	// The entries without errors are still parsed
+	if len(p.GetTransactions()) != 2 {
+		t.Errorf("Expected 2 transactions, got %d", len(p.GetTransactions()))
+	}
+}
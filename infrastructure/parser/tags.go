@@ -0,0 +1,40 @@
+package parser
+
+import (
+	"regexp"
+	"strings"
+)
+
+// tagListPattern matches ":tag1:tag2:" tag lists in notes
+var tagListPattern = regexp.MustCompile(`:[^\s:]+(?::[^\s:]+)*:`)
+
+// metadataPattern matches "Key: value" metadata notes
+var metadataPattern = regexp.MustCompile(`^([^\s:]+)::?\s+(.*)$`)
+
+// parseTags records the tags and metadata found in a note. A note is either
+// "Key: value" metadata or free text that may contain ":tag1:tag2:" lists.
+func parseTags(note string, metadata map[string]string) {
+	note = strings.TrimSpace(note)
+	if note == "" {
+		return
+	}
+
+	if match := metadataPattern.FindStringSubmatch(note); match != nil {
+		metadata[match[1]] = strings.TrimSpace(match[2])
+		return
+	}
+
+	for _, list := range tagListPattern.FindAllString(note, -1) {
+		for _, tag := range strings.Split(strings.Trim(list, ":"), ":") {
+			metadata[tag] = ""
+		}
+	}
+}
+
+// appendNote adds a line to a possibly empty note
+func appendNote(note, line string) string {
+	if note == "" {
+		return line
+	}
+	return note + "\n" + line
+}